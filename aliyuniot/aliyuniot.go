@@ -26,12 +26,19 @@ const (
     APP_SECRET = "1ba85698bb10e19c6437413b61ba3445"
     APP_VERSION = "1.11.130"
     ALIYUN_DOMAIN = "api.link.aliyun.com"
+    OPENACCOUNT_DOMAIN = "sdk.openaccount.aliyun.com"
 )
 
 type CloudIOTGateway struct {
 	AppKey                   string
 	AppSecret                string
 	Domain                   string
+	// OpenAccountDomain serves the connect call; the region response
+	// supplies every later endpoint.
+	OpenAccountDomain        string
+	// Protocol is HTTPS against the real cloud. A local stand-in such as
+	// the fakecloud harness can be reached over plain HTTP.
+	Protocol                 string
 	ClientID                 string
 	DeviceSN                 string
 	Utdid                    string
@@ -208,6 +215,8 @@ func NewCloudIOTGateway() *CloudIOTGateway {
 		AppKey:    APP_KEY,
 		AppSecret: APP_SECRET,
 		Domain:    ALIYUN_DOMAIN,
+		OpenAccountDomain: OPENACCOUNT_DOMAIN,
+		Protocol:  "HTTPS",
 		ClientID:  clientId,
 		DeviceSN:  deviceSn,
 		Utdid:     utdid,
@@ -286,6 +295,14 @@ func (c *Client) DoRequest(endpoint, protocol, method string, headers map[string
 	return client.Do(req)
 }
 
+// protocol is the scheme for every gateway request, HTTPS unless overridden.
+func (cg *CloudIOTGateway) protocol() string {
+	if cg.Protocol == "" {
+		return "HTTPS"
+	}
+	return cg.Protocol
+}
+
 func (cg *CloudIOTGateway) Sign(data map[string]string) string {
 	keys := []string{"appKey", "clientId", "deviceSn", "timestamp"}
 	concatenatedStr := ""
@@ -303,7 +320,8 @@ func (cg *CloudIOTGateway) GetRegion(countryCode, authCode string) (*RegionRespo
     config := new(iot.Config).
 		SetAppKey(cg.AppKey).
 		SetAppSecret(cg.AppSecret).
-		SetDomain(cg.Domain)
+		SetDomain(cg.Domain).
+		SetProtocol(cg.protocol())
 
     client, err := iot.NewClient(config)
 	if err != nil {
@@ -359,7 +377,8 @@ func (cg *CloudIOTGateway) SessionByAuthCode() error {
     config := new(iot.Config).
 		SetAppKey(cg.AppKey).
 		SetAppSecret(cg.AppSecret).
-		SetDomain(cg.RegionResponse.Data.ApiGatewayEndpoint)
+		SetDomain(cg.RegionResponse.Data.ApiGatewayEndpoint).
+		SetProtocol(cg.protocol())
 
     client, err := iot.NewClient(config)
 	if err != nil {
@@ -412,7 +431,8 @@ func (cg *CloudIOTGateway) CheckOrRefreshSession() error {
 	config := new(iot.Config).
 		SetAppKey(cg.AppKey).
 		SetAppSecret(cg.AppSecret).
-		SetDomain(cg.RegionResponse.Data.ApiGatewayEndpoint)
+		SetDomain(cg.RegionResponse.Data.ApiGatewayEndpoint).
+		SetProtocol(cg.protocol())
 
 	client, err := iot.NewClient(config)
 	if err != nil {
@@ -465,7 +485,8 @@ func (cg *CloudIOTGateway) ListDevices() ([]Device, error) {
     config := new(iot.Config).
 		SetAppKey(cg.AppKey).
 		SetAppSecret(cg.AppSecret).
-		SetDomain(cg.RegionResponse.Data.ApiGatewayEndpoint)
+		SetDomain(cg.RegionResponse.Data.ApiGatewayEndpoint).
+		SetProtocol(cg.protocol())
 
     client, err := iot.NewClient(config)
 	if err != nil {
@@ -519,7 +540,8 @@ func (cg *CloudIOTGateway) AepHandle() error {
     config := new(iot.Config).
 		SetAppKey(cg.AppKey).
 		SetAppSecret(cg.AppSecret).
-		SetDomain(aepDomain)
+		SetDomain(aepDomain).
+		SetProtocol(cg.protocol())
 
     client, err := iot.NewClient(config)
 	if err != nil {
@@ -579,7 +601,10 @@ func (cg *CloudIOTGateway) AepHandle() error {
 }
 
 func (cg *CloudIOTGateway) Connect() error {
-	regionURL := "sdk.openaccount.aliyun.com"
+	regionURL := cg.OpenAccountDomain
+	if regionURL == "" {
+		regionURL = OPENACCOUNT_DOMAIN
+	}
 	headers := map[string]string{
 		"host":                  regionURL,
 		"date":                  time.Now().UTC().Format(http.TimeFormat),
@@ -651,7 +676,7 @@ func (cg *CloudIOTGateway) Connect() error {
 	signature := base64.StdEncoding.EncodeToString(hash.Sum(nil))
 	headers["x-ca-signature"] = signature
 
-	req, err := http.NewRequest("POST", fmt.Sprintf("https://%s/api/prd/connect.json?request=%s", regionURL, jsonToString(bodyParam)), nil)
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s://%s/api/prd/connect.json?request=%s", strings.ToLower(cg.protocol()), regionURL, jsonToString(bodyParam)), nil)
 	if err != nil {
 		return err
 	}
@@ -761,7 +786,7 @@ func (cg *CloudIOTGateway) LoginByOAuth(countryCode, authCode string) (*LoginByO
 	signature := base64.StdEncoding.EncodeToString(hash.Sum(nil))
 	headers["x-ca-signature"] = signature
 
-	req, err := http.NewRequest("POST", fmt.Sprintf("https://%s/api/prd/loginbyoauth.json?loginByOauthRequest=%s", regionURL, jsonToString(bodyParam)), nil)
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s://%s/api/prd/loginbyoauth.json?loginByOauthRequest=%s", strings.ToLower(cg.protocol()), regionURL, jsonToString(bodyParam)), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -806,6 +831,7 @@ func (cg *CloudIOTGateway) SendCloudCommand(iotID string, command []byte) (strin
 		AppKey:    tea.String(cg.AppKey),
 		AppSecret: tea.String(cg.AppSecret),
 		Domain:    tea.String(cg.RegionResponse.Data.ApiGatewayEndpoint),
+		Protocol:  tea.String(cg.protocol()),
 	}
 
 	client, err := iot.NewClient(config)
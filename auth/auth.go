@@ -9,9 +9,14 @@ import (
 	"net/http"
 )
 
-const (
+// The account endpoints are variables so a local stand-in (see the fakecloud
+// package) can replace them.
+var (
 	MAMMOTION_AUTH_DOMAIN   = "https://id.mammotion.com"
 	MAMMOTION_API_DOMAIN   = "https://domestic.mammotion.com"
+)
+
+const (
 	MAMMOTION_CLIENT_ID    = "MADKALUBAS"
 	MAMMOTION_CLIENT_SECRET = "GshzGRZJjuMUgd2sYHM7"
 )
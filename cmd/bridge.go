@@ -0,0 +1,454 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"mammo/mammotion"
+	pb "mammo/proto"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/spf13/cobra"
+)
+
+// Home Assistant lawn_mower activities.
+const (
+	haMowing    = "mowing"
+	haPaused    = "paused"
+	haDocked    = "docked"
+	haReturning = "returning"
+)
+
+// haActivity maps rpt_dev_status onto a Home Assistant lawn_mower activity.
+// Ready (and any status HA has no word for) counts as docked while the mower
+// is charging and paused otherwise, HA having no idle activity; start_mowing
+// goes by the raw status, so only a real pause is resumed.
+func haActivity(sysStatus, chargeState int32) string {
+	switch sysStatus {
+	case sysStatusWorking:
+		return haMowing
+	case sysStatusPaused:
+		return haPaused
+	case sysStatusReturning:
+		return haReturning
+	case sysStatusCharging:
+		return haDocked
+	}
+	if chargeState != 0 {
+		return haDocked
+	}
+	return haPaused
+}
+
+// haState is the JSON document published on the state topic. The lawn_mower
+// entity and every sensor read their value out of it with a template.
+type haState struct {
+	Activity    string  `json:"activity"`
+	Battery     int     `json:"battery"`
+	RTKFix      string  `json:"rtk_fix"`
+	Progress    int32   `json:"progress"`     // percent of the current job
+	KnifeHeight int32   `json:"knife_height"` // mm
+	X           float64 `json:"x"`            // metres, map frame
+	Y           float64 `json:"y"`
+	Position    string  `json:"position"`
+	Error       int32   `json:"error"` // last toapp_err_code, 0 for none
+
+	sysStatus int32 // raw rpt_dev_status, which start_mowing acts on
+}
+
+// haSensor is one sensor entity published alongside the lawn mower.
+type haSensor struct {
+	key         string // haState JSON field and object id
+	name        string
+	unit        string
+	deviceClass string
+	icon        string
+}
+
+var haSensors = []haSensor{
+	{key: "battery", name: "Battery", unit: "%", deviceClass: "battery"},
+	{key: "rtk_fix", name: "RTK fix", icon: "mdi:crosshairs-gps"},
+	{key: "progress", name: "Job progress", unit: "%", icon: "mdi:progress-check"},
+	{key: "knife_height", name: "Cutting height", unit: "mm", deviceClass: "distance"},
+	{key: "position", name: "Position", icon: "mdi:map-marker"},
+	{key: "error", name: "Error code", icon: "mdi:alert-circle"},
+}
+
+// haTopics are the bridge's own topics for one mower, under mammo/<node>.
+type haTopics struct {
+	node         string
+	state        string
+	availability string
+	start        string
+	pause        string
+	dock         string
+}
+
+var haNodeInvalid = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// newHATopics derives the node id (Home Assistant allows [a-zA-Z0-9_-] in
+// discovery topics) and topic names from the device name.
+func newHATopics(deviceName string) haTopics {
+	node := strings.Trim(haNodeInvalid.ReplaceAllString(strings.ToLower(deviceName), "_"), "_")
+	if node == "" {
+		node = "mower"
+	}
+	base := "mammo/" + node
+	return haTopics{
+		node:         node,
+		state:        base + "/state",
+		availability: base + "/availability",
+		start:        base + "/command/start_mowing",
+		pause:        base + "/command/pause",
+		dock:         base + "/command/dock",
+	}
+}
+
+// haDiscovery returns the retained MQTT discovery configs, keyed by topic:
+// one lawn_mower entity and a sensor per haSensors entry, all grouped under a
+// single device.
+func haDiscovery(prefix, deviceName string, t haTopics) (map[string][]byte, error) {
+	device := map[string]any{
+		"identifiers":  []string{"mammo_" + t.node},
+		"name":         deviceName,
+		"manufacturer": "Mammotion",
+		"model":        "Luba",
+	}
+	configs := map[string]map[string]any{
+		fmt.Sprintf("%s/lawn_mower/%s/mower/config", prefix, t.node): {
+			"name":                       nil,
+			"unique_id":                  t.node + "_mower",
+			"activity_state_topic":       t.state,
+			"activity_value_template":    "{{ value_json.activity }}",
+			"start_mowing_command_topic": t.start,
+			"pause_command_topic":        t.pause,
+			"dock_command_topic":         t.dock,
+			"availability_topic":         t.availability,
+			"device":                     device,
+		},
+	}
+	for _, sn := range haSensors {
+		cfg := map[string]any{
+			"name":               sn.name,
+			"unique_id":          t.node + "_" + sn.key,
+			"state_topic":        t.state,
+			"value_template":     "{{ value_json." + sn.key + " }}",
+			"availability_topic": t.availability,
+			"device":             device,
+		}
+		if sn.unit != "" {
+			cfg["unit_of_measurement"] = sn.unit
+		}
+		if sn.deviceClass != "" {
+			cfg["device_class"] = sn.deviceClass
+		}
+		if sn.icon != "" {
+			cfg["icon"] = sn.icon
+		}
+		configs[fmt.Sprintf("%s/sensor/%s/%s/config", prefix, t.node, sn.key)] = cfg
+	}
+
+	out := make(map[string][]byte, len(configs))
+	for topic, cfg := range configs {
+		payload, err := json.Marshal(cfg)
+		if err != nil {
+			return nil, err
+		}
+		out[topic] = payload
+	}
+	return out, nil
+}
+
+// haCommandNav is the nav message for a lawn_mower command.
+func haCommandNav(command string, st haState, breakPoint bool) (*pb.MctlNav, error) {
+	switch command {
+	case "start_mowing":
+		return startMowingNav(st.sysStatus == sysStatusPaused, breakPoint, st.KnifeHeight), nil
+	case "pause":
+		return &pb.MctlNav{SubNavMsg: &pb.MctlNav_TodevTaskctrl{TodevTaskctrl: &pb.NavTaskCtrl{Type: 1, Action: 1}}}, nil
+	case "dock":
+		return &pb.MctlNav{SubNavMsg: &pb.MctlNav_TodevRechgcmd{TodevRechgcmd: 1}}, nil
+	}
+	return nil, fmt.Errorf("unknown command %q", command)
+}
+
+// haBridge mirrors one mower's state into Home Assistant and forwards its
+// lawn_mower commands to the mower.
+type haBridge struct {
+	s      *cloudSession
+	client mqtt.Client
+	topics haTopics
+	prefix string
+
+	mu         sync.Mutex
+	state      haState
+	breakPoint bool
+
+	dirty chan struct{} // the state changed since it was last published
+	done  chan struct{}
+}
+
+// haPublishTimeout bounds a publish that waits for the broker.
+const haPublishTimeout = 10 * time.Second
+
+// startHABridge publishes the discovery configs, subscribes to the command
+// topics and starts republishing the session's state. client must already be
+// connected.
+func startHABridge(s *cloudSession, client mqtt.Client, prefix string) (*haBridge, error) {
+	b := &haBridge{
+		s:      s,
+		client: client,
+		topics: newHATopics(s.device.DeviceName),
+		prefix: prefix,
+		dirty:  make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	b.state.Activity = haDocked
+	b.state.RTKFix = "unknown"
+	if err := b.announce(); err != nil {
+		return nil, err
+	}
+	go b.publishChanges()
+
+	sm := s.stateManager
+	sm.OnPropertiesReceived = func() {
+		b.update(func(st *haState) { st.Battery = s.mowingDevice.BatteryPercentage })
+	}
+	sm.OnDeviceStatus = func(sysStatus, chargeState int32) {
+		b.update(func(st *haState) {
+			st.Activity, st.sysStatus = haActivity(sysStatus, chargeState), sysStatus
+		})
+	}
+	sm.OnPositionUpdate = func(x, y float32, _ int32, posType int32) {
+		b.update(func(st *haState) {
+			// RealPos is in 0.1mm units.
+			st.X, st.Y = float64(x)/10000, float64(y)/10000
+			st.Position = fmt.Sprintf("%.2f, %.2f", st.X, st.Y)
+			st.RTKFix = rtkLabel(posType)
+		})
+	}
+	sm.OnWorkReport = func(w *mammotion.WorkData) {
+		b.update(func(st *haState) {
+			st.Progress = w.Area >> 16
+			st.KnifeHeight = w.KnifeHeight
+			b.breakPoint = w.BreakPoint != nil
+		})
+	}
+	sm.OnErrorCode = func(code int32) {
+		b.update(func(st *haState) { st.Error = code })
+	}
+	return b, nil
+}
+
+// announce publishes the discovery configs, subscribes to the command topics
+// and marks the mower available with its current state. It runs on every
+// connection to the broker: the session is clean, so a reconnect loses the
+// subscriptions, and a restarted broker may have lost the retained configs.
+func (b *haBridge) announce() error {
+	configs, err := haDiscovery(b.prefix, b.s.device.DeviceName, b.topics)
+	if err != nil {
+		return err
+	}
+	for topic, payload := range configs {
+		if err := b.publish(topic, true, payload); err != nil {
+			return fmt.Errorf("discovery: %w", err)
+		}
+	}
+
+	for command, topic := range map[string]string{
+		"start_mowing": b.topics.start,
+		"pause":        b.topics.pause,
+		"dock":         b.topics.dock,
+	} {
+		tok := b.client.Subscribe(topic, 1, func(_ mqtt.Client, _ mqtt.Message) {
+			// Off paho's delivery goroutine: the replies publish state
+			// back to the same client.
+			go func() {
+				if err := b.command(command); err != nil {
+					log.Printf("bridge: %s: %v", command, err)
+				}
+			}()
+		})
+		if !tok.WaitTimeout(haPublishTimeout) {
+			return fmt.Errorf("subscribe %s: timed out", topic)
+		}
+		if tok.Error() != nil {
+			return fmt.Errorf("subscribe %s: %w", topic, tok.Error())
+		}
+	}
+
+	if err := b.publish(b.topics.availability, true, []byte("online")); err != nil {
+		return err
+	}
+	return b.publishState()
+}
+
+// reconnected announces the bridge again after paho has reconnected to the
+// broker.
+func (b *haBridge) reconnected() {
+	if err := b.announce(); err != nil {
+		log.Printf("bridge: announce after reconnecting: %v", err)
+		return
+	}
+	log.Printf("bridge: reconnected to the Home Assistant broker")
+}
+
+func (b *haBridge) publish(topic string, retained bool, payload []byte) error {
+	tok := b.client.Publish(topic, 1, retained, payload)
+	if !tok.WaitTimeout(haPublishTimeout) {
+		return fmt.Errorf("publish %s: timed out", topic)
+	}
+	return tok.Error()
+}
+
+// update applies fn to the state and queues it to be republished. It never
+// waits for the broker, so the cloud callbacks calling it keep going while
+// Home Assistant's broker is slow or down.
+func (b *haBridge) update(fn func(*haState)) {
+	b.mu.Lock()
+	fn(&b.state)
+	b.mu.Unlock()
+	select {
+	case b.dirty <- struct{}{}:
+	default: // a publish is already pending and will carry this change
+	}
+}
+
+// publishChanges republishes the state after each update until Close. Updates
+// made while a publish is in flight are coalesced into the next one.
+func (b *haBridge) publishChanges() {
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-b.dirty:
+			if err := b.publishState(); err != nil {
+				log.Printf("bridge: publish state: %v", err)
+			}
+		}
+	}
+}
+
+func (b *haBridge) publishState() error {
+	b.mu.Lock()
+	payload, err := json.Marshal(b.state)
+	b.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return b.publish(b.topics.state, true, payload)
+}
+
+func (b *haBridge) command(command string) error {
+	b.mu.Lock()
+	st, bp := b.state, b.breakPoint
+	b.mu.Unlock()
+	nav, err := haCommandNav(command, st, bp)
+	if err != nil {
+		return err
+	}
+	if err := b.s.refresh(); err != nil {
+		return fmt.Errorf("refresh: %w", err)
+	}
+	log.Printf("bridge: %s", command)
+	return sendNav(b.s, nav)
+}
+
+// Close stops republishing state and marks the mower unavailable in Home
+// Assistant.
+func (b *haBridge) Close() error {
+	close(b.done)
+	return b.publish(b.topics.availability, true, []byte("offline"))
+}
+
+// haClientOptions are the paho options for the Home Assistant broker. The
+// will marks the mower unavailable if the bridge dies without closing.
+// onConnect runs after every connection, the first and each automatic
+// reconnect, on a goroutine of its own.
+func haClientOptions(broker, user, pass, deviceName string, onConnect mqtt.OnConnectHandler) *mqtt.ClientOptions {
+	t := newHATopics(deviceName)
+	opts := mqtt.NewClientOptions().
+		AddBroker(broker).
+		SetClientID(fmt.Sprintf("mammo-bridge-%s-%d", t.node, os.Getpid())).
+		SetUsername(user).
+		SetPassword(pass).
+		SetAutoReconnect(true).
+		SetConnectTimeout(10*time.Second).
+		SetWill(t.availability, "offline", 1, true).
+		SetOnConnectHandler(onConnect)
+	return opts
+}
+
+var (
+	haBroker          string
+	haUsername        string
+	haPassword        string
+	haDiscoveryPrefix string
+)
+
+var bridgeCmd = &cobra.Command{
+	Use:   "bridge",
+	Short: "Bridge the mower into other systems",
+}
+
+var bridgeHomeAssistantCmd = &cobra.Command{
+	Use:   "homeassistant",
+	Short: "Expose the mower to Home Assistant over MQTT discovery",
+	Long: `Keeps a cloud session open and connects to Home Assistant's MQTT
+broker (e.g. Mosquitto). Publishes discovery configs for a lawn_mower entity
+and sensors for battery, RTK fix, job progress, cutting height, position and
+error code, then republishes state as the mower reports it.
+
+The lawn_mower's start, pause and dock commands are sent to the mower: start
+resumes a paused or interrupted job or otherwise starts a new one (NavStartJob),
+pause is NavTaskCtrl pause and dock is todev_rechgcmd. Runs until Ctrl-C.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		withSession(func(s *cloudSession) error {
+			// The first connection is announced by startHABridge; reconnects
+			// once it is running.
+			var bridge atomic.Pointer[haBridge]
+			client := mqtt.NewClient(haClientOptions(haBroker, haUsername, haPassword, s.device.DeviceName, func(mqtt.Client) {
+				if b := bridge.Load(); b != nil {
+					b.reconnected()
+				}
+			}))
+			if tok := client.Connect(); tok.Wait() && tok.Error() != nil {
+				return fmt.Errorf("home assistant broker: %w", tok.Error())
+			}
+			defer client.Disconnect(250)
+
+			b, err := startHABridge(s, client, haDiscoveryPrefix)
+			if err != nil {
+				return err
+			}
+			bridge.Store(b)
+			defer b.Close()
+			stopPolling := startPolling(s)
+			defer stopPolling()
+
+			fmt.Printf("Bridging %s to Home Assistant at %s (discovery prefix %q). Ctrl-C to stop.\n",
+				s.device.DeviceName, haBroker, haDiscoveryPrefix)
+			sig := make(chan os.Signal, 1)
+			signal.Notify(sig, os.Interrupt)
+			<-sig
+			return nil
+		})
+	},
+}
+
+func init() {
+	bridgeHomeAssistantCmd.Flags().StringVar(&haBroker, "ha-broker", "tcp://localhost:1883", "Home Assistant MQTT broker URL")
+	bridgeHomeAssistantCmd.Flags().StringVar(&haUsername, "ha-username", "", "Home Assistant MQTT username")
+	bridgeHomeAssistantCmd.Flags().StringVar(&haPassword, "ha-password", "", "Home Assistant MQTT password")
+	bridgeHomeAssistantCmd.Flags().StringVar(&haDiscoveryPrefix, "discovery-prefix", "homeassistant", "MQTT discovery prefix")
+	bridgeCmd.AddCommand(bridgeHomeAssistantCmd)
+	rootCmd.AddCommand(bridgeCmd)
+}
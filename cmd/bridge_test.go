@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"mammo/fakecloud"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// TestHomeAssistantBridge runs the bridge against a local broker standing in
+// for Home Assistant's: discovery, republished state and the lawn_mower
+// commands reaching the simulated mower.
+func TestHomeAssistantBridge(t *testing.T) {
+	broker, err := fakecloud.NewBroker("127.0.0.1:0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer broker.Close()
+	var mu sync.Mutex
+	published := map[string][]byte{}
+	broker.OnPublish = func(_, topic string, payload []byte) {
+		mu.Lock()
+		published[topic] = payload
+		mu.Unlock()
+	}
+	lastState := func() haState {
+		mu.Lock()
+		defer mu.Unlock()
+		var st haState
+		json.Unmarshal(published["mammo/luba_sim/state"], &st)
+		return st
+	}
+	waitFor := func(what string, ok func(haState) bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !ok(lastState()) {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s: last state %+v", what, lastState())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	var bridge atomic.Pointer[haBridge]
+	client := mqtt.NewClient(haClientOptions(broker.URL(), "", "", "Luba-SIM", func(mqtt.Client) {
+		if b := bridge.Load(); b != nil {
+			b.reconnected()
+		}
+	}))
+	if tok := client.Connect(); tok.Wait() && tok.Error() != nil {
+		t.Fatal(tok.Error())
+	}
+	defer client.Disconnect(0)
+
+	s, sim := newSimSession(simLawn())
+	b, err := startHABridge(s, client, "homeassistant")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bridge.Store(b)
+
+	mu.Lock()
+	var cfg map[string]any
+	json.Unmarshal(published["homeassistant/lawn_mower/luba_sim/mower/config"], &cfg)
+	sensors := 0
+	for topic := range published {
+		if strings.HasPrefix(topic, "homeassistant/sensor/luba_sim/") {
+			sensors++
+		}
+	}
+	mu.Unlock()
+	if cfg["start_mowing_command_topic"] != "mammo/luba_sim/command/start_mowing" || cfg["activity_state_topic"] != "mammo/luba_sim/state" {
+		t.Errorf("lawn_mower discovery config = %v", cfg)
+	}
+	if sensors != len(haSensors) {
+		t.Errorf("%d sensor configs published, want %d", sensors, len(haSensors))
+	}
+
+	if err := primeSession(s); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("docked report", func(st haState) bool {
+		return st.Activity == haDocked && st.Battery == 100 && st.KnifeHeight == 60 && st.RTKFix == "RTK-Fix"
+	})
+
+	for _, step := range []struct {
+		command, activity string
+		status            int32
+	}{
+		{"start_mowing", haMowing, sysStatusWorking},
+		{"pause", haPaused, sysStatusPaused},
+		{"start_mowing", haMowing, sysStatusWorking},
+		{"dock", haReturning, sysStatusReturning},
+	} {
+		broker.Publish("mammo/luba_sim/command/"+step.command, nil)
+		waitFor(step.command, func(st haState) bool { return st.Activity == step.activity })
+		if sim.Status() != step.status {
+			t.Errorf("after %s sys_status = %d, want %d", step.command, sim.Status(), step.status)
+		}
+	}
+
+	// After the broker drops the connection the bridge reconnects, announces
+	// itself again and still takes commands.
+	// Availability is published once the subscriptions are back.
+	mu.Lock()
+	delete(published, "homeassistant/lawn_mower/luba_sim/mower/config")
+	delete(published, "mammo/luba_sim/availability")
+	mu.Unlock()
+	broker.DropClients()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		_, config := published["homeassistant/lawn_mower/luba_sim/mower/config"]
+		online := string(published["mammo/luba_sim/availability"]) == "online"
+		mu.Unlock()
+		if config && online {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("bridge did not announce itself again after reconnecting")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	broker.Publish("mammo/luba_sim/command/start_mowing", nil)
+	waitFor("start_mowing after reconnecting", func(st haState) bool { return st.Activity == haMowing })
+
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	if got := string(published["mammo/luba_sim/availability"]); got != "offline" {
+		t.Errorf("availability after Close = %q", got)
+	}
+	mu.Unlock()
+}
+
+// TestHACommandNavStartFromReady checks that start_mowing only resumes a real
+// pause: a Ready mower off the dock shows as paused in Home Assistant but
+// must be sent a new job.
+func TestHACommandNavStartFromReady(t *testing.T) {
+	for _, c := range []struct {
+		name                   string
+		sysStatus, chargeState int32
+		breakPoint             bool
+		want                   string
+	}{
+		{"ready on the lawn", sysStatusReady, 0, false, "mow task"},
+		{"ready on the dock", sysStatusReady, 1, false, "mow task"},
+		{"paused", sysStatusPaused, 0, false, "resume"},
+		{"ready with a breakpoint", sysStatusReady, 0, true, "breakpoint"},
+	} {
+		st := haState{Activity: haActivity(c.sysStatus, c.chargeState), sysStatus: c.sysStatus, KnifeHeight: 60}
+		nav, err := haCommandNav("start_mowing", st, c.breakPoint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := "other"
+		if job := nav.GetTodevMowTask(); job != nil && job.KnifeHeight == 60 {
+			got = "mow task"
+		} else if ctrl := nav.GetTodevTaskctrl(); ctrl != nil && ctrl.Action == 0 {
+			got = "resume"
+		} else if ctrl != nil && ctrl.Action == taskCtrlBreakPointContinue {
+			got = "breakpoint"
+		}
+		if got != c.want {
+			t.Errorf("%s: start_mowing sent %s, want %s", c.name, got, c.want)
+		}
+	}
+}
@@ -2,7 +2,11 @@ package cmd
 
 import (
 	"fmt"
+	"log"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -15,22 +19,92 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
-// cloudSession holds the connected device and gateway for control commands.
+// cloudSession holds the connected device and its transport for control
+// commands. Everything in cmd talks to the mower through transport, so the
+// cloud, the simulator or a replayed recording are interchangeable.
 type cloudSession struct {
-	gateway      *aliyuniot.CloudIOTGateway
-	mqttClient   *mammotion.MammotionMQTT
+	transport    mammotion.Transport
 	device       *aliyuniot.Device
 	mowingDevice *mammotion.MowingDevice
 	stateManager *mammotion.StateManager
 }
 
+// send delivers one app→device LubaMsg.
+func (s *cloudSession) send(data []byte) error {
+	return s.transport.Send(data)
+}
+
+// refresh renews the transport's session if it has one (the cloud token).
+func (s *cloudSession) refresh() error {
+	if r, ok := s.transport.(interface{ Refresh() error }); ok {
+		return r.Refresh()
+	}
+	return nil
+}
+
 func (s *cloudSession) Close() {
-	if s.mqttClient != nil {
-		s.mqttClient.Disconnect()
+	if s.transport != nil {
+		s.transport.Close()
 	}
 }
 
+// Endpoint overrides for a self-hosted stand-in such as `mammo fakecloud`.
+var (
+	cloudURL  string
+	brokerURL string
+	caFile    string
+
+	defaultAuthDomain = auth.MAMMOTION_AUTH_DOMAIN
+	defaultAPIDomain  = auth.MAMMOTION_API_DOMAIN
+)
+
+// applyEndpoints points the account service at --cloud-url and the MQTT
+// client at --broker and --ca-file, or back at the real cloud when unset.
+func applyEndpoints() error {
+	mammotion.BrokerURL = brokerURL
+	mammotion.RootCAFile = caFile
+	auth.MAMMOTION_AUTH_DOMAIN = defaultAuthDomain
+	auth.MAMMOTION_API_DOMAIN = defaultAPIDomain
+	if cloudURL == "" {
+		return nil
+	}
+	if u, err := url.Parse(cloudURL); err != nil || u.Host == "" {
+		return fmt.Errorf("--cloud-url %q: want a base URL like http://127.0.0.1:8080", cloudURL)
+	}
+	auth.MAMMOTION_AUTH_DOMAIN = strings.TrimSuffix(cloudURL, "/")
+	auth.MAMMOTION_API_DOMAIN = auth.MAMMOTION_AUTH_DOMAIN
+	return nil
+}
+
+// newGateway returns an IoT gateway whose first calls go to --cloud-url when
+// set; the region response then supplies every later endpoint.
+func newGateway() *aliyuniot.CloudIOTGateway {
+	cg := aliyuniot.NewCloudIOTGateway()
+	if u, err := url.Parse(cloudURL); err == nil && u.Host != "" {
+		cg.Domain = u.Host
+		cg.OpenAccountDomain = u.Host
+		cg.Protocol = strings.ToUpper(u.Scheme)
+	}
+	return cg
+}
+
+// recordFile, when set (--record), captures the session's traffic for replay.
+var recordFile string
+
 func connectCloud() (*cloudSession, error) {
+	sessions, err := connectCloudDevices(nil)
+	if err != nil {
+		return nil, err
+	}
+	return sessions[0], nil
+}
+
+// connectCloudDevices logs in and opens a session for each device in names
+// (device names or nicknames, or "all" for every device on the account), all
+// over one MQTT connection. With no names it opens the first device. With
+// --record and several devices, each is recorded to its own file named after
+// the device.
+func connectCloudDevices(names []string) ([]*cloudSession, error) {
 	client, err := auth.ConnectHTTP(username, password)
 	if err != nil {
 		return nil, fmt.Errorf("login: %w", err)
@@ -39,7 +113,7 @@ func connectCloud() (*cloudSession, error) {
 		return nil, fmt.Errorf("login: LoginInfo nil")
 	}
 
-	cg := aliyuniot.NewCloudIOTGateway()
+	cg := newGateway()
 	if _, err := cg.GetRegion(client.LoginInfo.UserInformation.DomainAbbreviation, client.LoginInfo.AuthorizationCode); err != nil {
 		return nil, fmt.Errorf("region: %w", err)
 	}
@@ -85,59 +159,145 @@ func connectCloud() (*cloudSession, error) {
 	mammoCloud.ConnectAsync()
 	wg.Wait()
 
-	firstDevice := devices[0]
-	mowingDevice := mammotion.NewMowingDevice(&firstDevice, *cg, mammoCloud)
-	stateManager := mammotion.NewStateManager(mowingDevice)
-	mammotion.NewMammotionBaseCloudDevice(mammoCloud, mowingDevice, stateManager)
-
-	return &cloudSession{
-		gateway:      cg,
-		mqttClient:   mqttClient,
-		device:       &firstDevice,
-		mowingDevice: mowingDevice,
-		stateManager: stateManager,
-	}, nil
+	picked, err := pickDevices(devices, names)
+	if err != nil {
+		mammoCloud.Disconnect()
+		return nil, err
+	}
+
+	var sessions []*cloudSession
+	for i := range picked {
+		device := &picked[i]
+		mowingDevice := mammotion.NewMowingDevice(device, *cg, mammoCloud)
+		stateManager := mammotion.NewStateManager(mowingDevice)
+		cloudDevice := mammotion.NewMammotionBaseCloudDevice(mammoCloud, mowingDevice, stateManager)
+		var transport mammotion.Transport = mammotion.NewCloudTransport(cg, mammoCloud, cloudDevice)
+		if recordFile != "" {
+			path := recordFile
+			if len(picked) > 1 {
+				ext := filepath.Ext(path)
+				path = strings.TrimSuffix(path, ext) + "-" + device.DeviceName + ext
+			}
+			rec, err := mammotion.CreateSessionRecording(path, device.DeviceName)
+			if err != nil {
+				transport.Close()
+				closeSessions(sessions)
+				return nil, fmt.Errorf("record: %w", err)
+			}
+			transport = mammotion.NewRecordingTransport(transport, rec)
+		}
+		stateManager.Attach(transport)
+		sessions = append(sessions, &cloudSession{
+			transport:    transport,
+			device:       device,
+			mowingDevice: mowingDevice,
+			stateManager: stateManager,
+		})
+	}
+	return sessions, nil
+}
+
+// pickDevices selects the devices named by --devices from the account's.
+func pickDevices(devices []aliyuniot.Device, names []string) ([]aliyuniot.Device, error) {
+	if len(names) == 0 {
+		return devices[:1], nil
+	}
+	if len(names) == 1 && names[0] == "all" {
+		return devices, nil
+	}
+	var picked []aliyuniot.Device
+	for _, name := range names {
+		found := false
+		for _, d := range devices {
+			if d.DeviceName == name || d.NickName != "" && d.NickName == name {
+				picked = append(picked, d)
+				found = true
+				break
+			}
+		}
+		if !found {
+			var known []string
+			for _, d := range devices {
+				known = append(known, d.DeviceName)
+			}
+			return nil, fmt.Errorf("no device %q on the account (have %s)", name, strings.Join(known, ", "))
+		}
+	}
+	return picked, nil
+}
+
+// closeSessions closes sessions that share a cloud connection; closing any
+// of them ends it for all.
+func closeSessions(sessions []*cloudSession) {
+	for _, s := range sessions {
+		s.Close()
+	}
 }
 
 // primeSession sends BLE sync + report-cfg so the device starts reporting.
 func primeSession(s *cloudSession) error {
-	if err := s.gateway.CheckOrRefreshSession(); err != nil {
+	if err := s.refresh(); err != nil {
 		return fmt.Errorf("refresh: %w", err)
 	}
 	bleSyncData, err := mammotion.SendTodevBleSync(3)
 	if err != nil {
 		return err
 	}
-	if _, err := s.gateway.SendCloudCommand(s.device.IotId, bleSyncData); err != nil {
+	if err := s.send(bleSyncData); err != nil {
 		return fmt.Errorf("ble_sync: %w", err)
 	}
 	reportCfgData, err := mammotion.GetReportCfg(10000, 1000, 1000)
 	if err != nil {
 		return err
 	}
-	if _, err := s.gateway.SendCloudCommand(s.device.IotId, reportCfgData); err != nil {
+	if err := s.send(reportCfgData); err != nil {
 		return fmt.Errorf("report_cfg: %w", err)
 	}
 	return nil
 }
 
+// Polling intervals; variables so tests can run them quickly.
+var (
+	pollInterval     = time.Second
+	pollRefreshEvery = 5 * time.Minute // renew the cloud session
+)
+
 // startPolling sends GetReportCfg every second in the background to keep
-// position/property updates flowing. The returned stop func ends polling.
+// position/property updates flowing, and renews the cloud session every few
+// minutes so long-running commands outlive the token they started with. Send
+// failures are logged when they start and when they clear, not on every poll.
+// The returned stop func ends polling.
 func startPolling(s *cloudSession) func() {
 	stop := make(chan struct{})
+	interval, refreshEvery := pollInterval, pollRefreshEvery
 	go func() {
-		t := time.NewTicker(1 * time.Second)
+		t := time.NewTicker(interval)
 		defer t.Stop()
+		refreshed := time.Now()
+		failing := false
 		for {
 			select {
 			case <-stop:
 				return
 			case <-t.C:
+				if time.Since(refreshed) >= refreshEvery {
+					refreshed = time.Now()
+					if err := s.refresh(); err != nil {
+						log.Printf("polling %s: refresh session: %v", s.device.DeviceName, err)
+					}
+				}
 				pollData, err := mammotion.GetReportCfg(10000, 1000, 1000)
 				if err != nil {
 					continue
 				}
-				_, _ = s.gateway.SendCloudCommand(s.device.IotId, pollData)
+				switch err := s.send(pollData); {
+				case err != nil && !failing:
+					log.Printf("polling %s: %v (updates have stopped until it recovers)", s.device.DeviceName, err)
+					failing = true
+				case err == nil && failing:
+					log.Printf("polling %s: recovered", s.device.DeviceName)
+					failing = false
+				}
 			}
 		}
 	}()
@@ -164,6 +324,28 @@ func withSession(fn func(*cloudSession) error) {
 	}
 }
 
+// withSessions is withSession for the devices in names, sharing one
+// connection; see connectCloudDevices.
+func withSessions(names []string, fn func([]*cloudSession) error) {
+	run := func() error {
+		sessions, err := connectCloudDevices(names)
+		if err != nil {
+			return fmt.Errorf("connect: %w", err)
+		}
+		defer closeSessions(sessions)
+		for _, s := range sessions {
+			if err := primeSession(s); err != nil {
+				return fmt.Errorf("prime %s: %w", s.device.DeviceName, err)
+			}
+		}
+		return fn(sessions)
+	}
+	if err := run(); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+}
+
 // buildNav wraps a nav sub-message in the standard app→main-controller LubaMsg envelope.
 func buildNav(nav *pb.MctlNav) ([]byte, error) {
 	lubaMsg := &pb.LubaMsg{
@@ -180,13 +362,65 @@ func buildNav(nav *pb.MctlNav) ([]byte, error) {
 	return proto.Marshal(lubaMsg)
 }
 
+// startMowingNav is the "start mowing" command for the device's state: it
+// resumes a paused task, continues an interrupted job from its breakpoint, and
+// otherwise starts a new job (todev_mow_task) at the given cutting height.
+func startMowingNav(paused, breakPoint bool, knifeHeight int32) *pb.MctlNav {
+	switch {
+	case paused && !breakPoint:
+		return &pb.MctlNav{SubNavMsg: &pb.MctlNav_TodevTaskctrl{TodevTaskctrl: &pb.NavTaskCtrl{Type: 1, Action: 0}}}
+	case breakPoint:
+		return breakPointContinueNav()
+	}
+	return &pb.MctlNav{SubNavMsg: &pb.MctlNav_TodevMowTask{TodevMowTask: &pb.NavStartJob{KnifeHeight: knifeHeight}}}
+}
+
+// The planning settings the phone app sends with a route request by default.
+// The device plans with exactly what it is given, so none may be left zero.
+const (
+	routeJobMode      = 4   // mow the listed zones
+	routeEdgeMode     = 1   // one lap of the edge before the zigzag
+	routeUltraWave    = 2   // ultrasonic obstacle handling
+	routeChannelWidth = 25  // cm between passes
+	routeChannelMode  = 0   // single zigzag
+	routeTowardAngle  = 90  // between the passes of a crosshatch
+	routeSpeed        = 0.3 // m/s
+)
+
+// zoneRouteNav asks the device to plan the coverage route for one zone
+// (bidire_reqconver_path) with the app's default settings. It answers with the
+// route as toapp_zigzag frames; a following todev_mow_task or todev_edgecmd
+// then works that zone. knifeHeight is the cutting height in mm, which has no
+// default: it must come from the device's work report.
+func zoneRouteNav(zoneHash int64, knifeHeight int32) (*pb.MctlNav, error) {
+	if knifeHeight <= 0 {
+		return nil, fmt.Errorf("the cutting height is not known yet; wait for the mower's work report")
+	}
+	return &pb.MctlNav{SubNavMsg: &pb.MctlNav_BidireReqconverPath{BidireReqconverPath: &pb.NavReqCoverPath{
+		Pver:                1,
+		JobMode:             routeJobMode,
+		EdgeMode:            routeEdgeMode,
+		KnifeHeight:         knifeHeight,
+		ChannelWidth:        routeChannelWidth,
+		UltraWave:           routeUltraWave,
+		ChannelMode:         routeChannelMode,
+		TowardIncludedAngle: routeTowardAngle,
+		Speed:               routeSpeed,
+		ZoneHashs:           []uint64{uint64(zoneHash)},
+	}}}, nil
+}
+
+// edgeMowNav mows the edge of the zone last routed (todev_edgecmd).
+func edgeMowNav() *pb.MctlNav {
+	return &pb.MctlNav{SubNavMsg: &pb.MctlNav_TodevEdgecmd{TodevEdgecmd: 1}}
+}
+
 func sendNav(s *cloudSession, nav *pb.MctlNav) error {
 	data, err := buildNav(nav)
 	if err != nil {
 		return err
 	}
-	_, err = s.gateway.SendCloudCommand(s.device.IotId, data)
-	return err
+	return s.send(data)
 }
 
 var rechargeCmd = &cobra.Command{
@@ -244,9 +478,12 @@ var leavePileCmd = &cobra.Command{
 }
 
 var (
-	moveLinear   int32
-	moveAngular  int32
-	moveDuration int
+	moveLinear      int32
+	moveAngular     int32
+	moveDuration    int
+	moveMap         string
+	moveFenceMargin float64
+	moveNoFence     bool
 )
 
 var moveCmd = &cobra.Command{
@@ -256,8 +493,25 @@ var moveCmd = &cobra.Command{
 Typical values:
   --linear  1000 = forward, -1000 = backward
   --angular 450  = right,   -450  = left
-  --duration in seconds.`,
+  --duration in seconds.
+
+With --map, motion is geofenced as in pilot: each command is checked against
+the map's mowing areas and no-go zones from the live position, slowed near
+the edge, and the move ends early if it would leave them. --no-geofence
+disables the check.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		var fence *geofence
+		if moveMap != "" && !moveNoFence {
+			m, err := LoadMap(moveMap)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			if fence = newGeofence(m, moveFenceMargin); fence == nil {
+				fmt.Println("Error: the map has no mowing areas to fence in; pass --no-geofence to drive anyway")
+				return
+			}
+		}
 		withSession(func(s *cloudSession) error {
 			// Position callback for live feedback.
 			var posMu sync.Mutex
@@ -275,23 +529,56 @@ Typical values:
 			stopPolling := startPolling(s)
 			defer stopPolling()
 
+			if fence != nil {
+				deadline := time.Now().Add(5 * time.Second)
+				for {
+					posMu.Lock()
+					n := posUpdates
+					posMu.Unlock()
+					if n > 0 {
+						break
+					}
+					if time.Now().After(deadline) {
+						return fmt.Errorf("geofence: no position report to check the move against")
+					}
+					time.Sleep(100 * time.Millisecond)
+				}
+			}
+
 			fmt.Printf("Driving linear=%d angular=%d for %ds...\n", moveLinear, moveAngular, moveDuration)
 			endTime := time.Now().Add(time.Duration(moveDuration) * time.Second)
 			ticker := time.NewTicker(200 * time.Millisecond)
 			defer ticker.Stop()
 			var lastPrint time.Time
+			var lastFenceMsg string
 
 			for time.Now().Before(endTime) {
-				if err := s.gateway.CheckOrRefreshSession(); err != nil {
+				if err := s.refresh(); err != nil {
 					fmt.Println("Session refresh error:", err)
 					break
 				}
-				data, err := mammotion.SendMotionControl(moveLinear, moveAngular)
+				linear := moveLinear
+				if fence != nil {
+					posMu.Lock()
+					// RealPos is in 0.1mm units and real_toward in 0.0001°.
+					x, y, heading := float64(lastX)/10000, float64(lastY)/10000, float64(lastAngle)/10000
+					posMu.Unlock()
+					var msg string
+					linear, msg = fence.Check(x, y, heading, moveLinear, moveAngular)
+					if msg != "" && msg != lastFenceMsg {
+						fmt.Println(" ", msg)
+					}
+					lastFenceMsg = msg
+					if linear == 0 && moveLinear != 0 {
+						break
+					}
+				}
+				data, err := mammotion.SendMotionControl(linear, moveAngular)
 				if err != nil {
 					fmt.Println("Build motion error:", err)
 					break
 				}
-				if _, err := s.gateway.SendCloudCommand(s.device.IotId, data); err != nil {
+				if err := s.send(data); err != nil {
 					fmt.Println("Send motion error:", err)
 					break
 				}
@@ -308,7 +595,7 @@ Typical values:
 			// Always send stop at the end.
 			stopData, err := mammotion.StopMotion()
 			if err == nil {
-				_, _ = s.gateway.SendCloudCommand(s.device.IotId, stopData)
+				s.send(stopData)
 			}
 			fmt.Println("Stop command sent.")
 			time.Sleep(1 * time.Second)
@@ -397,6 +684,9 @@ func init() {
 	moveCmd.Flags().Int32Var(&moveLinear, "linear", 0, "linear speed (-1000..1000)")
 	moveCmd.Flags().Int32Var(&moveAngular, "angular", 0, "angular speed (-450..450)")
 	moveCmd.Flags().IntVar(&moveDuration, "duration", 2, "seconds to drive")
+	moveCmd.Flags().StringVar(&moveMap, "map", "", "saved map to geofence the move against")
+	moveCmd.Flags().Float64Var(&moveFenceMargin, "fence-margin", 0.3, "geofence margin in metres")
+	moveCmd.Flags().BoolVar(&moveNoFence, "no-geofence", false, "ignore --map's geofence")
 
 	positionCmd.Flags().IntVar(&positionDuration, "duration", 15, "seconds to listen for position updates")
 
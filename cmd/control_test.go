@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"mammo/aliyuniot"
+	"mammo/mammotion"
+)
+
+// refreshingTransport is a loopback with a cloud-style Refresh.
+type refreshingTransport struct {
+	*mammotion.LoopbackTransport
+	refreshes atomic.Int32
+}
+
+func (t *refreshingTransport) Refresh() error {
+	t.refreshes.Add(1)
+	return nil
+}
+
+// syncBuffer is a bytes.Buffer safe to log into from another goroutine.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestPollingRefreshesAndReportsFailures(t *testing.T) {
+	defer func(i, r time.Duration) { pollInterval, pollRefreshEvery = i, r }(pollInterval, pollRefreshEvery)
+	pollInterval, pollRefreshEvery = 5*time.Millisecond, 20*time.Millisecond
+	defer log.SetOutput(log.Writer())
+	var logged syncBuffer
+	log.SetOutput(&logged)
+
+	var failing atomic.Bool
+	tr := &refreshingTransport{LoopbackTransport: mammotion.NewLoopbackTransport(func([]byte) error {
+		if failing.Load() {
+			return errors.New("token expired")
+		}
+		return nil
+	})}
+	s := &cloudSession{transport: tr, device: &aliyuniot.Device{DeviceName: "Luba-SIM"}}
+	waitFor := func(what string, ok func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !ok() {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s; log:\n%s", what, logged.String())
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	stop := startPolling(s)
+	defer stop()
+	waitFor("session never refreshed", func() bool { return tr.refreshes.Load() >= 2 })
+
+	failing.Store(true)
+	waitFor("send failure not logged", func() bool { return strings.Contains(logged.String(), "token expired") })
+	sent := len(tr.Sent())
+	waitFor("polling stopped after a failure", func() bool { return len(tr.Sent()) > sent+3 })
+	if n := strings.Count(logged.String(), "token expired"); n != 1 {
+		t.Errorf("failure logged %d times, want once", n)
+	}
+	failing.Store(false)
+	waitFor("recovery not logged", func() bool { return strings.Contains(logged.String(), "polling Luba-SIM: recovered") })
+}
@@ -0,0 +1,591 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// maxTrailGap is the longest step between consecutive trail points that is
+// treated as continuous mowing. Longer jumps are position glitches (RTK
+// re-convergence) or gaps in recording and would paint false coverage.
+const maxTrailGap = 3.0
+
+// CoverageGrid rasterises the map's mowing areas into square cells and
+// counts, per cell, how many recorded sessions passed the blade over it.
+type CoverageGrid struct {
+	MinX, MinY float64
+	Cell       float64 // cell edge in meters
+	W, H       int
+	zone       []int // index into Zones for mowable cells, -1 otherwise
+	passes     []int // sessions that covered the cell
+	Zones      []coverageZone
+}
+
+type coverageZone struct {
+	Hash int64
+	Name string
+}
+
+// NewCoverageGrid builds a grid over the map's areas. Cells inside an area
+// and outside every obstacle are mowable.
+func NewCoverageGrid(m *MowerMap, cell float64) (*CoverageGrid, error) {
+	if cell <= 0 {
+		return nil, fmt.Errorf("cell size must be positive")
+	}
+	var areas, obstacles []MapElement
+	for _, el := range m.Elements {
+		switch {
+		case el.Type == MapTypeArea && len(el.Points) >= 3:
+			areas = append(areas, el)
+		case el.Type == MapTypeObstacle && len(el.Points) >= 3:
+			obstacles = append(obstacles, el)
+		}
+	}
+	if len(areas) == 0 {
+		return nil, fmt.Errorf("map has no mowing areas")
+	}
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, a := range areas {
+		for _, p := range a.Points {
+			minX, maxX = math.Min(minX, p.X), math.Max(maxX, p.X)
+			minY, maxY = math.Min(minY, p.Y), math.Max(maxY, p.Y)
+		}
+	}
+	g := &CoverageGrid{
+		MinX: minX,
+		MinY: minY,
+		Cell: cell,
+		W:    int(math.Ceil((maxX-minX)/cell)) + 1,
+		H:    int(math.Ceil((maxY-minY)/cell)) + 1,
+	}
+	if g.W*g.H > 50_000_000 {
+		return nil, fmt.Errorf("grid of %dx%d cells is too large; use a larger blade width", g.W, g.H)
+	}
+	for i := range areas {
+		g.Zones = append(g.Zones, coverageZone{Hash: areas[i].Hash, Name: m.ElementLabel(&areas[i])})
+	}
+	g.zone = make([]int, g.W*g.H)
+	g.passes = make([]int, g.W*g.H)
+	for j := 0; j < g.H; j++ {
+		for i := 0; i < g.W; i++ {
+			x, y := g.cellCenter(i, j)
+			z := -1
+			for k, a := range areas {
+				if pointInPolygon(x, y, a.Points) {
+					z = k
+					break
+				}
+			}
+			if z >= 0 {
+				for _, ob := range obstacles {
+					if pointInPolygon(x, y, ob.Points) {
+						z = -1
+						break
+					}
+				}
+			}
+			g.zone[j*g.W+i] = z
+		}
+	}
+	return g, nil
+}
+
+func (g *CoverageGrid) cellCenter(i, j int) (float64, float64) {
+	return g.MinX + (float64(i)+0.5)*g.Cell, g.MinY + (float64(j)+0.5)*g.Cell
+}
+
+// cellAt returns the cell containing a world point, ok=false outside the grid.
+func (g *CoverageGrid) cellAt(x, y float64) (int, int, bool) {
+	i := int(math.Floor((x - g.MinX) / g.Cell))
+	j := int(math.Floor((y - g.MinY) / g.Cell))
+	return i, j, i >= 0 && j >= 0 && i < g.W && j < g.H
+}
+
+// AddTrail marks every cell whose centre passed within half a blade width of
+// the trail. A cell counts once per trail however often that session crossed
+// it, so the count is "sessions that mowed here".
+func (g *CoverageGrid) AddTrail(t *Trail, bladeWidth float64) {
+	r := bladeWidth / 2
+	seen := make(map[int]bool)
+	mark := func(a, b TrailPoint) {
+		i0, j0, _ := g.cellAt(math.Min(a.X, b.X)-r, math.Min(a.Y, b.Y)-r)
+		i1, j1, _ := g.cellAt(math.Max(a.X, b.X)+r, math.Max(a.Y, b.Y)+r)
+		for j := max(j0, 0); j <= min(j1, g.H-1); j++ {
+			for i := max(i0, 0); i <= min(i1, g.W-1); i++ {
+				idx := j*g.W + i
+				if seen[idx] {
+					continue
+				}
+				x, y := g.cellCenter(i, j)
+				if distToSegment(x, y, a.X, a.Y, b.X, b.Y) <= r {
+					seen[idx] = true
+				}
+			}
+		}
+	}
+	for k := range t.Points {
+		if k == 0 {
+			mark(t.Points[0], t.Points[0])
+			continue
+		}
+		a, b := t.Points[k-1], t.Points[k]
+		if math.Hypot(b.X-a.X, b.Y-a.Y) > maxTrailGap {
+			mark(b, b)
+			continue
+		}
+		mark(a, b)
+	}
+	for idx := range seen {
+		g.passes[idx]++
+	}
+}
+
+// ZoneCoverage is the covered share of one mowing area.
+type ZoneCoverage struct {
+	Name       string
+	Hash       int64
+	AreaM2     float64
+	CoveredM2  float64
+	PercentCov float64
+}
+
+// ZoneStats reports coverage per mowing area.
+func (g *CoverageGrid) ZoneStats() []ZoneCoverage {
+	total := make([]int, len(g.Zones))
+	covered := make([]int, len(g.Zones))
+	for idx, z := range g.zone {
+		if z < 0 {
+			continue
+		}
+		total[z]++
+		if g.passes[idx] > 0 {
+			covered[z]++
+		}
+	}
+	cellArea := g.Cell * g.Cell
+	stats := make([]ZoneCoverage, len(g.Zones))
+	for k, z := range g.Zones {
+		stats[k] = ZoneCoverage{
+			Name:      z.Name,
+			Hash:      z.Hash,
+			AreaM2:    float64(total[k]) * cellArea,
+			CoveredM2: float64(covered[k]) * cellArea,
+		}
+		if total[k] > 0 {
+			stats[k].PercentCov = 100 * float64(covered[k]) / float64(total[k])
+		}
+	}
+	return stats
+}
+
+// MissedStrip is a connected patch of mowable cells no session covered.
+type MissedStrip struct {
+	Zone             string
+	AreaM2           float64
+	CenterX, CenterY float64
+	LengthM, WidthM  float64 // bounding box extent, longer side first
+}
+
+// MissedStrips finds uncovered patches of at least minArea square metres,
+// largest first.
+func (g *CoverageGrid) MissedStrips(minArea float64) []MissedStrip {
+	visited := make([]bool, len(g.zone))
+	var strips []MissedStrip
+	cellArea := g.Cell * g.Cell
+	for start := range g.zone {
+		if visited[start] || g.zone[start] < 0 || g.passes[start] > 0 {
+			continue
+		}
+		// flood fill (4-connected) over uncovered cells of the same zone
+		z := g.zone[start]
+		stack := []int{start}
+		visited[start] = true
+		n := 0
+		var sx, sy float64
+		minI, minJ, maxI, maxJ := g.W, g.H, -1, -1
+		for len(stack) > 0 {
+			idx := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			i, j := idx%g.W, idx/g.W
+			n++
+			x, y := g.cellCenter(i, j)
+			sx += x
+			sy += y
+			minI, maxI = min(minI, i), max(maxI, i)
+			minJ, maxJ = min(minJ, j), max(maxJ, j)
+			for _, d := range [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+				ni, nj := i+d[0], j+d[1]
+				if ni < 0 || nj < 0 || ni >= g.W || nj >= g.H {
+					continue
+				}
+				nidx := nj*g.W + ni
+				if visited[nidx] || g.zone[nidx] != z || g.passes[nidx] > 0 {
+					continue
+				}
+				visited[nidx] = true
+				stack = append(stack, nidx)
+			}
+		}
+		area := float64(n) * cellArea
+		if area < minArea {
+			continue
+		}
+		l := float64(maxI-minI+1) * g.Cell
+		w := float64(maxJ-minJ+1) * g.Cell
+		if w > l {
+			l, w = w, l
+		}
+		strips = append(strips, MissedStrip{
+			Zone:    g.Zones[z].Name,
+			AreaM2:  area,
+			CenterX: sx / float64(n),
+			CenterY: sy / float64(n),
+			LengthM: l,
+			WidthM:  w,
+		})
+	}
+	sort.Slice(strips, func(a, b int) bool { return strips[a].AreaM2 > strips[b].AreaM2 })
+	return strips
+}
+
+// passesAt returns the pass count and whether the point is mowable.
+func (g *CoverageGrid) passesAt(x, y float64) (int, bool) {
+	i, j, ok := g.cellAt(x, y)
+	if !ok {
+		return 0, false
+	}
+	idx := j*g.W + i
+	if g.zone[idx] < 0 {
+		return 0, false
+	}
+	return g.passes[idx], true
+}
+
+// Heatmap colors (ANSI 256), by number of sessions that covered a cell.
+const (
+	colMissed   = 160 // red — never covered
+	colCovered1 = 22  // dark green — one session
+	colCovered2 = 28
+	colCovered3 = 46 // bright green — many sessions
+)
+
+func heatColor(passes int) int {
+	switch {
+	case passes == 0:
+		return colMissed
+	case passes == 1:
+		return colCovered1
+	case passes <= 3:
+		return colCovered2
+	default:
+		return colCovered3
+	}
+}
+
+// DrawCoverage shades the canvas by pass count. Missed cells are plotted last
+// so a braille cell that mixes covered and missed ground shows as missed.
+func DrawCoverage(c *Canvas, v *Viewport, g *CoverageGrid) {
+	var missed [][2]int
+	for py := 0; py < c.PixelH(); py++ {
+		for px := 0; px < c.PixelW(); px++ {
+			x, y := v.ToWorld(px, py)
+			n, ok := g.passesAt(x, y)
+			if !ok {
+				continue
+			}
+			if n == 0 {
+				missed = append(missed, [2]int{px, py})
+				continue
+			}
+			c.SetDot(px, py, heatColor(n))
+		}
+	}
+	for _, p := range missed {
+		c.SetDot(p[0], p[1], colMissed)
+	}
+}
+
+// coverageRGBA mirrors heatColor for the PNG export.
+func coverageRGBA(passes int) color.RGBA {
+	switch {
+	case passes == 0:
+		return color.RGBA{215, 38, 38, 255}
+	case passes == 1:
+		return color.RGBA{161, 217, 155, 255}
+	case passes <= 3:
+		return color.RGBA{65, 171, 93, 255}
+	default:
+		return color.RGBA{0, 109, 44, 255}
+	}
+}
+
+// WriteCoveragePNG renders the heatmap at scale pixels per cell with area and
+// obstacle outlines, north up.
+func WriteCoveragePNG(path string, g *CoverageGrid, m *MowerMap, scale int) error {
+	if scale < 1 {
+		scale = 1
+	}
+	img := image.NewRGBA(image.Rect(0, 0, g.W*scale, g.H*scale))
+	bg := color.RGBA{245, 245, 245, 255}
+	for j := 0; j < g.H; j++ {
+		for i := 0; i < g.W; i++ {
+			col := bg
+			if idx := j*g.W + i; g.zone[idx] >= 0 {
+				col = coverageRGBA(g.passes[idx])
+			}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetRGBA(i*scale+dx, (g.H-1-j)*scale+(scale-1-dy), col)
+				}
+			}
+		}
+	}
+	toImg := func(p MapPoint) (int, int) {
+		x := (p.X - g.MinX) / g.Cell * float64(scale)
+		y := float64(g.H*scale) - (p.Y-g.MinY)/g.Cell*float64(scale)
+		return int(math.Round(x)), int(math.Round(y))
+	}
+	for _, el := range m.Elements {
+		if !elementClosed(el.Type) || len(el.Points) < 2 {
+			continue
+		}
+		col := color.RGBA{40, 40, 40, 255}
+		if el.Type == MapTypeObstacle {
+			col = color.RGBA{120, 0, 0, 255}
+		}
+		for k := range el.Points {
+			x0, y0 := toImg(el.Points[k])
+			x1, y1 := toImg(el.Points[(k+1)%len(el.Points)])
+			imageLine(img, x0, y0, x1, y1, col)
+		}
+	}
+	// Annotations as small blue crosses.
+	for _, n := range m.Notes {
+		x, y := toImg(MapPoint{X: n.X, Y: n.Y})
+		noteCol := color.RGBA{30, 110, 220, 255}
+		imageLine(img, x-3, y, x+3, y, noteCol)
+		imageLine(img, x, y-3, x, y+3, noteCol)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// imageLine draws a Bresenham line, clipped to the image.
+func imageLine(img *image.RGBA, x0, y0, x1, y1 int, col color.RGBA) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	err := dx + dy
+	for {
+		if image.Pt(x0, y0).In(img.Rect) {
+			img.SetRGBA(x0, y0, col)
+		}
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// pointInPolygon is the even-odd ray casting test.
+func pointInPolygon(x, y float64, poly []MapPoint) bool {
+	in := false
+	for i, j := 0, len(poly)-1; i < len(poly); j, i = i, i+1 {
+		a, b := poly[i], poly[j]
+		if (a.Y > y) != (b.Y > y) && x < (b.X-a.X)*(y-a.Y)/(b.Y-a.Y)+a.X {
+			in = !in
+		}
+	}
+	return in
+}
+
+// distToSegment is the distance from (px,py) to segment a-b.
+func distToSegment(px, py, ax, ay, bx, by float64) float64 {
+	dx, dy := bx-ax, by-ay
+	l2 := dx*dx + dy*dy
+	if l2 == 0 {
+		return math.Hypot(px-ax, py-ay)
+	}
+	t := ((px-ax)*dx + (py-ay)*dy) / l2
+	t = math.Max(0, math.Min(1, t))
+	return math.Hypot(px-(ax+t*dx), py-(ay+t*dy))
+}
+
+var (
+	coverageMap        string
+	coverageTrailDir   string
+	coverageFrom       string
+	coverageTo         string
+	coverageBladeWidth float64
+	coveragePNG        string
+	coverageMinStrip   float64
+	coverageDevice     string
+)
+
+// parseDay parses YYYY-MM-DD in local time; empty gives the zero time.
+func parseDay(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation("2006-01-02", s, time.Local)
+}
+
+var coverageCmd = &cobra.Command{
+	Use:   "coverage",
+	Short: "Heatmap of recorded trails over the map: zone coverage and missed strips",
+	Long: `Rasterises every trail pilot recorded (see pilot --trail-dir) in a date
+range over a saved map at blade-width resolution, then reports the percent
+of each zone covered, lists the largest patches no session reached, and
+renders a heatmap in the terminal (and optionally as a PNG).
+
+Only the trails of the map's mower are used; --device picks another mower's,
+or --device all every recorded mower's.
+
+Heatmap colours: red = never mowed, dark → bright green = mowed in one → many
+sessions. Missed patches that show up across many sessions are the spots the
+mower consistently skips.
+
+  mammo coverage --map lawn.json --from 2026-10-01 --to 2026-10-18 --png cov.png`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if coverageMap == "" {
+			fmt.Println("Error: --map is required")
+			os.Exit(1)
+		}
+		m, err := LoadMap(coverageMap)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		from, err := parseDay(coverageFrom)
+		if err != nil {
+			fmt.Println("Error: --from:", err)
+			os.Exit(1)
+		}
+		to, err := parseDay(coverageTo)
+		if err != nil {
+			fmt.Println("Error: --to:", err)
+			os.Exit(1)
+		}
+		if !to.IsZero() {
+			to = to.AddDate(0, 0, 1) // --to is inclusive
+		}
+		device := coverageDevice
+		switch device {
+		case "":
+			device = m.Device
+		case "all":
+			device = ""
+		}
+		trails, err := LoadTrails(coverageTrailDir, device, from, to)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		if len(trails) == 0 {
+			if device != "" {
+				fmt.Printf("No trails of %s found in %s for that range (--device all includes every mower).\n", device, coverageTrailDir)
+			} else {
+				fmt.Printf("No trails found in %s for that range.\n", coverageTrailDir)
+			}
+			os.Exit(1)
+		}
+
+		g, err := NewCoverageGrid(m, coverageBladeWidth)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		points := 0
+		for _, t := range trails {
+			g.AddTrail(t, coverageBladeWidth)
+			points += len(t.Points)
+		}
+
+		width, height := terminalSize()
+		canvas := NewCanvas(width, height-8)
+		minX, minY, maxX, maxY, _ := m.Bounds()
+		vp := NewViewport(minX, minY, maxX, maxY, canvas.PixelW(), canvas.PixelH(), 0.05)
+		DrawCoverage(canvas, vp, g)
+		DrawMap(canvas, vp, m, map[int32]bool{MapTypeSvg: true})
+		for _, line := range canvas.Render() {
+			fmt.Println(line)
+		}
+
+		fmt.Printf("%d session(s), %d points, blade %.2fm\n", len(trails), points, coverageBladeWidth)
+		for _, z := range g.ZoneStats() {
+			name := z.Name
+			if name == "" {
+				name = fmt.Sprintf("zone %d", z.Hash)
+			}
+			fmt.Printf("  %-20s %6.1f%% of %.0fm² covered\n", name, z.PercentCov, z.AreaM2)
+		}
+		strips := g.MissedStrips(coverageMinStrip)
+		if len(strips) > 0 {
+			fmt.Println("Largest missed patches:")
+			for i, s := range strips {
+				if i == 5 {
+					fmt.Printf("  … and %d more\n", len(strips)-5)
+					break
+				}
+				fmt.Printf("  %5.1fm² at %.1f, %.1f (%.1fm x %.1fm) in %s\n",
+					s.AreaM2, s.CenterX, s.CenterY, s.LengthM, s.WidthM, s.Zone)
+			}
+		}
+		if coveragePNG != "" {
+			scale := int(math.Max(1, math.Round(2000/float64(max(g.W, g.H)))))
+			if err := WriteCoveragePNG(coveragePNG, g, m, scale); err != nil {
+				fmt.Println("Error writing PNG:", err)
+				os.Exit(1)
+			}
+			fmt.Println("Heatmap written to", coveragePNG)
+		}
+	},
+}
+
+func init() {
+	coverageCmd.Flags().StringVar(&coverageMap, "map", "", "saved map file (required)")
+	coverageCmd.Flags().StringVar(&coverageTrailDir, "trails", defaultTrailDir(), "directory of recorded trails")
+	coverageCmd.Flags().StringVar(&coverageFrom, "from", "", "first day to include (YYYY-MM-DD)")
+	coverageCmd.Flags().StringVar(&coverageTo, "to", "", "last day to include (YYYY-MM-DD)")
+	coverageCmd.Flags().Float64Var(&coverageBladeWidth, "blade-width", 0.4, "cutting width in meters (also the grid resolution)")
+	coverageCmd.Flags().StringVar(&coveragePNG, "png", "", "also write the heatmap to a PNG file")
+	coverageCmd.Flags().StringVar(&coverageDevice, "device", "", "mower whose trails to use, or all (default the map's device)")
+	coverageCmd.Flags().Float64Var(&coverageMinStrip, "min-strip", 0.5, "smallest missed patch to report, in m²")
+	rootCmd.AddCommand(coverageCmd)
+}
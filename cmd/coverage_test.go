@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"testing"
+)
+
+func coverageSquare() *MowerMap {
+	return &MowerMap{Elements: []MapElement{
+		{Hash: 1, Type: MapTypeArea, Points: []MapPoint{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}, {X: 0, Y: 10}}},
+	}}
+}
+
+func TestCoverageMarksBladeWidth(t *testing.T) {
+	g, err := NewCoverageGrid(coverageSquare(), 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// One pass along y=5 with a 1m blade covers the band 4.5-5.5.
+	trail := &Trail{}
+	for x := 0.0; x <= 10; x++ {
+		trail.Points = append(trail.Points, TrailPoint{X: x, Y: 5})
+	}
+	g.AddTrail(trail, 1)
+	g.AddTrail(trail, 1)
+	if n, _ := g.passesAt(5, 5.2); n != 2 {
+		t.Errorf("cell on the pass should count two sessions, got %d", n)
+	}
+	if n, _ := g.passesAt(5, 6.5); n != 0 {
+		t.Errorf("cell outside the blade should be uncovered, got %d", n)
+	}
+	stats := g.ZoneStats()
+	if len(stats) != 1 || stats[0].PercentCov < 5 || stats[0].PercentCov > 15 {
+		t.Errorf("expected ~10%% coverage, got %+v", stats)
+	}
+}
+
+func TestCoverageSkipsPositionJumps(t *testing.T) {
+	g, err := NewCoverageGrid(coverageSquare(), 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.AddTrail(&Trail{Points: []TrailPoint{{X: 1, Y: 1}, {X: 9, Y: 9}}}, 0.5)
+	if n, _ := g.passesAt(5, 5); n != 0 {
+		t.Error("a jump longer than maxTrailGap must not paint coverage")
+	}
+}
+
+func TestMissedStripsLargestFirst(t *testing.T) {
+	g, err := NewCoverageGrid(coverageSquare(), 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Mow a wall across x=3, splitting the lawn into a 3m and a 7m strip.
+	g.AddTrail(&Trail{Points: []TrailPoint{{X: 3.25, Y: 0}, {X: 3.25, Y: 2}, {X: 3.25, Y: 4}, {X: 3.25, Y: 6}, {X: 3.25, Y: 8}, {X: 3.25, Y: 10}}}, 0.5)
+	strips := g.MissedStrips(0.5)
+	if len(strips) != 2 {
+		t.Fatalf("expected 2 missed strips, got %d", len(strips))
+	}
+	if strips[0].AreaM2 <= strips[1].AreaM2 || strips[0].CenterX < 3.25 {
+		t.Errorf("largest strip (east side) should come first: %+v", strips)
+	}
+}
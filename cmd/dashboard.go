@@ -0,0 +1,372 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"mammo/mammotion"
+)
+
+// Dashboard panels, stacked top to bottom beside the pilot map.
+const (
+	panelRTK = iota
+	panelBattery
+	panelWork
+	panelEvents
+	panelLink
+	panelCount
+)
+
+var panelNames = [panelCount]string{"rtk", "battery", "work", "events", "link"}
+
+const (
+	panelDefaultWidth = 34
+	panelMinWidth     = 24
+	panelMaxWidth     = 64
+	panelMinMapWidth  = 30 // narrower terminals hide the panels
+
+	batteryHistoryLen = 240         // samples kept for the sparkline
+	batterySampleGap  = time.Minute // a sample is kept at least this often
+	eventLogLen       = 100
+)
+
+type batterySample struct {
+	t   time.Time
+	pct int
+}
+
+type dashEvent struct {
+	t    time.Time
+	text string
+	warn bool
+}
+
+// dashboard is the pilot's side-panel state: which panels are shown, and
+// what they show that the map and header don't keep.
+type dashboard struct {
+	show    [panelCount]bool
+	hidden  [panelCount]bool // what tab brings back
+	width   int
+	started time.Time
+
+	rtk       *mammotion.RtkData
+	rtkAt     time.Time
+	work      *mammotion.WorkData
+	sysStatus int32
+	battery   []batterySample
+	events    []dashEvent
+}
+
+func newDashboard(panels []string) (dashboard, error) {
+	d := dashboard{width: panelDefaultWidth, started: time.Now()}
+	for _, name := range panels {
+		if name == "all" {
+			for i := range d.show {
+				d.show[i] = true
+			}
+			continue
+		}
+		i := panelIndex(name)
+		if i < 0 {
+			return d, fmt.Errorf("unknown panel %q (want %s or all)", name, strings.Join(panelNames[:], ", "))
+		}
+		d.show[i] = true
+	}
+	return d, nil
+}
+
+func panelIndex(name string) int {
+	for i, n := range panelNames {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (d *dashboard) any() bool {
+	for _, on := range d.show {
+		if on {
+			return true
+		}
+	}
+	return false
+}
+
+// Resize widens (or with a negative delta narrows) the panel column.
+func (d *dashboard) Resize(delta int) {
+	d.width = max(panelMinWidth, min(panelMaxWidth, d.width+delta))
+}
+
+// AddBattery records a battery reading, keeping one sample per change and at
+// least one a minute.
+func (d *dashboard) AddBattery(t time.Time, pct int) {
+	if n := len(d.battery); n > 0 && d.battery[n-1].pct == pct && t.Sub(d.battery[n-1].t) < batterySampleGap {
+		return
+	}
+	d.battery = append(d.battery, batterySample{t, pct})
+	if len(d.battery) > batteryHistoryLen {
+		d.battery = d.battery[len(d.battery)-batteryHistoryLen:]
+	}
+}
+
+// Log adds a line to the events panel.
+func (d *dashboard) Log(t time.Time, text string, warn bool) {
+	d.events = append(d.events, dashEvent{t, text, warn})
+	if len(d.events) > eventLogLen {
+		d.events = d.events[len(d.events)-eventLogLen:]
+	}
+}
+
+// Status records a sys_status report, logging changes.
+func (d *dashboard) Status(t time.Time, sysStatus int32) {
+	if sysStatus != d.sysStatus {
+		d.Log(t, "status: "+sysStatusLabel(sysStatus), false)
+	}
+	d.sysStatus = sysStatus
+}
+
+func sysStatusLabel(s int32) string {
+	switch s {
+	case sysStatusReady:
+		return "ready"
+	case sysStatusWorking:
+		return "mowing"
+	case sysStatusReturning:
+		return "returning"
+	case sysStatusCharging:
+		return "charging"
+	case sysStatusPaused:
+		return "paused"
+	}
+	return fmt.Sprintf("status %d", s)
+}
+
+// sparkline draws values (0-100) as block characters, one per value.
+func sparkline(values []int) string {
+	const bars = "▁▂▃▄▅▆▇█"
+	runes := []rune(bars)
+	var b strings.Builder
+	for _, v := range values {
+		i := v * (len(runes) - 1) / 100
+		b.WriteRune(runes[max(0, min(len(runes)-1, i))])
+	}
+	return b.String()
+}
+
+// batteryRate is the battery's change in percent per hour over the history,
+// or false when it spans too little time to say.
+func batteryRate(samples []batterySample) (float64, bool) {
+	if len(samples) < 2 {
+		return 0, false
+	}
+	first, last := samples[0], samples[len(samples)-1]
+	h := last.t.Sub(first.t).Hours()
+	if h < 5.0/60 {
+		return 0, false
+	}
+	return float64(last.pct-first.pct) / h, true
+}
+
+// progressBar is a bar of width cells, pct full.
+func progressBar(pct, width int) string {
+	full := max(0, min(width, pct*width/100))
+	return strings.Repeat("█", full) + strings.Repeat("░", width-full)
+}
+
+// fitLine truncates s to width cells and pads it to exactly width.
+func fitLine(s string, width int) string {
+	r := []rune(s)
+	if len(r) > width {
+		if width < 1 {
+			return ""
+		}
+		return string(r[:width-1]) + "…"
+	}
+	return s + strings.Repeat(" ", width-len(r))
+}
+
+// currentZone names the mowing area the mower is in, or "".
+func currentZone(mm *MowerMap, x, y float64) string {
+	if mm == nil {
+		return ""
+	}
+	for i := range mm.Elements {
+		el := &mm.Elements[i]
+		if el.Type == MapTypeArea && len(el.Points) >= 3 && pointInPolygon(x, y, el.Points) {
+			if name := mm.ElementLabel(el); name != "" {
+				return name
+			}
+			return fmt.Sprintf("zone %d", el.Hash)
+		}
+	}
+	return ""
+}
+
+// panelLine is one rendered panel row; warn lines are drawn in red.
+type panelLine struct {
+	text string
+	warn bool
+}
+
+// panelWriter builds one panel's rows at a fixed width.
+type panelWriter struct {
+	width int
+	lines []panelLine
+}
+
+func (p *panelWriter) title(name string) {
+	t := "─ " + name + " "
+	p.lines = append(p.lines, panelLine{text: fitLine(t+strings.Repeat("─", max(0, p.width-len([]rune(t)))), p.width)})
+}
+
+func (p *panelWriter) add(warn bool, format string, args ...any) {
+	p.lines = append(p.lines, panelLine{text: " " + fitLine(fmt.Sprintf(format, args...), p.width-1), warn: warn})
+}
+
+// panels renders the shown panels into exactly height rows of the column's
+// width. Events take whatever rows the others leave, and link stats sit at
+// the bottom.
+func (m pilotModel) panels(height int) []panelLine {
+	d := &m.dash
+	top := &panelWriter{width: d.width}
+	bottom := &panelWriter{width: d.width}
+
+	if m.zoneBrowse {
+		m.zonePanel(top, max(3, height/2-3))
+	}
+
+	if d.show[panelRTK] {
+		top.title("RTK")
+		top.add(m.posType != 4, "%s", rtkLabel(m.posType))
+		if r := d.rtk; r != nil {
+			top.add(false, "sats %d  L2 %d  co-view %d", r.GpsStars, r.L2Stars, r.CoViewStars)
+			top.add(false, "age %d  std lat %d lon %d", r.Age, r.LatStd, r.LonStd)
+			top.add(false, "status %d  level %d  %s ago", r.Status, r.PosLevel, time.Since(d.rtkAt).Round(time.Second))
+		} else {
+			top.add(false, "no rpt_rtk yet")
+		}
+	}
+
+	if d.show[panelBattery] {
+		top.title("Battery")
+		label := fmt.Sprintf("%d%%", m.battery)
+		if m.charging {
+			label += " charging"
+		}
+		if rate, ok := batteryRate(d.battery); ok {
+			label += fmt.Sprintf("  %+.0f%%/h", rate)
+		}
+		top.add(m.batteryLow(), "%s", label)
+		vals := make([]int, 0, len(d.battery))
+		for _, s := range d.battery {
+			vals = append(vals, s.pct)
+		}
+		if len(vals) > d.width-1 {
+			vals = vals[len(vals)-(d.width-1):]
+		}
+		top.add(false, "%s", sparkline(vals))
+	}
+
+	if d.show[panelWork] {
+		top.title("Work")
+		if d.sysStatus != 0 {
+			top.add(false, "%s", sysStatusLabel(d.sysStatus))
+		}
+		if zone := currentZone(m.mowerMap, m.posX, m.posY); zone != "" && m.posValid {
+			top.add(false, "in %s", zone)
+		}
+		if w := d.work; w != nil && (w.Area != 0 || w.Progress != 0) {
+			pct := int(w.Area >> 16)
+			top.add(false, "%s %d%%", progressBar(pct, max(4, d.width-7)), pct)
+			top.add(false, "%d m²  %d/%d min  knife %dmm", w.Area&0xffff, w.Progress>>16, w.Progress&0xffff, w.KnifeHeight)
+		} else {
+			top.add(false, "no job")
+		}
+		if m.breakPoint != nil {
+			top.add(false, "resume point %.1f, %.1f", m.breakPoint.X, m.breakPoint.Y)
+		}
+	}
+
+	if d.show[panelLink] {
+		bottom.title("Link")
+		h := m.motion.Health()
+		bottom.add(false, "%s", linkLabel(h))
+		if h.Tripped != "" {
+			bottom.add(true, "watchdog: %s", h.Tripped)
+		}
+		up := time.Since(d.started)
+		bottom.add(false, "positions %d (%.1f/s)", m.posUpdates, float64(m.posUpdates)/max(up.Seconds(), 1))
+		if st, ok := m.session.transport.(interface{ Stats() mammotion.CloudStats }); ok {
+			c := st.Stats()
+			bottom.add(c.MessageErrors > 0, "mqtt msgs %d  bad %d", c.Messages, c.MessageErrors)
+			bottom.add(c.CommandErrors > 0, "commands %d  failed %d", c.Commands, c.CommandErrors)
+			bottom.add(c.Disconnects > 0, "connects %d  drops %d", c.Connects, c.Disconnects)
+		}
+		bottom.add(false, "up %s", up.Round(time.Second))
+	}
+
+	if d.show[panelEvents] {
+		top.title("Events")
+		room := height - len(top.lines) - len(bottom.lines)
+		events := d.events
+		if len(events) > room {
+			events = events[len(events)-max(0, room):]
+		}
+		if len(events) == 0 {
+			top.add(false, "none yet")
+		}
+		for _, ev := range events {
+			top.add(ev.warn, "%s %s", ev.t.Format("15:04"), ev.text)
+		}
+	}
+
+	out := top.lines
+	for len(out)+len(bottom.lines) < height {
+		out = append(out, panelLine{text: strings.Repeat(" ", d.width)})
+	}
+	out = append(out, bottom.lines...)
+	if len(out) > height {
+		out = out[:height]
+	}
+	return out
+}
+
+// panelWidth is the columns the panels take from the map, 0 when hidden.
+func (m pilotModel) panelWidth() int {
+	if !m.dash.any() && !m.zoneBrowse || m.width-m.dash.width-1 < panelMinMapWidth {
+		return 0
+	}
+	return m.dash.width + 1 // a separator column
+}
+
+// panelKey handles the panel keys, reporting whether key was one.
+func (m *pilotModel) panelKey(key string) bool {
+	if m.dash.width == 0 {
+		m.dash.width = panelDefaultWidth
+	}
+	switch key {
+	case "1", "2", "3", "4", "5":
+		i := int(key[0] - '1')
+		m.dash.show[i] = !m.dash.show[i]
+	case "tab":
+		if m.dash.any() {
+			m.dash.hidden = m.dash.show
+			m.dash.show = [panelCount]bool{}
+		} else if m.dash.hidden != ([panelCount]bool{}) {
+			m.dash.show = m.dash.hidden
+		} else {
+			for i := range m.dash.show {
+				m.dash.show[i] = true
+			}
+		}
+	case "<", ",":
+		m.dash.Resize(-4)
+	case ">", ".":
+		m.dash.Resize(4)
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"mammo/mammotion"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestPilotDashboard(t *testing.T) {
+	if _, err := newDashboard([]string{"rtk", "bogus"}); err == nil {
+		t.Error("unknown panel accepted")
+	}
+	if got := sparkline([]int{0, 50, 100}); got != "▁▄█" {
+		t.Errorf("sparkline = %q", got)
+	}
+
+	dash, err := newDashboard([]string{"all"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := newSimSession(simLawn())
+	mc := &motionController{session: s, notify: func(string) {}}
+	var model tea.Model = pilotModel{session: s, motion: mc, speed: 400, turnRate: 450, minBattery: 15, zoom: 1, dash: dash}
+	now := time.Now()
+	for _, msg := range []tea.Msg{
+		tea.WindowSizeMsg{Width: 120, Height: 40},
+		pilotMapMsg(simLawn()),
+		pilotPosMsg{x: 12, y: 13, heading: 0, posType: 4},
+		pilotRtkMsg(&mammotion.RtkData{Status: 4, GpsStars: 30, L2Stars: 24, CoViewStars: 22, Age: 1, LatStd: 12, LonStd: 15}),
+		pilotBatteryMsg(80),
+		pilotDevStatusMsg{sysStatus: sysStatusWorking},
+		pilotWorkMsg(&mammotion.WorkData{Area: 42<<16 | 120, Progress: 10<<16 | 30}),
+		pilotEventMsg(notifyEvent{Kind: eventLifted, Device: "Luba-Sim", Message: "Luba-Sim was lifted or locked (lock_state 1)", Time: now}),
+	} {
+		model, _ = model.Update(msg)
+	}
+	view := model.View()
+	for _, want := range []string{"sats 30  L2 24", "std lat 12 lon 15", "80%", "in Front", "42%", "120 m²  10/30 min", "status: mowing", "was lifted or locked", "positions 1"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("dashboard is missing %q", want)
+		}
+	}
+	if m := model.(pilotModel); m.panelWidth() != panelDefaultWidth+1 {
+		t.Errorf("panel width %d", m.panelWidth())
+	}
+
+	// The map gives way to the panels; narrower panels give it back.
+	c, _ := model.(pilotModel).mapView()
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("<")})
+	if c2, _ := model.(pilotModel).mapView(); c2.W != c.W+4 {
+		t.Errorf("map width %d after narrowing panels, was %d", c2.W, c.W)
+	}
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyTab})
+	if m := model.(pilotModel); m.panelWidth() != 0 || strings.Contains(m.View(), "sats 30") {
+		t.Error("tab did not hide the panels")
+	}
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyTab})
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("1")})
+	if view := model.View(); strings.Contains(view, "sats 30") || !strings.Contains(view, "in Front") {
+		t.Error("1 did not toggle just the RTK panel")
+	}
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// driveProfile is how quickly a mower model may change speed when driven by
+// hand. Rates are per second: mm/s² for speed, mrad/s² for turning.
+type driveProfile struct {
+	Name      string
+	Accel     float64 // speeding up
+	Decel     float64 // slowing down, including through zero to reverse
+	TurnAccel float64 // changing turn rate either way
+}
+
+// driveProfiles are matched by device-name prefix, first match wins. The
+// rates are conservative starting points; pilot's --accel, --decel and
+// --turn-accel override them.
+var driveProfiles = []struct {
+	prefix  string
+	profile driveProfile
+}{
+	{"Luba-VS", driveProfile{"Luba 2", 800, 1200, 1500}},
+	{"Luba-VA", driveProfile{"Luba 2", 800, 1200, 1500}},
+	{"Yuka-", driveProfile{"Yuka", 600, 1000, 1200}},
+	{"Luba-", driveProfile{"Luba", 600, 1000, 1200}},
+}
+
+var defaultDriveProfile = driveProfile{"default", 600, 1000, 1200}
+
+// driveProfileFor picks the profile for a device name.
+func driveProfileFor(deviceName string) driveProfile {
+	for _, p := range driveProfiles {
+		if strings.HasPrefix(deviceName, p.prefix) {
+			return p.profile
+		}
+	}
+	return defaultDriveProfile
+}
+
+// ramp moves cur toward target by at most one tick of the rate: up while
+// speeding up in the same direction, down otherwise. A zero rate jumps
+// straight to the target.
+func ramp(cur, target, up, down float64, tick time.Duration) float64 {
+	rate := down
+	if math.Abs(target) > math.Abs(cur) && (cur == 0 || math.Signbit(cur) == math.Signbit(target)) {
+		rate = up
+	}
+	if rate <= 0 {
+		return target
+	}
+	step := rate * tick.Seconds()
+	if math.Abs(target-cur) <= step {
+		return target
+	}
+	return cur + math.Copysign(step, target-cur)
+}
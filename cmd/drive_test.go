@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestDriveRamps(t *testing.T) {
+	tick := 100 * time.Millisecond
+	for _, c := range []struct {
+		cur, target, want float64
+	}{
+		{0, 600, 100},     // speeding up at 1000/s
+		{550, 600, 600},   // the last step lands on the target
+		{600, 0, 400},     // slowing down at 2000/s
+		{100, -600, -100}, // through zero to reverse slows first
+		{-600, -200, -400},
+	} {
+		if got := ramp(c.cur, c.target, 1000, 2000, tick); math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("ramp(%v → %v) = %v, want %v", c.cur, c.target, got, c.want)
+		}
+	}
+	if got := ramp(0, 600, 0, 0, tick); got != 600 {
+		t.Errorf("zero rate should jump to the target, got %v", got)
+	}
+	if p := driveProfileFor("Luba-VSXXXXXX"); p.Name != "Luba 2" {
+		t.Errorf("Luba-VS profile = %q", p.Name)
+	}
+	if p := driveProfileFor("Yuka-ABC"); p.Name != "Yuka" {
+		t.Errorf("Yuka profile = %q", p.Name)
+	}
+	if p := driveProfileFor("Mower"); p != defaultDriveProfile {
+		t.Errorf("unknown model profile = %+v", p)
+	}
+
+	// Against the simulator the speed builds up over several ticks.
+	s, sim := newSimSession(simLawn())
+	mc := &motionController{session: s, notify: func(string) {}, accel: 1000, decel: 2000, turnAccel: 2000}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		mc.run(stop)
+		close(done)
+	}()
+	defer func() {
+		close(stop)
+		<-done
+	}()
+	mc.Drive(600, 0, 2*time.Second)
+	time.Sleep(200 * time.Millisecond)
+	if lin, _ := sim.Motion(); lin <= 0 || lin >= 600 {
+		t.Errorf("after one tick linear %d, want part way to 600", lin)
+	}
+	time.Sleep(800 * time.Millisecond)
+	if lin, _ := sim.Motion(); lin != 600 {
+		t.Errorf("after ramping linear %d, want 600", lin)
+	}
+}
+
+func TestPilotCombinedDriveAndCruise(t *testing.T) {
+	s, _ := newSimSession(simLawn())
+	mc := &motionController{session: s, notify: func(string) {}}
+	var model tea.Model = pilotModel{session: s, motion: mc, speed: 400, turnRate: 450, minBattery: 15, zoom: 1, fenceMargin: 0.3}
+	key := func(k string) {
+		model, _ = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)})
+	}
+	model, _ = model.Update(pilotMapMsg(simLawn()))
+	model, _ = model.Update(pilotPosMsg{x: 10, y: 5, heading: 270, posType: 4})
+
+	// w then d: steering keeps the forward motion going.
+	key("w")
+	key("d")
+	if mc.linear != 400 || mc.angular != 450 || !time.Now().Before(mc.linUntil) {
+		t.Fatalf("w+d: linear %d angular %d", mc.linear, mc.angular)
+	}
+	if m := model.(pilotModel); m.status != "▲ forward ▶ right" {
+		t.Errorf("status %q", m.status)
+	}
+
+	// Cruise holds the speed across position reports until cancelled.
+	key(" ")
+	key("c")
+	model, _ = model.Update(pilotPosMsg{x: 9.8, y: 5, heading: 270, posType: 4})
+	if m := model.(pilotModel); !m.cruise || mc.linear != 400 || mc.linUntil.Sub(time.Now()) < time.Second {
+		t.Fatalf("cruise: on %v, linear %d, held %s", m.cruise, mc.linear, time.Until(mc.linUntil))
+	}
+	key("]")
+	if m := model.(pilotModel); m.cruiseSpeed != 500 || mc.linear != 500 {
+		t.Errorf("cruise speed %d, linear %d after ]", m.cruiseSpeed, mc.linear)
+	}
+	key(" ")
+	if m := model.(pilotModel); m.cruise || !mc.linUntil.IsZero() {
+		t.Errorf("space left cruise on: %v", m.cruise)
+	}
+
+	// Cruise ends where the geofence refuses to go on.
+	model, _ = model.Update(pilotPosMsg{x: 16, y: 10, heading: 90, posType: 4})
+	key("c")
+	model, _ = model.Update(pilotPosMsg{x: 17.6, y: 10, heading: 90, posType: 4})
+	if m := model.(pilotModel); m.cruise || !strings.Contains(m.status, "cruise stopped at the geofence") {
+		t.Errorf("at the fence: cruise %v, status %q", m.cruise, m.status)
+	}
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"mammo/aliyuniot"
+	"mammo/fakecloud"
+
+	"github.com/spf13/cobra"
+)
+
+// simDevice is the account's only device when serving a simulated mower.
+var simDevice = aliyuniot.Device{
+	IotId:       "sim-iot-id",
+	DeviceName:  "Luba-SIM",
+	ProductKey:  "simProduct",
+	NickName:    "Simulated Luba",
+	ProductName: "Luba 2",
+	Status:      1,
+	Owned:       1,
+}
+
+// startSimCloud brings up a stand-in cloud with one simulated mower bound to
+// it, stepping the mower's motion every tick.
+func startSimCloud(m *MowerMap, opts fakecloud.Options, tick time.Duration) (*fakecloud.Cloud, *SimMower, func(), error) {
+	cloud, err := fakecloud.Start(opts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var sim *SimMower
+	emit := cloud.AddDevice(simDevice, func(data []byte) error { return sim.Handle(data) })
+	sim = NewSimMower(m, emit)
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(tick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				sim.Step(tick)
+			case <-done:
+				return
+			}
+		}
+	}()
+	stop := func() {
+		close(done)
+		cloud.Close()
+	}
+	return cloud, sim, stop, nil
+}
+
+var (
+	fakeCloudMap    string
+	fakeCloudListen string
+	fakeCloudBroker string
+	fakeCloudTLS    bool
+)
+
+var fakeCloudCmd = &cobra.Command{
+	Use:   "fakecloud",
+	Short: "Serve a local stand-in cloud with a simulated mower",
+	Long: `Runs the account service, the IoT API gateway and an MQTT broker
+locally, with one simulated mower on the account that mows the given map.
+Point any other command at it with the printed --cloud-url/--broker flags to
+exercise login, connect and control without a real account or mower.
+
+The -u/-p given here are the credentials the stand-in accepts (default
+sim/sim). With --tls the broker serves a fresh self-signed certificate and
+its PEM is written next to the map for --ca-file.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if fakeCloudMap == "" {
+			fmt.Println("Error: --map is required")
+			return
+		}
+		m, err := LoadMap(fakeCloudMap)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		opts := fakecloud.Options{
+			Username:   username,
+			Password:   password,
+			HTTPAddr:   fakeCloudListen,
+			BrokerAddr: fakeCloudBroker,
+		}
+		if opts.Username == "" {
+			opts.Username, opts.Password = "sim", "sim"
+		}
+		var caPath string
+		if fakeCloudTLS {
+			tlsConfig, certPEM, err := fakecloud.SelfSignedTLS()
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			caPath = filepath.Join(filepath.Dir(fakeCloudMap), "fakecloud-ca.pem")
+			if err := os.WriteFile(caPath, certPEM, 0o644); err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			opts.BrokerTLS = tlsConfig
+		}
+
+		cloud, _, stop, err := startSimCloud(m, opts, 500*time.Millisecond)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		defer stop()
+
+		fmt.Println("Stand-in cloud running. Connect with:")
+		flags := fmt.Sprintf("  -u %s -p %s --cloud-url %s --broker %s", opts.Username, opts.Password, cloud.URL(), cloud.Broker.URL())
+		if caPath != "" {
+			flags += " --ca-file " + caPath
+		}
+		fmt.Println(flags)
+		fmt.Println("Ctrl-C to stop.")
+
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt)
+		<-sig
+	},
+}
+
+func init() {
+	fakeCloudCmd.Flags().StringVar(&fakeCloudMap, "map", "", "saved map file the simulated mower mows (required)")
+	fakeCloudCmd.Flags().StringVar(&fakeCloudListen, "listen", "127.0.0.1:8080", "address for the HTTP APIs")
+	fakeCloudCmd.Flags().StringVar(&fakeCloudBroker, "broker-listen", "127.0.0.1:1883", "address for the MQTT broker")
+	fakeCloudCmd.Flags().BoolVar(&fakeCloudTLS, "tls", false, "serve the broker over TLS with a self-signed certificate")
+	rootCmd.AddCommand(fakeCloudCmd)
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"mammo/fakecloud"
+	"mammo/mammotion"
+)
+
+// TestFakeCloudEndToEnd runs the real login → region → oauth → session →
+// device list → MQTT (over TLS, verified against a supplied root) → command
+// flow against the stand-in cloud, with a simulated mower answering.
+func TestFakeCloudEndToEnd(t *testing.T) {
+	tlsConfig, certPEM, err := fakecloud.SelfSignedTLS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	caPath := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caPath, certPEM, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m := simLawn()
+	cloud, sim, stop, err := startSimCloud(m, fakecloud.Options{
+		Username:  "owner@example.com",
+		Password:  "hunter2",
+		BrokerTLS: tlsConfig,
+	}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	username, password = "owner@example.com", "hunter2"
+	cloudURL, brokerURL, caFile = cloud.URL(), cloud.Broker.URL(), caPath
+	t.Cleanup(func() {
+		username, password, cloudURL, brokerURL, caFile = "", "", "", "", ""
+		applyEndpoints()
+	})
+	if err := applyEndpoints(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := connectCloud()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if s.device.IotId != simDevice.IotId {
+		t.Fatalf("connected to %q, want the account's device %q", s.device.IotId, simDevice.IotId)
+	}
+
+	var mu sync.Mutex
+	var x, y float32
+	gotPos := make(chan struct{}, 1)
+	s.stateManager.OnPositionUpdate = func(px, py float32, _ int32, _ int32) {
+		mu.Lock()
+		x, y = px, py
+		mu.Unlock()
+		select {
+		case gotPos <- struct{}{}:
+		default:
+		}
+	}
+	sim.SetPose(3.25, -1.5, 90)
+	if err := primeSession(s); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-gotPos:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no position report arrived over MQTT")
+	}
+	mu.Lock()
+	if x != 32500 || y != -15000 {
+		t.Errorf("reported position %.0f, %.0f, want 32500, -15000", x, y)
+	}
+	mu.Unlock()
+
+	got, err := FetchMap(s, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Elements) != len(m.Elements) || got.AreaNames[101] != "Front" {
+		t.Errorf("map over the cloud path: %d elements, names %v", len(got.Elements), got.AreaNames)
+	}
+	if cloud.Invokes(simDevice.IotId) < 2 || cloud.Refreshes() < 2 {
+		t.Errorf("gateway saw %d invokes and %d session refreshes", cloud.Invokes(simDevice.IotId), cloud.Refreshes())
+	}
+	stats := s.transport.(*mammotion.CloudTransport).Stats()
+	if stats.Connects != 1 || stats.Messages == 0 || stats.MessageErrors != 0 ||
+		stats.Commands != uint64(cloud.Invokes(simDevice.IotId)) || stats.CommandErrors != 0 {
+		t.Errorf("cloud stats = %+v after %d invokes", stats, cloud.Invokes(simDevice.IotId))
+	}
+
+	events := make(chan *mammotion.DeviceEvent, 1)
+	s.stateManager.OnDeviceEvent = func(ev *mammotion.DeviceEvent) { events <- ev }
+	if err := cloud.Event(simDevice.IotId, "device_warning_event", map[string]any{"code": 1005}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ev := <-events:
+		if ev.Identifier != "device_warning_event" || ev.Code != 1005 {
+			t.Errorf("device event = %+v", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no warning event arrived over MQTT")
+	}
+}
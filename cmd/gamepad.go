@@ -0,0 +1,340 @@
+package cmd
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// gamepadEvent is one axis or button change, from either Linux interface.
+type gamepadEvent struct {
+	axis   bool    // an axis rather than a button
+	number int     // axis or button number, as the gamepad command prints
+	value  float64 // axes -1..1, buttons 0 or 1
+}
+
+// Linux joystick API (/dev/input/js*): 8-byte events of time (ms), value,
+// type and number. The init flag marks the synthetic events sent on open
+// with each control's starting state.
+const (
+	jsEventButton = 0x01
+	jsEventAxis   = 0x02
+	jsEventInit   = 0x80
+	jsEventSize   = 8
+)
+
+func parseJSEvent(b []byte) (gamepadEvent, bool) {
+	value := int16(binary.LittleEndian.Uint16(b[4:6]))
+	typ, number := b[6]&^jsEventInit, int(b[7])
+	switch typ {
+	case jsEventAxis:
+		return gamepadEvent{axis: true, number: number, value: math.Max(-1, float64(value)/32767)}, true
+	case jsEventButton:
+		if b[6]&jsEventInit != 0 {
+			return gamepadEvent{}, false // a button held at open is not a press
+		}
+		return gamepadEvent{number: number, value: float64(value)}, true
+	}
+	return gamepadEvent{}, false
+}
+
+// Linux evdev (/dev/input/event*): struct input_event, a timeval (its size,
+// evdevEventSize less 8, depends on the platform) then type, code and value.
+// Buttons are numbered from BTN_GAMEPAD (south = 0) and axes by their ABS
+// code, normalised with the range from EVIOCGABS.
+const (
+	evKey      = 0x01
+	evAbs      = 0x03
+	btnGamepad = 0x130
+)
+
+type absRange struct{ min, max int32 }
+
+func parseEvdevEvent(b []byte, ranges map[int]absRange) (gamepadEvent, bool) {
+	b = b[len(b)-8:] // past the timeval
+	typ := binary.LittleEndian.Uint16(b[0:2])
+	code := int(binary.LittleEndian.Uint16(b[2:4]))
+	value := int32(binary.LittleEndian.Uint32(b[4:8]))
+	switch typ {
+	case evAbs:
+		r, ok := ranges[code]
+		if !ok || r.max <= r.min {
+			r = absRange{-32768, 32767}
+		}
+		v := 2*float64(value-r.min)/float64(r.max-r.min) - 1
+		return gamepadEvent{axis: true, number: code, value: math.Max(-1, math.Min(1, v))}, true
+	case evKey:
+		if code < btnGamepad || value > 1 { // not a gamepad button, or autorepeat
+			return gamepadEvent{}, false
+		}
+		return gamepadEvent{number: code - btnGamepad, value: float64(value)}, true
+	}
+	return gamepadEvent{}, false
+}
+
+// gamepadReader reads events from a joystick or evdev device node.
+type gamepadReader struct {
+	f      *os.File
+	evdev  bool
+	ranges map[int]absRange
+	buf    []byte
+}
+
+func openGamepad(path string) (*gamepadReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &gamepadReader{f: f, buf: make([]byte, jsEventSize)}
+	if isEvdevPath(path) {
+		r.evdev = true
+		r.buf = make([]byte, evdevEventSize)
+		r.ranges = make(map[int]absRange)
+		// Sticks and triggers; codes the device lacks fail and are skipped.
+		for code := 0; code <= 5; code++ {
+			if min, max, err := evdevAbsRange(f, code); err == nil {
+				r.ranges[code] = absRange{min, max}
+			}
+		}
+	}
+	return r, nil
+}
+
+// isEvdevPath reports whether path is an evdev node rather than a joystick.
+func isEvdevPath(path string) bool {
+	return strings.HasPrefix(filepath.Base(path), "event")
+}
+
+// Next blocks for the next axis or button event.
+func (r *gamepadReader) Next() (gamepadEvent, error) {
+	for {
+		if _, err := io.ReadFull(r.f, r.buf); err != nil {
+			return gamepadEvent{}, err
+		}
+		var ev gamepadEvent
+		var ok bool
+		if r.evdev {
+			ev, ok = parseEvdevEvent(r.buf, r.ranges)
+		} else {
+			ev, ok = parseJSEvent(r.buf)
+		}
+		if ok {
+			return ev, nil
+		}
+	}
+}
+
+func (r *gamepadReader) Close() error {
+	return r.f.Close()
+}
+
+// gamepadConfig maps sticks and buttons to driving. The stick axes are
+// shaped by a deadzone (the fraction of travel ignored around centre) and an
+// expo curve (0 linear, 1 cubic) for finer control near the middle.
+type gamepadConfig struct {
+	linearAxis  int
+	angularAxis int
+	buttons     map[int]string // button number → stop, pause or dock
+	deadzone    float64
+	expo        float64
+}
+
+// defaultGamepadMap suits an Xbox-style pad on the joystick API: left stick
+// up/down drives, right stick left/right steers, B stops, start pauses and Y
+// docks.
+var defaultGamepadMap = map[string]int{"linear": 1, "angular": 3, "stop": 1, "pause": 7, "dock": 3}
+
+// defaultEvdevGamepadMap is the same pad through evdev, which numbers the
+// buttons by code from BTN_SOUTH: BTN_EAST (B) 1, BTN_Y 4, BTN_START 11. The
+// axes are the same.
+var defaultEvdevGamepadMap = map[string]int{"linear": 1, "angular": 3, "stop": 1, "pause": 11, "dock": 4}
+
+// gamepadDefaults is the default mapping for the device's interface.
+func gamepadDefaults(evdev bool) map[string]int {
+	if evdev {
+		return defaultEvdevGamepadMap
+	}
+	return defaultGamepadMap
+}
+
+var gamepadActions = []string{"stop", "pause", "dock"}
+
+// newGamepadConfig applies mapping over the defaults for a joystick or, with
+// evdev set, an evdev device.
+func newGamepadConfig(mapping map[string]int, evdev bool, deadzone, expo float64) (gamepadConfig, error) {
+	cfg := gamepadConfig{buttons: make(map[int]string), deadzone: deadzone, expo: expo}
+	if deadzone < 0 || deadzone >= 1 || expo < 0 || expo > 1 {
+		return cfg, fmt.Errorf("deadzone must be in [0,1) and expo in [0,1]")
+	}
+	defaults := gamepadDefaults(evdev)
+	merged := make(map[string]int, len(defaults))
+	for k, v := range defaults {
+		merged[k] = v
+	}
+	for k, v := range mapping {
+		if _, ok := defaults[k]; !ok {
+			return cfg, fmt.Errorf("unknown gamepad mapping %q (want linear, angular, stop, pause or dock)", k)
+		}
+		merged[k] = v
+	}
+	cfg.linearAxis, cfg.angularAxis = merged["linear"], merged["angular"]
+	for _, action := range gamepadActions {
+		if other, taken := cfg.buttons[merged[action]]; taken {
+			return cfg, fmt.Errorf("button %d is mapped to both %s and %s", merged[action], other, action)
+		}
+		cfg.buttons[merged[action]] = action
+	}
+	return cfg, nil
+}
+
+// shapeAxis applies the deadzone and expo curve to a raw axis value.
+func shapeAxis(v, deadzone, expo float64) float64 {
+	a := math.Abs(v)
+	if a <= deadzone {
+		return 0
+	}
+	a = math.Min(1, (a-deadzone)/(1-deadzone))
+	a = (1-expo)*a + expo*a*a*a
+	return math.Copysign(a, v)
+}
+
+// gamepadDriver turns gamepad events into proportional motion: stick up
+// drives forward (axes read negative upwards) and stick right turns right.
+type gamepadDriver struct {
+	cfg  gamepadConfig
+	axes map[int]float64
+}
+
+func newGamepadDriver(cfg gamepadConfig) *gamepadDriver {
+	return &gamepadDriver{cfg: cfg, axes: make(map[int]float64)}
+}
+
+// Handle records an event and returns the action of a pressed button, if any.
+func (d *gamepadDriver) Handle(ev gamepadEvent) string {
+	if ev.axis {
+		d.axes[ev.number] = ev.value
+		return ""
+	}
+	if ev.value == 1 {
+		return d.cfg.buttons[ev.number]
+	}
+	return ""
+}
+
+// Motion is the command for the current stick positions.
+func (d *gamepadDriver) Motion(maxLinear, maxAngular int32) (linear, angular int32) {
+	l := -shapeAxis(d.axes[d.cfg.linearAxis], d.cfg.deadzone, d.cfg.expo)
+	a := shapeAxis(d.axes[d.cfg.angularAxis], d.cfg.deadzone, d.cfg.expo)
+	return int32(math.Round(l * float64(maxLinear))), int32(math.Round(a * float64(maxAngular)))
+}
+
+// runGamepad reads the device and posts to the pilot: the stick command every
+// 100ms while a stick is off centre (and once more on return, as a stop), and
+// button actions as they are pressed. It returns when the device fails.
+func runGamepad(r *gamepadReader, d *gamepadDriver, motion func(linear, angular float64), button func(string)) error {
+	events := make(chan gamepadEvent)
+	errc := make(chan error, 1)
+	go func() {
+		for {
+			ev, err := r.Next()
+			if err != nil {
+				errc <- err
+				return
+			}
+			events <- ev
+		}
+	}()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	active := false
+	for {
+		select {
+		case err := <-errc:
+			return err
+		case ev := <-events:
+			if action := d.Handle(ev); action != "" {
+				button(action)
+			}
+		case <-ticker.C:
+			l, a := d.Motion(1000, 1000)
+			if l != 0 || a != 0 || active {
+				motion(float64(l)/1000, float64(a)/1000)
+			}
+			active = l != 0 || a != 0
+		}
+	}
+}
+
+var (
+	gamepadDevice string // the gamepad command's --device
+	gamepadMap    map[string]int
+	gamepadDead   float64
+	gamepadExpo   float64
+)
+
+var gamepadCmd = &cobra.Command{
+	Use:   "gamepad",
+	Short: "Print gamepad axes and buttons, to set up pilot --gamepad-map",
+	Long: `Reads --device and prints each axis and button event with its
+number, and the drive command the current mapping makes of the sticks, so you
+can find the numbers for pilot --gamepad-map. Runs until Ctrl-C.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := newGamepadConfig(gamepadMap, isEvdevPath(gamepadDevice), gamepadDead, gamepadExpo)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		r, err := openGamepad(gamepadDevice)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		defer r.Close()
+		d := newGamepadDriver(cfg)
+		for {
+			ev, err := r.Next()
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			action := d.Handle(ev)
+			if ev.axis {
+				l, a := d.Motion(1000, 1000)
+				fmt.Printf("axis %d = %+.2f   drive linear %+d‰ angular %+d‰\n", ev.number, ev.value, l, a)
+			} else {
+				fmt.Printf("button %d = %.0f %s\n", ev.number, ev.value, action)
+			}
+		}
+	},
+}
+
+// addGamepadFlags registers the mapping flags shared by pilot and the gamepad
+// command.
+func addGamepadFlags(cmd *cobra.Command) {
+	defaults := func(m map[string]int) string {
+		keys := make([]string, 0, len(m))
+		for k, v := range m {
+			keys = append(keys, fmt.Sprintf("%s=%d", k, v))
+		}
+		sort.Strings(keys)
+		return strings.Join(keys, ",")
+	}
+	cmd.Flags().StringToIntVar(&gamepadMap, "gamepad-map", nil, "axis and button numbers to override (defaults "+
+		defaults(defaultGamepadMap)+" for js*, "+defaults(defaultEvdevGamepadMap)+" for event*)")
+	cmd.Flags().Float64Var(&gamepadDead, "gamepad-deadzone", 0.15, "fraction of stick travel ignored around centre")
+	cmd.Flags().Float64Var(&gamepadExpo, "gamepad-expo", 0.4, "stick curve: 0 linear, 1 cubic (finer control near centre)")
+}
+
+func init() {
+	gamepadCmd.Flags().StringVar(&gamepadDevice, "device", "/dev/input/js0", "joystick (/dev/input/js*) or evdev (/dev/input/event*) device")
+	addGamepadFlags(gamepadCmd)
+	rootCmd.AddCommand(gamepadCmd)
+}
@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// evdevEventSize is sizeof(struct input_event): the timeval is two longs, so
+// 16 bytes on 64-bit Linux and 8 on 32-bit, then type, code and value.
+const evdevEventSize = int(unsafe.Sizeof(syscall.Timeval{})) + 8
+
+// evdevAbsRange reads an absolute axis's range with EVIOCGABS.
+func evdevAbsRange(f *os.File, code int) (min, max int32, err error) {
+	// struct input_absinfo: value, minimum, maximum, fuzz, flat, resolution.
+	var info [6]int32
+	// _IOR('E', 0x40 + code, struct input_absinfo)
+	req := uintptr(2<<30 | unsafe.Sizeof(info)<<16 | 'E'<<8 | uintptr(0x40+code))
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), req, uintptr(unsafe.Pointer(&info))); errno != 0 {
+		return 0, 0, errno
+	}
+	return info[1], info[2], nil
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package cmd
+
+import (
+	"errors"
+	"os"
+)
+
+// evdevEventSize is the 64-bit Linux layout; evdev devices are Linux-only.
+const evdevEventSize = 24
+
+// evdevAbsRange is Linux-only; elsewhere evdev axes use the full int16 range.
+func evdevAbsRange(f *os.File, code int) (min, max int32, err error) {
+	return 0, 0, errors.New("evdev axis ranges need Linux")
+}
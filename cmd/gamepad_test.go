@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestGamepad(t *testing.T) {
+	js := func(value int16, typ, number byte) []byte {
+		b := make([]byte, jsEventSize)
+		binary.LittleEndian.PutUint16(b[4:], uint16(value))
+		b[6], b[7] = typ, number
+		return b
+	}
+	if ev, ok := parseJSEvent(js(-32767, jsEventAxis|jsEventInit, 1)); !ok || !ev.axis || ev.number != 1 || ev.value != -1 {
+		t.Errorf("js axis = %+v %v", ev, ok)
+	}
+	if _, ok := parseJSEvent(js(1, jsEventButton|jsEventInit, 0)); ok {
+		t.Error("a button held at open counted as a press")
+	}
+	evdev := func(size int, typ, code uint16, value int32) []byte {
+		b := make([]byte, size)
+		binary.LittleEndian.PutUint16(b[size-8:], typ)
+		binary.LittleEndian.PutUint16(b[size-6:], code)
+		binary.LittleEndian.PutUint32(b[size-4:], uint32(value))
+		return b
+	}
+	if got, ok := parseEvdevEvent(evdev(evdevEventSize, evAbs, 4, 255), map[int]absRange{4: {0, 255}}); !ok || got.number != 4 || got.value != 1 {
+		t.Errorf("evdev axis = %+v %v", got, ok)
+	}
+	// 64-bit and 32-bit Linux differ only in the size of the timeval.
+	for _, size := range []int{24, 16} {
+		if got, ok := parseEvdevEvent(evdev(size, evKey, btnGamepad+1, 1), nil); !ok || got.axis || got.number != 1 || got.value != 1 {
+			t.Errorf("%d-byte evdev button = %+v %v", size, got, ok)
+		}
+	}
+
+	for _, c := range []struct{ v, want float64 }{{0.1, 0}, {-0.15, 0}, {1, 1}, {-1, -1}, {0.575, 0.5*0.6 + 0.125*0.4}} {
+		if got := shapeAxis(c.v, 0.15, 0.4); math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("shapeAxis(%v) = %v, want %v", c.v, got, c.want)
+		}
+	}
+	if _, err := newGamepadConfig(map[string]int{"stop": 7}, false, 0.15, 0.4); err == nil {
+		t.Error("accepted stop and pause on one button")
+	}
+	if _, err := newGamepadConfig(map[string]int{"boost": 2}, false, 0.15, 0.4); err == nil {
+		t.Error("accepted an unknown mapping")
+	}
+	// evdev numbers Start as 11 (BTN_START); 7 is BTN_TR, the right bumper.
+	if cfg, err := newGamepadConfig(nil, true, 0.15, 0.4); err != nil || cfg.buttons[11] != "pause" || cfg.buttons[7] != "" {
+		t.Errorf("evdev defaults = %v, %v", cfg.buttons, err)
+	}
+	if !isEvdevPath("/dev/input/event3") || isEvdevPath("/dev/input/js0") {
+		t.Error("isEvdevPath misclassified a device node")
+	}
+
+	// Through a pipe standing in for /dev/input/js0, into pilot.
+	cfg, err := newGamepadConfig(map[string]int{"angular": 0}, false, 0.15, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	motions := make(chan pilotPadMsg, 16)
+	buttons := make(chan string, 4)
+	done := make(chan error, 1)
+	go func() {
+		done <- runGamepad(&gamepadReader{f: pr, buf: make([]byte, jsEventSize)}, newGamepadDriver(cfg),
+			func(l, a float64) { motions <- pilotPadMsg{linear: l, angular: a} },
+			func(action string) { buttons <- action })
+	}()
+	pw.Write(js(-32767, jsEventAxis, 1)) // full forward
+	pw.Write(js(16384, jsEventAxis, 0))  // half right, less the deadzone
+	pw.Write(js(1, jsEventButton, 1))    // B
+	if action := <-buttons; action != "stop" {
+		t.Errorf("button 1 = %q", action)
+	}
+	var got pilotPadMsg
+	for got.linear == 0 || got.angular == 0 {
+		got = <-motions
+	}
+	if got.linear != 1 || math.Abs(got.angular-0.412) > 0.001 {
+		t.Errorf("stick command = %+v", got)
+	}
+
+	s, _ := newSimSession(simLawn())
+	mc := &motionController{session: s, notify: func(string) {}}
+	var model tea.Model = pilotModel{session: s, motion: mc, speed: 500, turnRate: 400, minBattery: 15, zoom: 1}
+	model, _ = model.Update(got)
+	if mc.linear != 500 || mc.angular != 165 {
+		t.Errorf("pilot drove %d, %d", mc.linear, mc.angular)
+	}
+
+	pw.Close()
+	if err := <-done; err == nil {
+		t.Error("runGamepad did not stop when the device closed")
+	}
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	// geofenceHorizon is how far ahead a motion command is predicted. It
+	// covers the gap between position reports (about a second) plus the
+	// time the mower takes to stop.
+	geofenceHorizon = 1500 * time.Millisecond
+	// channelHalfWidth is how far (metres) either side of a channel between
+	// zones counts as inside the fence.
+	channelHalfWidth = 0.5
+)
+
+// geofence keeps manual driving inside the mowing areas and channels of a
+// map, at least margin metres from their edges and from every no-go zone.
+type geofence struct {
+	areas     [][]MapPoint
+	channels  [][]MapPoint
+	obstacles [][]MapPoint
+	margin    float64
+}
+
+// newGeofence builds the fence for m, or returns nil when the map has no
+// mowing areas to fence in.
+func newGeofence(m *MowerMap, margin float64) *geofence {
+	if m == nil {
+		return nil
+	}
+	g := &geofence{margin: margin, obstacles: mapObstacles(m)}
+	for _, el := range m.Elements {
+		switch {
+		case el.Type == MapTypeArea && len(el.Points) >= 3:
+			g.areas = append(g.areas, el.Points)
+		case el.Type == MapTypePath && len(el.Points) >= 2:
+			g.channels = append(g.channels, el.Points)
+		}
+	}
+	if len(g.areas) == 0 {
+		return nil
+	}
+	return g
+}
+
+// polygonEdgeDist is the distance from a point to the nearest edge of poly.
+func polygonEdgeDist(x, y float64, poly []MapPoint, closed bool) float64 {
+	d := math.Inf(1)
+	for i := 1; i < len(poly); i++ {
+		d = math.Min(d, distToSegment(x, y, poly[i-1].X, poly[i-1].Y, poly[i].X, poly[i].Y))
+	}
+	if closed {
+		last := len(poly) - 1
+		d = math.Min(d, distToSegment(x, y, poly[last].X, poly[last].Y, poly[0].X, poly[0].Y))
+	}
+	return d
+}
+
+// Clearance is how far (metres) the point is inside the fence: positive
+// inside, negative outside, with the margin already taken off.
+func (g *geofence) Clearance(x, y float64) float64 {
+	inside := math.Inf(-1)
+	for _, poly := range g.areas {
+		d := polygonEdgeDist(x, y, poly, true)
+		if !pointInPolygon(x, y, poly) {
+			d = -d
+		}
+		inside = math.Max(inside, d)
+	}
+	for _, line := range g.channels {
+		inside = math.Max(inside, channelHalfWidth-polygonEdgeDist(x, y, line, false))
+	}
+	for _, poly := range g.obstacles {
+		d := polygonEdgeDist(x, y, poly, true)
+		if pointInPolygon(x, y, poly) {
+			d = -d
+		}
+		inside = math.Min(inside, d)
+	}
+	return inside - g.margin
+}
+
+// predictPath integrates a motion command from a pose for geofenceHorizon,
+// returning a sample every 100ms. Linear is mm/s; angular is mrad/s, positive
+// clockwise.
+func predictPath(x, y, heading float64, linear, angular int32) []MapPoint {
+	const dt = 0.1
+	h := heading * math.Pi / 180
+	v := float64(linear) / 1000
+	w := float64(angular) / 1000
+	var pts []MapPoint
+	for t := dt; t <= geofenceHorizon.Seconds()+1e-9; t += dt {
+		h += w * dt
+		x += v * math.Sin(h) * dt
+		y += v * math.Cos(h) * dt
+		pts = append(pts, MapPoint{X: x, Y: y})
+	}
+	return pts
+}
+
+// Check vets a motion command from the current pose. Turning on the spot is
+// always allowed. A command whose predicted path would leave the fence is
+// slowed until it stays inside, and refused (linear 0) if even a quarter of
+// the speed would not. Outside the fence — after an override, say — only
+// commands that do not take the mower further out are allowed. msg explains
+// any change and is empty when the command passes as given.
+func (g *geofence) Check(x, y, heading float64, linear, angular int32) (allowed int32, msg string) {
+	if linear == 0 {
+		return 0, ""
+	}
+	now := g.Clearance(x, y)
+	for _, f := range []float64{1, 0.75, 0.5, 0.25} {
+		l := int32(float64(linear) * f)
+		ok := true
+		for _, p := range predictPath(x, y, heading, l, angular) {
+			c := g.Clearance(p.X, p.Y)
+			if c < 0 && c < now-0.01 {
+				ok = false
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+		if f == 1 {
+			return linear, ""
+		}
+		return l, fmt.Sprintf("geofence: slowed to %d mm/s", abs(int(l)))
+	}
+	if now < 0 {
+		return 0, fmt.Sprintf("geofence: outside the fence by %.1fm; only moves back in are allowed", -now)
+	}
+	return 0, "geofence: refused — would leave the mowing area or enter a no-go zone"
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestGeofence(t *testing.T) {
+	// simLawn's zone is a circle of radius 8 around 10,10 with an obstacle
+	// inside and a channel running from 0,0 to 2,10.
+	g := newGeofence(simLawn(), 0.3)
+	if g == nil {
+		t.Fatal("no fence for a map with an area")
+	}
+	for _, c := range []struct {
+		x, y   float64
+		inside bool
+	}{{14, 10, true}, {10.5, 9.5, false}, {1, 5, true}, {19, 10, false}, {17.8, 10, false}} {
+		if got := g.Clearance(c.x, c.y) >= 0; got != c.inside {
+			t.Errorf("Clearance(%v, %v) = %.2f, want inside %v", c.x, c.y, g.Clearance(c.x, c.y), c.inside)
+		}
+	}
+
+	for _, c := range []struct {
+		name            string
+		x, y, heading   float64
+		linear, angular int32
+		want            int32
+		wantMsg         string
+	}{
+		{"clear ahead", 17, 10, 90, 400, 0, 400, ""},
+		{"slowed near the edge", 17, 10, 90, 1000, 0, 250, "slowed to 250"},
+		{"away from the edge", 17, 10, 270, 1000, 0, 1000, ""},
+		{"refused at the edge", 17.6, 10, 90, 400, 0, 0, "refused"},
+		{"turning on the spot", 17.9, 10, 90, 0, 450, 0, ""},
+		{"back in from outside", 18.5, 10, 270, 400, 0, 400, ""},
+		{"further out from outside", 18.5, 10, 90, 400, 0, 0, "outside the fence"},
+	} {
+		got, msg := g.Check(c.x, c.y, c.heading, c.linear, c.angular)
+		if got != c.want || !strings.Contains(msg, c.wantMsg) || (c.wantMsg == "" && msg != "") {
+			t.Errorf("%s: Check = %d %q, want %d %q", c.name, got, msg, c.want, c.wantMsg)
+		}
+	}
+
+	if newGeofence(&MowerMap{}, 0.3) != nil {
+		t.Error("fenced a map without areas")
+	}
+}
+
+func TestPilotGeofenceOverride(t *testing.T) {
+	s, _ := newSimSession(simLawn())
+	mc := &motionController{session: s, notify: func(string) {}}
+	var model tea.Model = pilotModel{session: s, motion: mc, speed: 400, turnRate: 450, minBattery: 15, zoom: 1, fenceMargin: 0.3}
+	for _, msg := range []tea.Msg{
+		pilotMapMsg(simLawn()),
+		pilotPosMsg{x: 17.6, y: 10, heading: 90, posType: 4},
+		tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("w")},
+	} {
+		model, _ = model.Update(msg)
+	}
+	if m := model.(pilotModel); !strings.Contains(m.fenceWarn, "refused") || mc.linear != 0 {
+		t.Errorf("driving out: warning %q, linear %d", m.fenceWarn, mc.linear)
+	}
+	for _, key := range []string{"F", "w"} {
+		model, _ = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+	}
+	if m := model.(pilotModel); !m.fenceOff || m.fenceWarn != "" || mc.linear != 400 {
+		t.Errorf("overridden: off %v, warning %q, linear %d", m.fenceOff, m.fenceWarn, mc.linear)
+	}
+}
@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"mammo/mammotion"
+	pb "mammo/proto"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// msgFilter selects messages by lubaMsgPath. A filter matches its own path
+// and everything below it, so "nav" matches every nav message and
+// "nav.toapp_zigzag" only that one.
+type msgFilter struct {
+	include []string
+	exclude []string
+}
+
+func pathMatches(path, filter string) bool {
+	return path == filter || strings.HasPrefix(path, filter+".")
+}
+
+func (f msgFilter) match(path string) bool {
+	for _, ex := range f.exclude {
+		if pathMatches(path, ex) {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, in := range f.include {
+		if pathMatches(path, in) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatLubaMsg renders a LubaMsg as a header line (type path and envelope
+// fields) followed by its sub-message as protojson.
+func formatLubaMsg(data []byte, compact bool) (string, error) {
+	var msg pb.LubaMsg
+	if err := proto.Unmarshal(data, &msg); err != nil {
+		return "", err
+	}
+	header := fmt.Sprintf("%s  msgtype=%s sender=%s rcver=%s seqs=%d",
+		lubaMsgPath(data), msg.Msgtype, msg.Sender, msg.Rcver, msg.Seqs)
+
+	var body proto.Message = &msg
+	m := msg.ProtoReflect()
+	if od := m.Descriptor().Oneofs().ByName("LubaSubMsg"); od != nil {
+		if fd := m.WhichOneof(od); fd != nil && fd.Kind() == protoreflect.MessageKind {
+			body = m.Get(fd).Message().Interface()
+		}
+	}
+	opts := protojson.MarshalOptions{UseProtoNames: true}
+	if !compact {
+		opts.Multiline = true
+		opts.Indent = "  "
+	}
+	js, err := opts.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+	if compact {
+		return header + "  " + string(js), nil
+	}
+	return header + "\n" + string(js), nil
+}
+
+// decodeInput turns one base64 or hex token into bytes. Hex is assumed when
+// the token is an even number of hex digits (an optional 0x prefix is
+// allowed), since short base64 strings can look like hex the other way round.
+func decodeInput(token, format string) ([]byte, error) {
+	token = strings.TrimSpace(token)
+	switch format {
+	case "hex":
+		return hex.DecodeString(strings.TrimPrefix(token, "0x"))
+	case "base64":
+		return base64.StdEncoding.DecodeString(token)
+	}
+	if h := strings.TrimPrefix(token, "0x"); len(h)%2 == 0 && strings.Trim(h, "0123456789abcdefABCDEF") == "" {
+		return hex.DecodeString(h)
+	}
+	return base64.StdEncoding.DecodeString(token)
+}
+
+var (
+	inspectTypes    []string
+	inspectExclude  []string
+	inspectDuration int
+	inspectCompact  bool
+	inspectFile     string
+	decodeFormat    string
+)
+
+func inspectFilter() msgFilter {
+	return msgFilter{include: inspectTypes, exclude: inspectExclude}
+}
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect",
+	Short: "Print every LubaMsg from the mower as readable JSON",
+	Long: `Connects and prints each message the mower sends: its type path (e.g.
+nav.toapp_zigzag), envelope (msgtype, sender, rcver, seqs) and the full
+sub-message as JSON. --type and --exclude take type paths and match
+everything below them, so --type nav shows only navigation messages.
+
+With --file, inspects a --record recording instead (both directions, → for
+app→mower commands, ← for replies) without connecting.
+
+See also: inspect decode, for single messages pasted as base64 or hex.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		filter := inspectFilter()
+		var mu sync.Mutex
+		show := func(at, arrow string, data []byte) {
+			if !filter.match(lubaMsgPath(data)) {
+				return
+			}
+			out, err := formatLubaMsg(data, inspectCompact)
+			if err != nil {
+				out = fmt.Sprintf("undecodable (%d bytes): %v  %s", len(data), err, hex.EncodeToString(data))
+			}
+			mu.Lock()
+			fmt.Printf("%s %s %s\n", at, arrow, out)
+			mu.Unlock()
+		}
+
+		if inspectFile != "" {
+			rec, err := mammotion.LoadRecording(inspectFile)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			for _, m := range rec.Messages {
+				arrow := "←"
+				if m.Outbound {
+					arrow = "→"
+				}
+				show(fmt.Sprintf("%10.3fs", m.At.Seconds()), arrow, m.Data)
+			}
+			return
+		}
+
+		withSession(func(s *cloudSession) error {
+			unsubscribe := s.transport.Subscribe(func(data []byte) {
+				show(time.Now().Format("15:04:05.000"), "←", data)
+			})
+			defer unsubscribe()
+			stopPolling := startPolling(s)
+			defer stopPolling()
+			time.Sleep(time.Duration(inspectDuration) * time.Second)
+			return nil
+		})
+	},
+}
+
+var inspectDecodeCmd = &cobra.Command{
+	Use:   "decode",
+	Short: "Decode LubaMsg bytes given as base64 or hex on stdin",
+	Long: `Reads one message per line from stdin, as base64 (the "content" of an
+MQTT event) or hex, and prints it as inspect does. The encoding is detected
+per line unless --format is given. No login is needed.
+
+  echo 'CAEQ...' | mammo inspect decode`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runDecode(os.Stdin, os.Stdout, decodeFormat, inspectCompact); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+	},
+}
+
+// runDecode decodes each non-empty line of r and writes the result to w.
+// Lines that fail to decode are reported inline and decoding carries on.
+func runDecode(r io.Reader, w io.Writer, format string, compact bool) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	line := 0
+	for sc.Scan() {
+		line++
+		token := strings.TrimSpace(sc.Text())
+		if token == "" {
+			continue
+		}
+		data, err := decodeInput(token, format)
+		if err != nil {
+			fmt.Fprintf(w, "line %d: not %s: %v\n", line, orAuto(format), err)
+			continue
+		}
+		out, err := formatLubaMsg(data, compact)
+		if err != nil {
+			fmt.Fprintf(w, "line %d: not a LubaMsg (%d bytes): %v\n", line, len(data), err)
+			continue
+		}
+		fmt.Fprintln(w, out)
+	}
+	return sc.Err()
+}
+
+func orAuto(format string) string {
+	if format == "" {
+		return "base64 or hex"
+	}
+	return format
+}
+
+func init() {
+	inspectCmd.PersistentFlags().BoolVar(&inspectCompact, "compact", false, "one line per message")
+	inspectCmd.Flags().StringSliceVar(&inspectTypes, "type", nil, "only show these type paths (repeatable, e.g. nav or sys.toapp_report_data)")
+	inspectCmd.Flags().StringSliceVar(&inspectExclude, "exclude", nil, "hide these type paths (repeatable)")
+	inspectCmd.Flags().IntVar(&inspectDuration, "duration", 60, "seconds to listen")
+	inspectCmd.Flags().StringVar(&inspectFile, "file", "", "inspect a --record recording instead of connecting")
+	inspectDecodeCmd.Flags().StringVar(&decodeFormat, "format", "", "input encoding: base64 or hex (default: detect per line)")
+	inspectCmd.AddCommand(inspectDecodeCmd)
+	rootCmd.AddCommand(inspectCmd)
+}
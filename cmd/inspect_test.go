@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"mammo/mammotion"
+)
+
+func TestInspectDecode(t *testing.T) {
+	msg, err := mammotion.SendTodevBleSync(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := base64.StdEncoding.EncodeToString(msg) + "\n\n0x" + hex.EncodeToString(msg) + "\nnot-a-message!\n"
+	var out bytes.Buffer
+	if err := runDecode(strings.NewReader(in), &out, "", true); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d output lines, want 3:\n%s", len(lines), out.String())
+	}
+	for _, l := range lines[:2] {
+		if !strings.HasPrefix(l, "net.todev_ble_sync  msgtype=") || !strings.Contains(l, `"todev_ble_sync"`) {
+			t.Errorf("decoded line = %q", l)
+		}
+	}
+	if !strings.HasPrefix(lines[2], "line 4: not base64 or hex") {
+		t.Errorf("bad input line = %q", lines[2])
+	}
+
+	f := msgFilter{include: []string{"nav"}, exclude: []string{"nav.toapp_zigzag"}}
+	for path, want := range map[string]bool{
+		"nav.toapp_bp":       true,
+		"nav.toapp_zigzag":   false,
+		"navx.anything":      false,
+		"net.todev_ble_sync": false,
+	} {
+		if f.match(path) != want {
+			t.Errorf("filter match %q = %v, want %v", path, !want, want)
+		}
+	}
+}
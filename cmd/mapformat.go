@@ -0,0 +1,221 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// mapFormatVersion is the MowerMap file format written by this build. The
+// published JSON Schema is docs/mowermap.schema.json; bump both together and
+// add a migration below whenever a field is added or changes meaning.
+//
+// History:
+//
+//	1  elements, dock
+//	2  areaNames (phone-app zone names) and svgs (pattern overlays)
+const mapFormatVersion = 2
+
+// mapMigration upgrades a decoded map file from version from to from+1. It
+// works on the raw JSON object so it can rename or reshape fields the current
+// struct no longer has. Numbers in it are json.Number, so 64-bit hashes survive
+// the round trip intact.
+type mapMigration struct {
+	from    int
+	migrate func(raw map[string]any) error
+}
+
+var mapMigrations = []mapMigration{
+	{from: 1, migrate: migrateMapV1},
+}
+
+// migrateMapV1 upgrades version 1 files. Version 2 only added optional fields,
+// but v1 writers could leave typeName empty, so backfill it from the type code.
+func migrateMapV1(raw map[string]any) error {
+	els, _ := raw["elements"].([]any)
+	for _, e := range els {
+		el, ok := e.(map[string]any)
+		if !ok {
+			continue
+		}
+		if name, _ := el["typeName"].(string); name != "" {
+			continue
+		}
+		if t, err := jsonInt(el["type"]); err == nil {
+			el["typeName"] = mapTypeName(int32(t))
+		}
+	}
+	return nil
+}
+
+// decodeMap parses map file JSON, applying migrations up to mapFormatVersion.
+// Files written before versioning (no formatVersion) are treated as version 1.
+func decodeMap(data []byte) (*MowerMap, error) {
+	var raw map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, fmt.Errorf("not a map object")
+	}
+	version := 1
+	if v, err := jsonInt(raw["formatVersion"]); err == nil && v > 0 {
+		version = int(v)
+	}
+	if version > mapFormatVersion {
+		return nil, fmt.Errorf("map format version %d is newer than supported (%d); upgrade mammo", version, mapFormatVersion)
+	}
+	for _, mig := range mapMigrations {
+		if mig.from < version {
+			continue
+		}
+		if mig.from != version {
+			return nil, fmt.Errorf("no migration from map format version %d", version)
+		}
+		if err := mig.migrate(raw); err != nil {
+			return nil, fmt.Errorf("migrate map format %d→%d: %w", version, version+1, err)
+		}
+		version++
+	}
+	raw["formatVersion"] = version
+
+	migrated, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var m MowerMap
+	if err := json.Unmarshal(migrated, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// jsonInt reads an integer decoded as json.Number.
+func jsonInt(v any) (int64, error) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("not a number: %v", v)
+	}
+	return n.Int64()
+}
+
+// MapIssue is one finding from ValidateMap.
+type MapIssue struct {
+	Error   bool // false = warning
+	Element int  // index into Elements, -1 for map-level issues
+	Message string
+}
+
+func (i MapIssue) String() string {
+	level := "warning"
+	if i.Error {
+		level = "error"
+	}
+	if i.Element < 0 {
+		return fmt.Sprintf("%s: %s", level, i.Message)
+	}
+	return fmt.Sprintf("%s: element %d: %s", level, i.Element, i.Message)
+}
+
+const (
+	// maxMapCoord bounds plausible map coordinates. The map frame is centred
+	// near the dock; no garden spans tens of kilometres.
+	maxMapCoord = 10000.0
+	// maxVertexGap flags boundary segments long enough to suggest a corrupt
+	// or mis-scaled vertex.
+	maxVertexGap = 500.0
+)
+
+// ValidateMap checks a map for structural problems: unknown element types,
+// degenerate or unclosed polygons, duplicate hashes and implausible
+// coordinates.
+func ValidateMap(m *MowerMap) []MapIssue {
+	var issues []MapIssue
+	errorf := func(el int, format string, args ...any) {
+		issues = append(issues, MapIssue{Error: true, Element: el, Message: fmt.Sprintf(format, args...)})
+	}
+	warnf := func(el int, format string, args ...any) {
+		issues = append(issues, MapIssue{Element: el, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if m.FormatVersion < 1 || m.FormatVersion > mapFormatVersion {
+		errorf(-1, "unsupported formatVersion %d (this build reads 1-%d)", m.FormatVersion, mapFormatVersion)
+	}
+	if len(m.Elements) == 0 {
+		errorf(-1, "map has no elements")
+	}
+
+	badCoord := func(x, y float64) bool {
+		return math.IsNaN(x) || math.IsNaN(y) || math.IsInf(x, 0) || math.IsInf(y, 0) ||
+			math.Abs(x) > maxMapCoord || math.Abs(y) > maxMapCoord
+	}
+
+	hashes := make(map[int64]int)
+	for i, el := range m.Elements {
+		if prev, dup := hashes[el.Hash]; dup {
+			errorf(i, "duplicate hash %d (also element %d)", el.Hash, prev)
+		} else {
+			hashes[el.Hash] = i
+		}
+
+		switch el.Type {
+		case MapTypeArea, MapTypeObstacle, MapTypePath, MapTypeDumpPoint, MapTypeSvg:
+		default:
+			warnf(i, "unknown element type %d", el.Type)
+		}
+		if el.TypeName != "" && el.TypeName != mapTypeName(el.Type) {
+			warnf(i, "typeName %q does not match type %d (%s)", el.TypeName, el.Type, mapTypeName(el.Type))
+		}
+
+		for j, p := range el.Points {
+			if badCoord(p.X, p.Y) {
+				errorf(i, "point %d (%g, %g) is out of range", j, p.X, p.Y)
+				break
+			}
+			if j > 0 {
+				q := el.Points[j-1]
+				if d := math.Hypot(p.X-q.X, p.Y-q.Y); d > maxVertexGap {
+					warnf(i, "points %d-%d are %.0fm apart", j-1, j, d)
+				}
+			}
+		}
+
+		if elementClosed(el.Type) {
+			if len(el.Points) < 3 {
+				errorf(i, "%s polygon has %d point(s), needs at least 3", mapTypeName(el.Type), len(el.Points))
+			} else if math.Abs(polygonArea(el.Points)) < 1e-6 {
+				errorf(i, "%s polygon has zero area", mapTypeName(el.Type))
+			}
+		} else if el.Type == MapTypePath && len(el.Points) == 0 {
+			warnf(i, "path has no points")
+		}
+	}
+
+	if m.Dock != nil && badCoord(m.Dock.X, m.Dock.Y) {
+		errorf(-1, "dock position (%g, %g) is out of range", m.Dock.X, m.Dock.Y)
+	}
+	for h := range m.AreaNames {
+		if _, ok := hashes[h]; !ok {
+			warnf(-1, "zone name for unknown hash %d", h)
+		}
+	}
+	for _, svg := range m.Svgs {
+		if i, ok := hashes[svg.Hash]; !ok || m.Elements[i].Type != MapTypeSvg {
+			warnf(-1, "svg %q references hash %d which is not an svg element", svg.FileName, svg.Hash)
+		}
+	}
+	return issues
+}
+
+// polygonArea is the signed shoelace area of a polygon (implicitly closed).
+func polygonArea(pts []MapPoint) float64 {
+	var a float64
+	for i := range pts {
+		j := (i + 1) % len(pts)
+		a += pts[i].X*pts[j].Y - pts[j].X*pts[i].Y
+	}
+	return a / 2
+}
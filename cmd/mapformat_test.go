@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadMapMigratesV1(t *testing.T) {
+	v1 := `{"formatVersion":1,"device":"old","downloadedAt":"2024-05-01T10:00:00Z",
+		"elements":[{"hash":1,"type":1,"points":[{"x":0,"y":0},{"x":1,"y":0},{"x":1,"y":1}]}]}`
+	path := filepath.Join(t.TempDir(), "v1.json")
+	if err := os.WriteFile(path, []byte(v1), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := LoadMap(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.FormatVersion != mapFormatVersion {
+		t.Errorf("expected migration to v%d, got v%d", mapFormatVersion, m.FormatVersion)
+	}
+	if m.Elements[0].TypeName != "obstacle" {
+		t.Errorf("expected typeName backfilled, got %q", m.Elements[0].TypeName)
+	}
+}
+
+func TestLoadMapKeepsLargeHashes(t *testing.T) {
+	// Device hashes use all 64 bits; a float64 round trip would corrupt them.
+	const hash = 5386962843296545234
+	for _, version := range []int{1, mapFormatVersion} {
+		data := fmt.Sprintf(`{"formatVersion":%d,
+			"elements":[{"hash":%d,"type":0,"points":[{"x":0,"y":0},{"x":1,"y":0},{"x":1,"y":1}]}],
+			"areaNames":{"%d":"Back"},"svgs":[{"hash":%d}]}`, version, int64(hash), int64(hash), int64(hash))
+		path := filepath.Join(t.TempDir(), "big.json")
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		m, err := LoadMap(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Elements[0].Hash != hash || m.AreaNames[hash] != "Back" || len(m.Svgs) != 1 || m.Svgs[0].Hash != hash {
+			t.Errorf("v%d: hashes changed: element %d, names %v, svgs %+v", version, m.Elements[0].Hash, m.AreaNames, m.Svgs)
+		}
+	}
+}
+
+func TestLoadMapRejectsNewerVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "future.json")
+	data := fmt.Sprintf(`{"formatVersion":%d,"elements":[]}`, mapFormatVersion+1)
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadMap(path); err == nil {
+		t.Error("expected an error for a map from a newer format version")
+	}
+}
+
+func TestLoadMapRejectsNull(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "null.json")
+	if err := os.WriteFile(path, []byte("null"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadMap(path); err == nil {
+		t.Error("expected an error for a map file holding null")
+	}
+}
+
+func TestValidateMap(t *testing.T) {
+	square := []MapPoint{{X: 0, Y: 0}, {X: 5, Y: 0}, {X: 5, Y: 5}, {X: 0, Y: 5}}
+	good := &MowerMap{FormatVersion: mapFormatVersion, Elements: []MapElement{
+		{Hash: 1, Type: MapTypeArea, TypeName: "area", Points: square},
+	}}
+	for _, issue := range ValidateMap(good) {
+		t.Errorf("unexpected issue on valid map: %s", issue)
+	}
+
+	bad := &MowerMap{FormatVersion: mapFormatVersion, Elements: []MapElement{
+		{Hash: 1, Type: MapTypeArea, Points: square},
+		{Hash: 1, Type: MapTypeObstacle, Points: []MapPoint{{X: 0, Y: 0}, {X: 1, Y: 1}}},
+		{Hash: 2, Type: MapTypePath, Points: []MapPoint{{X: 0, Y: 0}, {X: 1e6, Y: 0}}},
+	}}
+	var msgs []string
+	for _, issue := range ValidateMap(bad) {
+		if issue.Error {
+			msgs = append(msgs, issue.String())
+		}
+	}
+	joined := strings.Join(msgs, "\n")
+	for _, want := range []string{"duplicate hash 1", "needs at least 3", "out of range"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("expected error containing %q, got:\n%s", want, joined)
+		}
+	}
+}
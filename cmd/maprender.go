@@ -228,27 +228,41 @@ func (v *Viewport) ToPixel(x, y float64) (int, int) {
 	return int(math.Round(px)), v.pxH - 1 - int(math.Round(py))
 }
 
+// ToWorld converts pixel coordinates back to world meters (the inverse of
+// ToPixel).
+func (v *Viewport) ToWorld(px, py int) (float64, float64) {
+	x := (float64(px)-v.offX)/v.scale + v.MinX
+	y := (float64(v.pxH-1-py)-v.offY)/v.scale + v.MinY
+	return x, y
+}
+
 // Colors (ANSI 256)
 const (
-	colArea     = 40  // green — mowing area boundary
-	colObstacle = 196 // red — obstacle
-	colPath     = 178 // gold — channel/path
-	colTrailOld = 24  // dim blue — older trail
-	colTrailNew = 39  // bright blue — recent trail
-	colMower    = 231 // white
-	colDock     = 201 // magenta
-	colLabel    = 250 // grey
-	colPlanned  = 51  // cyan — planned coverage (zigzag) path
+	colArea         = 40  // green — mowing area boundary
+	colObstacle     = 196 // red — obstacle
+	colPath         = 178 // gold — channel/path
+	colTrailOld     = 24  // dim blue — older trail
+	colTrailNew     = 39  // bright blue — recent trail
+	colMower        = 231 // white
+	colDock         = 201 // magenta
+	colLabel        = 250 // grey
+	colPlanned      = 51  // cyan — planned coverage (zigzag) path
+	colSvg          = 141 // violet — SVG pattern overlay
+	colPercept      = 208 // orange — obstacles detected by perception
+	colCostLethal   = 202 // red-orange — blocked costmap cell
+	colCostInflated = 94  // brown — costmap inflation band
+	colBreakPoint   = 226 // yellow — where an interrupted job resumes
+	colRoute        = 213 // pink — go-to route and target
 )
 
 // elementColor maps a map element type to its render color.
 func elementColor(t int32) int {
 	switch t {
-	case 0:
+	case MapTypeArea:
 		return colArea
-	case 1:
+	case MapTypeObstacle:
 		return colObstacle
-	case 2:
+	case MapTypePath:
 		return colPath
 	default:
 		return colLabel
@@ -257,7 +271,7 @@ func elementColor(t int32) int {
 
 // elementClosed reports whether the element's polyline should be closed.
 func elementClosed(t int32) bool {
-	return t == 0 || t == 1 // areas and obstacles are polygons
+	return t == MapTypeArea || t == MapTypeObstacle // areas and obstacles are polygons
 }
 
 // DrawMap renders the elements of a MowerMap onto the canvas through the
@@ -288,7 +302,7 @@ func DrawMap(c *Canvas, v *Viewport, m *MowerMap, hidden map[int32]bool) {
 			x1, y1 := v.ToPixel(el.Points[0].X, el.Points[0].Y)
 			c.Line(x0, y0, x1, y1, col)
 		}
-		if el.Label != "" {
+		if label := m.ElementLabel(&el); label != "" {
 			// label at polygon centroid
 			var sx, sy float64
 			for _, p := range el.Points {
@@ -296,22 +310,49 @@ func DrawMap(c *Canvas, v *Viewport, m *MowerMap, hidden map[int32]bool) {
 				sy += p.Y
 			}
 			px, py := v.ToPixel(sx/float64(n), sy/float64(n))
-			c.OverlayString(px/2, py/4, el.Label, colLabel)
+			c.OverlayString(px/2, py/4, label, colLabel)
+		}
+	}
+	if !hidden[MapTypeSvg] {
+		for i := range m.Svgs {
+			if m.Svgs[i].Hidden {
+				continue
+			}
+			for _, shape := range m.Svgs[i].Outlines() {
+				DrawPolyline(c, v, shape, colSvg)
+			}
 		}
 	}
 	dock, _ := m.DockEstimate()
 	px, py := v.ToPixel(dock.X, dock.Y)
 	c.SetOverlay(px/2, py/4, '⌂', colDock)
+	DrawNotes(c, v, m.Notes)
+}
+
+// mowerColors tells mowers apart when pilot shows several: the first keeps
+// the usual white arrow and blue trail.
+var mowerColors = []struct{ mower, trailOld, trailNew int }{
+	{colMower, colTrailOld, colTrailNew},
+	{214, 130, 214}, // orange
+	{177, 90, 177},  // violet
+	{121, 29, 121},  // green
+	{227, 100, 227}, // yellow
+	{159, 30, 159},  // teal
 }
 
 // DrawTrail renders the mower's path history; the most recent segment is
 // brighter.
 func DrawTrail(c *Canvas, v *Viewport, trail []MapPoint) {
+	DrawTrailColor(c, v, trail, colTrailOld, colTrailNew)
+}
+
+// DrawTrailColor is DrawTrail in the given older and recent colours.
+func DrawTrailColor(c *Canvas, v *Viewport, trail []MapPoint, oldCol, newCol int) {
 	n := len(trail)
 	for i := 1; i < n; i++ {
-		col := colTrailOld
+		col := oldCol
 		if i > n-20 {
-			col = colTrailNew
+			col = newCol
 		}
 		x0, y0 := v.ToPixel(trail[i-1].X, trail[i-1].Y)
 		x1, y1 := v.ToPixel(trail[i].X, trail[i].Y)
@@ -331,8 +372,19 @@ func DrawPolyline(c *Canvas, v *Viewport, pts []MapPoint, col int) {
 // DrawMower places the mower arrow at world position with compass heading
 // (0° = north, clockwise).
 func DrawMower(c *Canvas, v *Viewport, x, y, headingDeg float64) {
+	DrawMowerColor(c, v, x, y, headingDeg, colMower)
+}
+
+// DrawMowerColor is DrawMower in the given colour.
+func DrawMowerColor(c *Canvas, v *Viewport, x, y, headingDeg float64, col int) {
+	px, py := v.ToPixel(x, y)
+	c.SetOverlay(px/2, py/4, headingArrow(headingDeg), col)
+}
+
+// DrawBreakPoint marks where an interrupted job will resume.
+func DrawBreakPoint(c *Canvas, v *Viewport, x, y float64) {
 	px, py := v.ToPixel(x, y)
-	c.SetOverlay(px/2, py/4, headingArrow(headingDeg), colMower)
+	c.SetOverlay(px/2, py/4, '✕', colBreakPoint)
 }
 
 // DrawOffScreenMarker draws an arrow clamped to the canvas border pointing
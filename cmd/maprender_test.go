@@ -162,3 +162,19 @@ func TestRenderSnapshotDoesNotPanic(t *testing.T) {
 		t.Error("expected placeholder output for empty map")
 	}
 }
+
+func TestDrawMapUsesAreaNames(t *testing.T) {
+	m := &MowerMap{
+		Elements: []MapElement{
+			{Hash: 7, Type: 0, Label: "area-1", Points: []MapPoint{{X: 0, Y: 0}, {X: 10, Y: 10}, {X: 10, Y: 0}}},
+		},
+		AreaNames: map[int64]string{7: "Front"},
+	}
+	c := NewCanvas(60, 20)
+	v := NewViewport(0, 0, 10, 10, c.PixelW(), c.PixelH(), 0.05)
+	DrawMap(c, v, m, nil)
+	out := strings.Join(c.Render(), "")
+	if !strings.Contains(out, "Front") || strings.Contains(out, "area-1") {
+		t.Error("expected the phone-app zone name to replace the device label")
+	}
+}
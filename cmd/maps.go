@@ -12,6 +12,7 @@ import (
 	"mammo/mammotion"
 	pb "mammo/proto"
 
+	tea "github.com/charmbracelet/bubbletea"
 	"github.com/spf13/cobra"
 	"google.golang.org/protobuf/proto"
 )
@@ -38,33 +39,77 @@ type MapElement struct {
 	Points   []MapPoint `json:"points"`
 }
 
+// MapSvg is an SVG pattern overlay (type 13 element) as placed by the phone
+// app: an SVG image of BaseWidthPix x BaseHeightPix pixels spanning
+// BaseWidthM x BaseHeightM metres, scaled, rotated (degrees) and centred at
+// XMove, YMove in map coordinates.
+type MapSvg struct {
+	Hash          int64   `json:"hash"`
+	FileName      string  `json:"fileName,omitempty"`
+	XMove         float64 `json:"xMove"`
+	YMove         float64 `json:"yMove"`
+	Scale         float64 `json:"scale"`
+	Rotate        float64 `json:"rotate"`
+	BaseWidthM    float64 `json:"baseWidthM"`
+	BaseHeightM   float64 `json:"baseHeightM"`
+	BaseWidthPix  int32   `json:"baseWidthPix"`
+	BaseHeightPix int32   `json:"baseHeightPix"`
+	Hidden        bool    `json:"hidden,omitempty"`
+	Data          string  `json:"data"`
+}
+
 // MowerMap is the local (downloadable/uploadable) map file format.
 type MowerMap struct {
-	FormatVersion int           `json:"formatVersion"`
-	Device        string        `json:"device"`
-	DeviceIotId   string        `json:"deviceIotId,omitempty"`
-	DownloadedAt  time.Time     `json:"downloadedAt"`
-	Dock          *DockPosition `json:"dock,omitempty"`
-	Elements      []MapElement  `json:"elements"`
+	FormatVersion int              `json:"formatVersion"`
+	Device        string           `json:"device"`
+	DeviceIotId   string           `json:"deviceIotId,omitempty"`
+	DownloadedAt  time.Time        `json:"downloadedAt"`
+	Dock          *DockPosition    `json:"dock,omitempty"`
+	Elements      []MapElement     `json:"elements"`
+	AreaNames     map[int64]string `json:"areaNames,omitempty"` // user-given zone names by hash
+	Svgs          []MapSvg         `json:"svgs,omitempty"`
+
+	// Notes are the user's annotations, kept in a sidecar file at NotesPath
+	// rather than in the map (see loadMapNotes).
+	Notes     []MapNote `json:"-"`
+	NotesPath string    `json:"-"`
 }
 
+// Map element type codes, as reported in NavGetCommDataAck.type.
+const (
+	MapTypeArea      int32 = 0  // mowing area boundary
+	MapTypeObstacle  int32 = 1  // obstacle / no-go zone
+	MapTypePath      int32 = 2  // channel between zones; a lone vertex is the charge point
+	MapTypeDumpPoint int32 = 12 // grass dump point
+	MapTypeSvg       int32 = 13 // SVG pattern overlay
+)
+
 func mapTypeName(t int32) string {
 	switch t {
-	case 0:
+	case MapTypeArea:
 		return "area"
-	case 1:
+	case MapTypeObstacle:
 		return "obstacle"
-	case 2:
+	case MapTypePath:
 		return "path"
-	case 12:
+	case MapTypeDumpPoint:
 		return "dump-point"
-	case 13:
+	case MapTypeSvg:
 		return "svg"
 	default:
 		return fmt.Sprintf("type-%d", t)
 	}
 }
 
+// ElementLabel is the name to show for an element: the zone name set in the
+// phone app if known, else the device's area label.
+func (m *MowerMap) ElementLabel(el *MapElement) string {
+	if name := m.AreaNames[el.Hash]; name != "" {
+		return name
+	}
+	return el.Label
+}
+
 // Bounds returns the world-coordinate extent of all map content.
 func (m *MowerMap) Bounds() (minX, minY, maxX, maxY float64, ok bool) {
 	first := true
@@ -107,7 +152,7 @@ func (m *MowerMap) DockEstimate() (MapPoint, string) {
 		return MapPoint{X: m.Dock.X, Y: m.Dock.Y}, "device"
 	}
 	for _, el := range m.Elements {
-		if el.Type == 2 && len(el.Points) == 1 {
+		if el.Type == MapTypePath && len(el.Points) == 1 {
 			return el.Points[0], "charge point"
 		}
 	}
@@ -131,16 +176,21 @@ func SaveMap(m *MowerMap, path string) error {
 	return os.WriteFile(path, data, 0644)
 }
 
+// LoadMap reads a map file, migrating older format versions to the current
+// one. Files from a newer mammo are refused rather than silently truncated.
 func LoadMap(path string) (*MowerMap, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
-	var m MowerMap
-	if err := json.Unmarshal(data, &m); err != nil {
+	m, err := decodeMap(data)
+	if err != nil {
 		return nil, fmt.Errorf("parse %s: %w", path, err)
 	}
-	return &m, nil
+	if err := loadMapNotes(m, path); err != nil {
+		return nil, fmt.Errorf("notes for %s: %w", path, err)
+	}
+	return m, nil
 }
 
 // buildFrameAck acknowledges a received map frame so the device sends the
@@ -194,6 +244,31 @@ func buildZigZagAck(zone int32, hash uint64, totalFrame, currentFrame int32) ([]
 	})
 }
 
+// buildSvgAck acknowledges a received SVG frame so the device sends the next
+// one (todev_svg_msg, mirroring the map-data ack).
+func buildSvgAck(hash uint64, totalFrame, currentFrame int32) ([]byte, error) {
+	return proto.Marshal(&pb.LubaMsg{
+		Msgtype:   pb.MsgCmdType_MSG_CMD_TYPE_NAV,
+		Sender:    pb.MsgDevice_DEV_MOBILEAPP,
+		Rcver:     pb.MsgDevice_DEV_MAINCTL,
+		Msgattr:   pb.MsgAttr_MSG_ATTR_REQ,
+		Seqs:      1,
+		Version:   1,
+		Subtype:   1,
+		Timestamp: uint64(time.Now().UnixMilli()),
+		LubaSubMsg: &pb.LubaMsg_Nav{Nav: &pb.MctlNav{
+			SubNavMsg: &pb.MctlNav_TodevSvgMsg{TodevSvgMsg: &pb.SvgMessageAckT{
+				Pver:         1,
+				SubCmd:       2,
+				TotalFrame:   totalFrame,
+				CurrentFrame: currentFrame,
+				DataHash:     hash,
+				Type:         13,
+			}},
+		}},
+	})
+}
+
 // FetchMap downloads the full map (all hashes, all frames) from the mower.
 // Progress messages go through report (may be nil). Read-only operation.
 func FetchMap(s *cloudSession, report func(string)) (*MowerMap, error) {
@@ -204,6 +279,8 @@ func FetchMap(s *cloudSession, report func(string)) (*MowerMap, error) {
 	hashCh := make(chan *mammotion.HashListData, 4)
 	mapCh := make(chan *mammotion.MapData, 256)
 	dockCh := make(chan DockPosition, 4)
+	namesCh := make(chan *mammotion.AreaNameData, 4)
+	svgCh := make(chan *mammotion.SvgData, 64)
 
 	s.stateManager.OnHashListReceived = func(h *mammotion.HashListData) {
 		select {
@@ -223,10 +300,24 @@ func FetchMap(s *cloudSession, report func(string)) (*MowerMap, error) {
 		default:
 		}
 	}
+	s.stateManager.OnAreaNamesReceived = func(n *mammotion.AreaNameData) {
+		select {
+		case namesCh <- n:
+		default:
+		}
+	}
+	s.stateManager.OnSvgReceived = func(sd *mammotion.SvgData) {
+		select {
+		case svgCh <- sd:
+		default:
+		}
+	}
 	defer func() {
 		s.stateManager.OnHashListReceived = nil
 		s.stateManager.OnMapDataReceived = nil
 		s.stateManager.OnChargePilePosition = nil
+		s.stateManager.OnAreaNamesReceived = nil
+		s.stateManager.OnSvgReceived = nil
 	}()
 
 	// Request the hash list.
@@ -235,7 +326,7 @@ func FetchMap(s *cloudSession, report func(string)) (*MowerMap, error) {
 	if err != nil {
 		return nil, err
 	}
-	if _, err := s.gateway.SendCloudCommand(s.device.IotId, hashListData); err != nil {
+	if err := s.send(hashListData); err != nil {
 		return nil, fmt.Errorf("request hash list: %w", err)
 	}
 
@@ -249,12 +340,22 @@ func FetchMap(s *cloudSession, report func(string)) (*MowerMap, error) {
 	report(fmt.Sprintf("Got %d map element(s) to fetch", len(hashes)))
 
 	m := &MowerMap{
-		FormatVersion: 1,
+		FormatVersion: mapFormatVersion,
 		Device:        s.device.DeviceName,
 		DeviceIotId:   s.device.IotId,
 		DownloadedAt:  time.Now(),
 	}
 
+	// Zone names are optional: older firmware doesn't answer, and the map is
+	// still usable with the device's own area labels.
+	report("Requesting zone names...")
+	if names, err := fetchAreaNames(s, namesCh); err != nil {
+		report(fmt.Sprintf("  zone names: %v — using device labels", err))
+	} else {
+		m.AreaNames = names
+		report(fmt.Sprintf("  got %d zone name(s)", len(names)))
+	}
+
 	for i, hash := range hashes {
 		el, err := fetchElement(s, hash, mapCh, report)
 		if err != nil {
@@ -263,7 +364,21 @@ func FetchMap(s *cloudSession, report func(string)) (*MowerMap, error) {
 		}
 		m.Elements = append(m.Elements, *el)
 		report(fmt.Sprintf("  element %d/%d: %s %q — %d points",
-			i+1, len(hashes), el.TypeName, el.Label, len(el.Points)))
+			i+1, len(hashes), el.TypeName, m.ElementLabel(el), len(el.Points)))
+	}
+
+	// SVG pattern overlays are fetched separately from their type-13 element.
+	for _, el := range m.Elements {
+		if el.Type != MapTypeSvg {
+			continue
+		}
+		svg, err := fetchSvg(s, el.Hash, svgCh, report)
+		if err != nil {
+			report(fmt.Sprintf("  svg (hash %d): %v — skipping", el.Hash, err))
+			continue
+		}
+		m.Svgs = append(m.Svgs, *svg)
+		report(fmt.Sprintf("  svg %q: %d bytes", svg.FileName, len(svg.Data)))
 	}
 
 	// Dock position may have arrived at any point during the session.
@@ -295,7 +410,7 @@ func fetchElement(s *cloudSession, hash int64, mapCh chan *mammotion.MapData, re
 	if err != nil {
 		return nil, err
 	}
-	if _, err := s.gateway.SendCloudCommand(s.device.IotId, reqData); err != nil {
+	if err := s.send(reqData); err != nil {
 		return nil, fmt.Errorf("request data: %w", err)
 	}
 
@@ -323,7 +438,7 @@ func fetchElement(s *cloudSession, hash int64, mapCh chan *mammotion.MapData, re
 			// Ack this frame to request the next.
 			if md.CurrentFrame < totalFrame {
 				if ack, err := buildFrameAck(hash, md.Type, md.TotalFrame, md.CurrentFrame); err == nil {
-					s.gateway.SendCloudCommand(s.device.IotId, ack)
+					s.send(ack)
 				}
 			}
 		case <-timeout:
@@ -357,6 +472,103 @@ assembled:
 	return el, nil
 }
 
+// fetchAreaNames requests the zone name table (toapp_all_hash_name).
+func fetchAreaNames(s *cloudSession, namesCh chan *mammotion.AreaNameData) (map[int64]string, error) {
+	reqData, err := mammotion.GetAreaNameList(s.device.IotId)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.send(reqData); err != nil {
+		return nil, fmt.Errorf("request names: %w", err)
+	}
+	select {
+	case n := <-namesCh:
+		return n.Names, nil
+	case <-time.After(5 * time.Second):
+		return nil, fmt.Errorf("no reply")
+	}
+}
+
+// fetchSvg requests all frames of one SVG overlay and joins the file data.
+func fetchSvg(s *cloudSession, hash int64, svgCh chan *mammotion.SvgData, report func(string)) (*MapSvg, error) {
+	for {
+		select {
+		case <-svgCh:
+			continue
+		default:
+		}
+		break
+	}
+
+	reqData, err := mammotion.GetSVGData(hash)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.send(reqData); err != nil {
+		return nil, fmt.Errorf("request svg: %w", err)
+	}
+
+	frames := make(map[int32]*mammotion.SvgData)
+	var totalFrame int32 = 1
+	timeout := time.After(20 * time.Second)
+
+collect:
+	for {
+		select {
+		case sd := <-svgCh:
+			if int64(sd.Hash) != hash {
+				continue
+			}
+			frames[sd.CurrentFrame] = sd
+			totalFrame = sd.TotalFrame
+			if int32(len(frames)) >= totalFrame {
+				break collect
+			}
+			if sd.CurrentFrame < totalFrame {
+				if ack, err := buildSvgAck(sd.Hash, sd.TotalFrame, sd.CurrentFrame); err == nil {
+					s.send(ack)
+				}
+			}
+		case <-timeout:
+			if len(frames) == 0 {
+				return nil, fmt.Errorf("no frames received")
+			}
+			report(fmt.Sprintf("  svg %d: timeout with %d/%d frames, keeping partial data", hash, len(frames), totalFrame))
+			break collect
+		}
+	}
+
+	var svg *MapSvg
+	var data strings.Builder
+	for f := int32(1); f <= totalFrame; f++ {
+		sd, ok := frames[f]
+		if !ok {
+			continue
+		}
+		if svg == nil {
+			svg = &MapSvg{
+				Hash:          hash,
+				FileName:      sd.FileName,
+				XMove:         sd.XMove,
+				YMove:         sd.YMove,
+				Scale:         sd.Scale,
+				Rotate:        sd.Rotate,
+				BaseWidthM:    sd.BaseWidthM,
+				BaseHeightM:   sd.BaseHeightM,
+				BaseWidthPix:  sd.BaseWidthPix,
+				BaseHeightPix: sd.BaseHeightPix,
+				Hidden:        sd.Hidden,
+			}
+		}
+		data.WriteString(sd.FileData)
+	}
+	if svg == nil {
+		return nil, fmt.Errorf("no frames in sequence")
+	}
+	svg.Data = data.String()
+	return svg, nil
+}
+
 // renderMapSnapshot draws a one-shot view of a map (plus optional mower
 // position) sized to the terminal, and returns the lines to print.
 func renderMapSnapshot(m *MowerMap, width, height int) []string {
@@ -415,16 +627,33 @@ var mapDownloadCmd = &cobra.Command{
 	},
 }
 
+var mapShowMeasure bool
+
 var mapShowCmd = &cobra.Command{
 	Use:   "map-show <map.json>",
 	Short: "Render a downloaded map file in the terminal (offline, high resolution)",
-	Args:  cobra.ExactArgs(1),
+	Long: `Renders a saved map in the terminal, with its annotations.
+
+--measure opens it full-screen in measure mode: move the cursor with the
+arrows or wasd (WASD for fine steps) and place points with enter or a click to
+read distances, bearings, and the area and perimeter of the polygon they make.
+n names an annotation at the cursor, such as a sprinkler head, and x removes
+the one under it; annotations are saved beside the map (lawn.notes.json) and
+drawn on every render of it, in map-show, pilot and coverage.`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		m, err := LoadMap(args[0])
 		if err != nil {
 			fmt.Println("Error:", err)
 			os.Exit(1)
 		}
+		if mapShowMeasure {
+			if _, err := tea.NewProgram(newMapMeasureModel(m), tea.WithAltScreen(), tea.WithMouseCellMotion()).Run(); err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(1)
+			}
+			return
+		}
 		width, height := terminalSize()
 		for _, line := range renderMapSnapshot(m, width, height-4) {
 			fmt.Println(line)
@@ -432,7 +661,50 @@ var mapShowCmd = &cobra.Command{
 		minX, minY, maxX, maxY, _ := m.Bounds()
 		fmt.Printf("%s — %d elements, %d points, %.1fm x %.1fm\n",
 			m.Device, len(m.Elements), m.PointCount(), maxX-minX, maxY-minY)
-		fmt.Println("Legend: green=area red=obstacle gold=path ⌂=dock")
+		fmt.Println("Legend: green=area red=obstacle gold=path violet=svg ⌂=dock ◆=annotation")
+		for _, n := range m.Notes {
+			fmt.Printf("  ◆ %-20s %.2f, %.2f\n", n.Name, n.X, n.Y)
+		}
+	},
+}
+
+var mapValidateCmd = &cobra.Command{
+	Use:   "map-validate <map.json>...",
+	Short: "Check map files for structural problems (types, polygons, hashes, coordinates)",
+	Long: `Validates map files against the MowerMap format (JSON Schema:
+docs/mowermap.schema.json). Older format versions are migrated on load.
+
+Checks: element type codes, closed polygons with non-zero area for areas
+and obstacles, duplicate hashes, coordinate sanity (finite, within 10km of
+the origin, no 500m jumps), and that zone names and SVG overlays refer to
+existing elements. Exits 1 if any file has errors.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		failed := false
+		for _, path := range args {
+			m, err := LoadMap(path)
+			if err != nil {
+				fmt.Printf("%s: error: %v\n", path, err)
+				failed = true
+				continue
+			}
+			issues := ValidateMap(m)
+			errors := 0
+			for _, issue := range issues {
+				fmt.Printf("%s: %s\n", path, issue)
+				if issue.Error {
+					errors++
+				}
+			}
+			if errors > 0 {
+				failed = true
+			}
+			fmt.Printf("%s: format v%d, %d element(s), %d error(s), %d warning(s)\n",
+				path, m.FormatVersion, len(m.Elements), errors, len(issues)-errors)
+		}
+		if failed {
+			os.Exit(1)
+		}
 	},
 }
 
@@ -446,6 +718,12 @@ var mapUploadCmd = &cobra.Command{
 			fmt.Println("Error:", err)
 			os.Exit(1)
 		}
+		for _, issue := range ValidateMap(m) {
+			if issue.Error {
+				fmt.Println("Map file is invalid:", issue)
+				os.Exit(1)
+			}
+		}
 		fmt.Printf("Map file is valid: %d element(s), %d points, device %q.\n",
 			len(m.Elements), m.PointCount(), m.Device)
 		fmt.Println()
@@ -466,6 +744,8 @@ var mapUploadCmd = &cobra.Command{
 func init() {
 	mapDownloadCmd.Flags().StringVarP(&mapDownloadOutput, "output", "o", "", "output file (default map-<timestamp>.json)")
 	rootCmd.AddCommand(mapDownloadCmd)
+	mapShowCmd.Flags().BoolVar(&mapShowMeasure, "measure", false, "open the map in measure mode to measure and annotate it")
 	rootCmd.AddCommand(mapShowCmd)
+	rootCmd.AddCommand(mapValidateCmd)
 	rootCmd.AddCommand(mapUploadCmd)
 }
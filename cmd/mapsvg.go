@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The SVG overlays the phone app places on the map are simple vector
+// patterns. Only the outline geometry matters for a braille render, so this is
+// a deliberately small reader: <path d>, <polygon>/<polyline> points and
+// <rect>. Curves are reduced to their end points.
+
+var (
+	svgPathRe  = regexp.MustCompile(`<path\b[^>]*\bd\s*=\s*"([^"]*)"`)
+	svgPointRe = regexp.MustCompile(`<(polygon|polyline)\b[^>]*\bpoints\s*=\s*"([^"]*)"`)
+	svgRectRe  = regexp.MustCompile(`<rect\b[^>]*>`)
+	svgAttrRe  = regexp.MustCompile(`\b(x|y|width|height)\s*=\s*"([-0-9.eE]+)"`)
+	svgTokenRe = regexp.MustCompile(`[MmLlHhVvZzCcSsQqTtAa]|[-+]?(?:\d+\.?\d*|\.\d+)(?:[eE][-+]?\d+)?`)
+)
+
+// svgShapes extracts outline polylines in SVG pixel coordinates. Closed shapes
+// repeat their first point at the end.
+func svgShapes(data string) [][]MapPoint {
+	var shapes [][]MapPoint
+	for _, m := range svgPathRe.FindAllStringSubmatch(data, -1) {
+		shapes = append(shapes, parseSvgPath(m[1])...)
+	}
+	for _, m := range svgPointRe.FindAllStringSubmatch(data, -1) {
+		nums := svgNumbers(m[2])
+		var pts []MapPoint
+		for i := 0; i+1 < len(nums); i += 2 {
+			pts = append(pts, MapPoint{X: nums[i], Y: nums[i+1]})
+		}
+		if m[1] == "polygon" && len(pts) > 2 {
+			pts = append(pts, pts[0])
+		}
+		if len(pts) > 1 {
+			shapes = append(shapes, pts)
+		}
+	}
+	for _, tag := range svgRectRe.FindAllString(data, -1) {
+		attrs := map[string]float64{}
+		for _, a := range svgAttrRe.FindAllStringSubmatch(tag, -1) {
+			attrs[a[1]], _ = strconv.ParseFloat(a[2], 64)
+		}
+		x, y, w, h := attrs["x"], attrs["y"], attrs["width"], attrs["height"]
+		if w <= 0 || h <= 0 {
+			continue
+		}
+		shapes = append(shapes, []MapPoint{{X: x, Y: y}, {X: x + w, Y: y}, {X: x + w, Y: y + h}, {X: x, Y: y + h}, {X: x, Y: y}})
+	}
+	return shapes
+}
+
+func svgNumbers(s string) []float64 {
+	var out []float64
+	for _, tok := range svgTokenRe.FindAllString(s, -1) {
+		if v, err := strconv.ParseFloat(tok, 64); err == nil {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// svgArgCount is the number of numeric arguments each path command consumes.
+var svgArgCount = map[byte]int{
+	'M': 2, 'L': 2, 'H': 1, 'V': 1, 'Z': 0, 'C': 6, 'S': 4, 'Q': 4, 'T': 2, 'A': 7,
+}
+
+// parseSvgPath converts path data into polylines, one per subpath.
+func parseSvgPath(d string) [][]MapPoint {
+	var shapes [][]MapPoint
+	var cur []MapPoint
+	var x, y, startX, startY float64
+	flush := func() {
+		if len(cur) > 1 {
+			shapes = append(shapes, cur)
+		}
+		cur = nil
+	}
+
+	toks := svgTokenRe.FindAllString(d, -1)
+	var cmd byte
+	for i := 0; i < len(toks); {
+		if c := toks[i][0]; strings.ContainsRune("MmLlHhVvZzCcSsQqTtAa", rune(c)) && len(toks[i]) == 1 {
+			cmd = c
+			i++
+		} else if cmd == 0 {
+			i++ // numbers before any command
+			continue
+		}
+		upper := cmd &^ 0x20
+		rel := cmd != upper
+		n := svgArgCount[upper]
+		if upper == 'Z' {
+			if len(cur) > 0 {
+				cur = append(cur, MapPoint{X: startX, Y: startY})
+			}
+			x, y = startX, startY
+			flush()
+			// Z takes no arguments: numbers after it without a new command
+			// are skipped like those before the first one.
+			cmd = 0
+			continue
+		}
+		if i+n > len(toks) {
+			break
+		}
+		args := make([]float64, n)
+		bad := false
+		for j := 0; j < n; j++ {
+			v, err := strconv.ParseFloat(toks[i+j], 64)
+			if err != nil {
+				bad = true
+				break
+			}
+			args[j] = v
+		}
+		if bad {
+			break
+		}
+		i += n
+
+		nx, ny := x, y
+		switch upper {
+		case 'H':
+			nx = args[0]
+			if rel {
+				nx += x
+			}
+		case 'V':
+			ny = args[0]
+			if rel {
+				ny += y
+			}
+		default:
+			// The end point is always the last coordinate pair.
+			nx, ny = args[n-2], args[n-1]
+			if rel {
+				nx += x
+				ny += y
+			}
+		}
+		if upper == 'M' {
+			flush()
+			startX, startY = nx, ny
+			// Further pairs after a moveto are implicit linetos.
+			if rel {
+				cmd = 'l'
+			} else {
+				cmd = 'L'
+			}
+		}
+		x, y = nx, ny
+		cur = append(cur, MapPoint{X: x, Y: y})
+	}
+	flush()
+	return shapes
+}
+
+// svgToWorld maps a point in SVG pixel space to map metres using the
+// overlay's placement: centred on the image, Y flipped (SVG grows down),
+// scaled, rotated clockwise by Rotate degrees, then moved to XMove, YMove.
+func svgToWorld(s *MapSvg, p MapPoint) MapPoint {
+	mpx, mpy := 1.0, 1.0
+	if s.BaseWidthPix > 0 && s.BaseWidthM > 0 {
+		mpx = s.BaseWidthM / float64(s.BaseWidthPix)
+	}
+	if s.BaseHeightPix > 0 && s.BaseHeightM > 0 {
+		mpy = s.BaseHeightM / float64(s.BaseHeightPix)
+	}
+	scale := s.Scale
+	if scale == 0 {
+		scale = 1
+	}
+	lx := (p.X - float64(s.BaseWidthPix)/2) * mpx * scale
+	ly := -(p.Y - float64(s.BaseHeightPix)/2) * mpy * scale
+	rad := -s.Rotate * math.Pi / 180
+	cos, sin := math.Cos(rad), math.Sin(rad)
+	return MapPoint{
+		X: lx*cos - ly*sin + s.XMove,
+		Y: lx*sin + ly*cos + s.YMove,
+	}
+}
+
+// Outlines returns the overlay's shapes in map coordinates.
+func (s *MapSvg) Outlines() [][]MapPoint {
+	shapes := svgShapes(s.Data)
+	for _, shape := range shapes {
+		for i := range shape {
+			shape[i] = svgToWorld(s, shape[i])
+		}
+	}
+	return shapes
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSvgPathParsing(t *testing.T) {
+	shapes := svgShapes(`<svg><path d="M10 10 h 20 v20 H10 z M0,0 L5,5"/><polygon points="1,1 2,1 2,2"/></svg>`)
+	if len(shapes) != 3 {
+		t.Fatalf("expected 3 shapes, got %d: %v", len(shapes), shapes)
+	}
+	sq := shapes[0]
+	if len(sq) != 5 || sq[2] != (MapPoint{X: 30, Y: 30}) || sq[4] != sq[0] {
+		t.Errorf("closed square path parsed wrong: %v", sq)
+	}
+	if tri := shapes[2]; len(tri) != 4 || tri[3] != tri[0] {
+		t.Errorf("polygon should be closed: %v", tri)
+	}
+}
+
+func TestSvgPathNumbersAfterClose(t *testing.T) {
+	done := make(chan [][]MapPoint)
+	go func() { done <- parseSvgPath("M0 0 L1 1 Z 5 5 L2 2") }()
+	select {
+	case shapes := <-done:
+		if len(shapes) != 1 || len(shapes[0]) != 3 {
+			t.Errorf("expected the closed path only, got %v", shapes)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("parseSvgPath did not return for numbers after Z")
+	}
+}
+
+func TestSvgToWorldPlacement(t *testing.T) {
+	s := &MapSvg{XMove: 100, YMove: 50, Scale: 1, BaseWidthM: 10, BaseHeightM: 10, BaseWidthPix: 100, BaseHeightPix: 100}
+	centre := svgToWorld(s, MapPoint{X: 50, Y: 50})
+	if math.Abs(centre.X-100) > 1e-9 || math.Abs(centre.Y-50) > 1e-9 {
+		t.Errorf("image centre should land on the move offset, got %v", centre)
+	}
+	// SVG y grows downward; the top edge of the image is north of centre.
+	top := svgToWorld(s, MapPoint{X: 50, Y: 0})
+	if math.Abs(top.Y-55) > 1e-9 {
+		t.Errorf("top edge should be 5m north, got %v", top)
+	}
+}
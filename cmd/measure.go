@@ -0,0 +1,279 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// colMeasure draws measure mode's points, legs and cursor.
+const colMeasure = 220 // light gold
+
+// measureTool is the measure mode of pilot and map-show: a cursor, the points
+// placed with it, and an annotation being named.
+type measureTool struct {
+	on     bool
+	cursor MapPoint
+	points []MapPoint
+	naming bool // typing an annotation's name
+	name   string
+}
+
+// start turns measure mode on with the cursor at x, y.
+func (t *measureTool) start(x, y float64) {
+	*t = measureTool{on: true, cursor: MapPoint{X: x, Y: y}}
+}
+
+// key handles a key in measure mode, reporting whether it was one. step is
+// the cursor step in metres. Annotations are added to and removed from mm
+// and saved straight away; the returned status describes what happened.
+func (t *measureTool) key(key string, step float64, mm *MowerMap) (string, bool) {
+	if t.naming {
+		switch key {
+		case "enter":
+			t.naming = false
+			name := strings.TrimSpace(t.name)
+			if name == "" {
+				return "annotation cancelled: no name", true
+			}
+			if mm == nil {
+				return "annotation needs a map", true
+			}
+			mm.Notes = append(mm.Notes, MapNote{Name: name, X: t.cursor.X, Y: t.cursor.Y, Created: time.Now()})
+			if err := saveMapNotes(mm); err != nil {
+				return fmt.Sprintf("annotation %q added but not saved: %v", name, err), true
+			}
+			return fmt.Sprintf("annotation %q saved to %s", name, mm.NotesPath), true
+		case "esc":
+			t.naming = false
+			return "annotation cancelled", true
+		case "backspace":
+			if _, size := utf8.DecodeLastRuneInString(t.name); size > 0 {
+				t.name = t.name[:len(t.name)-size]
+			}
+		default:
+			if utf8.RuneCountInString(key) == 1 {
+				t.name += key
+			}
+		}
+		return "", true
+	}
+
+	switch key {
+	case "up", "w":
+		t.cursor.Y += step
+	case "down", "s":
+		t.cursor.Y -= step
+	case "left", "a":
+		t.cursor.X -= step
+	case "right", "d":
+		t.cursor.X += step
+	case "W":
+		t.cursor.Y += step / 4
+	case "S":
+		t.cursor.Y -= step / 4
+	case "A":
+		t.cursor.X -= step / 4
+	case "D":
+		t.cursor.X += step / 4
+	case "enter":
+		t.points = append(t.points, t.cursor)
+	case "backspace", "u":
+		if len(t.points) > 0 {
+			t.points = t.points[:len(t.points)-1]
+		}
+	case "c":
+		t.points = nil
+		return "measure points cleared", true
+	case "n":
+		t.naming, t.name = true, ""
+	case "x":
+		if mm == nil {
+			return "no annotations without a map", true
+		}
+		i := nearestNote(mm.Notes, t.cursor.X, t.cursor.Y, math.Max(1, 2*step))
+		if i < 0 {
+			return "no annotation under the cursor", true
+		}
+		name := mm.Notes[i].Name
+		mm.Notes = append(mm.Notes[:i:i], mm.Notes[i+1:]...)
+		if err := saveMapNotes(mm); err != nil {
+			return fmt.Sprintf("annotation %q removed but not saved: %v", name, err), true
+		}
+		return fmt.Sprintf("annotation %q removed", name), true
+	default:
+		return "", false
+	}
+	return "", true
+}
+
+// readout describes the measurement for a header: the cursor, its distance
+// and bearing from the mower when from is set, the leg from the last point,
+// the path's length, and with three or more points the area and perimeter of
+// the polygon they make.
+func (t measureTool) readout(from *MapPoint, mm *MowerMap) string {
+	if t.naming {
+		return fmt.Sprintf("name the annotation at %.2f, %.2f: %s▏", t.cursor.X, t.cursor.Y, t.name)
+	}
+	parts := []string{fmt.Sprintf("cursor %.2f, %.2f", t.cursor.X, t.cursor.Y)}
+	if from != nil {
+		parts = append(parts, fmt.Sprintf("mower→ %.2fm %03.0f°",
+			math.Hypot(t.cursor.X-from.X, t.cursor.Y-from.Y), bearingTo(from.X, from.Y, t.cursor.X, t.cursor.Y)))
+	}
+	if n := len(t.points); n > 0 {
+		last := t.points[n-1]
+		parts = append(parts, fmt.Sprintf("leg %.2fm %03.0f°",
+			math.Hypot(t.cursor.X-last.X, t.cursor.Y-last.Y), bearingTo(last.X, last.Y, t.cursor.X, t.cursor.Y)))
+		parts = append(parts, fmt.Sprintf("path %.2fm (%d pts)", routeLength(append(t.points[:n:n], t.cursor)), n))
+	}
+	if len(t.points) >= 3 {
+		parts = append(parts, fmt.Sprintf("area %.1fm² perim %.1fm",
+			math.Abs(polygonArea(t.points)), polygonEdgeLength(t.points)))
+	}
+	if mm != nil {
+		if i := nearestNote(mm.Notes, t.cursor.X, t.cursor.Y, 0.5); i >= 0 {
+			parts = append(parts, "on "+mm.Notes[i].Name)
+		}
+	}
+	return strings.Join(parts, " │ ")
+}
+
+// draw overlays the points, the legs through them to the cursor, the
+// polygon's closing edge, and the cursor.
+func (t measureTool) draw(c *Canvas, v *Viewport) {
+	n := len(t.points)
+	DrawPolyline(c, v, append(t.points[:n:n], t.cursor), colMeasure)
+	if n >= 3 {
+		DrawPolyline(c, v, []MapPoint{t.points[n-1], t.points[0]}, colMeasure)
+	}
+	for _, p := range t.points {
+		px, py := v.ToPixel(p.X, p.Y)
+		c.SetOverlay(px/2, py/4, '•', colMeasure)
+	}
+	px, py := v.ToPixel(t.cursor.X, t.cursor.Y)
+	c.SetOverlay(px/2, py/4, '+', colMeasure)
+}
+
+// help is the help line while measuring.
+func (t measureTool) help() string {
+	if t.naming {
+		return " type a name · enter saves · esc cancels"
+	}
+	return " wasd/arrows move (WASD fine) · enter/click place point · u undo · c clear · n name annotation · x remove annotation · esc done"
+}
+
+// mapMeasureModel is map-show --measure: the map full-screen with measure
+// mode always on.
+type mapMeasureModel struct {
+	mowerMap      *MowerMap
+	width, height int
+	zoom          float64
+	panX, panY    float64
+	measure       measureTool
+	status        string
+}
+
+func newMapMeasureModel(m *MowerMap) mapMeasureModel {
+	mm := mapMeasureModel{mowerMap: m, zoom: 1, status: "measuring: enter places points, n names an annotation, q quits"}
+	minX, minY, maxX, maxY, _ := m.Bounds()
+	mm.measure.start((minX+maxX)/2, (minY+maxY)/2)
+	return mm
+}
+
+func (m mapMeasureModel) Init() tea.Cmd { return nil }
+
+// mapView lays out the canvas below the header and fits the map, with the
+// zoom and pan applied.
+func (m mapMeasureModel) mapView() (*Canvas, *Viewport) {
+	canvas := NewCanvas(m.width, max(5, m.height-2))
+	minX, minY, maxX, maxY, ok := m.mowerMap.Bounds()
+	if !ok {
+		return canvas, nil
+	}
+	vp := NewViewport(minX, minY, maxX, maxY, canvas.PixelW(), canvas.PixelH(), 0.05)
+	if m.zoom != 1 {
+		vp.Zoom(m.zoom)
+	}
+	if m.panX != 0 || m.panY != 0 {
+		vp.Pan(m.panX, m.panY)
+	}
+	return canvas, vp
+}
+
+func (m mapMeasureModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+
+	case tea.MouseMsg:
+		if msg.Action != tea.MouseActionPress || msg.Button != tea.MouseButtonLeft || m.measure.naming {
+			break
+		}
+		if c, vp := m.mapView(); vp != nil && msg.X < c.W && msg.Y >= 1 && msg.Y-1 < c.H {
+			x, y := vp.ToWorld(msg.X*2+1, (msg.Y-1)*4+2)
+			m.measure.cursor = MapPoint{X: x, Y: y}
+			m.measure.points = append(m.measure.points, m.measure.cursor)
+		}
+
+	case tea.KeyMsg:
+		key := msg.String()
+		if key == "ctrl+c" || key == "q" && !m.measure.naming {
+			return m, tea.Quit
+		}
+		step := 0.5
+		if _, vp := m.mapView(); vp != nil {
+			step = 4 * vp.MetersPerPixel()
+		}
+		if status, ok := m.measure.key(key, step, m.mowerMap); ok {
+			if status != "" {
+				m.status = status
+			}
+			break
+		}
+		switch key {
+		case "+", "=":
+			m.zoom *= 1.5
+			if m.zoom > 64 {
+				m.zoom = 64
+			}
+		case "-", "_":
+			m.zoom /= 1.5
+			if m.zoom < 1 {
+				m.zoom = 1
+			}
+		case "0":
+			m.zoom = 1
+			m.panX, m.panY = 0, 0
+		case "h":
+			m.panX -= 0.15 / m.zoom
+		case "l":
+			m.panX += 0.15 / m.zoom
+		case "j":
+			m.panY -= 0.15 / m.zoom
+		case "k":
+			m.panY += 0.15 / m.zoom
+		}
+	}
+	return m, nil
+}
+
+func (m mapMeasureModel) View() string {
+	if m.width == 0 {
+		return "starting..."
+	}
+	canvas, vp := m.mapView()
+	if vp != nil {
+		DrawMap(canvas, vp, m.mowerMap, nil)
+		m.measure.draw(canvas, vp)
+	}
+	header := pilotHeaderStyle.Render(fmt.Sprintf(" MEASURE │ %s │ zoom %.1fx │ %s", m.measure.readout(nil, m.mowerMap), m.zoom, m.status))
+	help := m.measure.help()
+	if !m.measure.naming {
+		help += " · +- zoom · hjkl pan · 0 fit · q quit"
+	}
+	return header + "\n" + strings.Join(canvas.Render(), "\n") + "\n" + pilotHelpStyle.Render(help)
+}
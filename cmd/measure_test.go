@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestPilotMeasureAndNotes(t *testing.T) {
+	dir := t.TempDir()
+	mapFile := filepath.Join(dir, "lawn.json")
+	if err := SaveMap(simLawn(), mapFile); err != nil {
+		t.Fatal(err)
+	}
+	lawn, err := LoadMap(mapFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, _ := newSimSession(lawn)
+	mc := &motionController{session: s, notify: func(string) {}}
+	var model tea.Model = pilotModel{session: s, motion: mc, speed: 400, turnRate: 450, minBattery: 15, zoom: 1}
+	key := func(k string) {
+		t.Helper()
+		var msg tea.KeyMsg
+		switch k {
+		case "enter":
+			msg = tea.KeyMsg{Type: tea.KeyEnter}
+		case "esc":
+			msg = tea.KeyMsg{Type: tea.KeyEsc}
+		default:
+			msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+		}
+		model, _ = model.Update(msg)
+	}
+	at := func(x, y float64) {
+		m := model.(pilotModel)
+		m.measure.cursor = MapPoint{X: x, Y: y}
+		model = m
+	}
+	for _, msg := range []tea.Msg{
+		tea.WindowSizeMsg{Width: 100, Height: 30},
+		pilotMapMsg(lawn),
+		pilotPosMsg{x: 10, y: 5, heading: 90, posType: 4},
+	} {
+		model, _ = model.Update(msg)
+	}
+
+	key("m")
+	if m := model.(pilotModel); !m.measure.on || m.measure.cursor != (MapPoint{X: 10, Y: 5}) {
+		t.Fatalf("measure mode %v, cursor %+v", m.measure.on, m.measure.cursor)
+	}
+	// Driving keys move the cursor instead.
+	key("w")
+	if m := model.(pilotModel); m.measure.cursor.Y <= 5 || m.motion.linear != 0 {
+		t.Errorf("w moved cursor to %+v, linear %d", m.measure.cursor, m.motion.linear)
+	}
+	at(13, 1)
+	if r := model.(pilotModel).measure.readout(&MapPoint{X: 10, Y: 5}, lawn); !strings.Contains(r, "mower→ 5.00m 143°") {
+		t.Errorf("readout %q", r)
+	}
+
+	for _, p := range []MapPoint{{X: 0, Y: 0}, {X: 4, Y: 0}, {X: 4, Y: 3}} {
+		at(p.X, p.Y)
+		key("enter")
+	}
+	if r := model.(pilotModel).measure.readout(nil, lawn); !strings.Contains(r, "area 6.0m² perim 12.0m") || !strings.Contains(r, "path 7.00m (3 pts)") {
+		t.Errorf("readout %q", r)
+	}
+	key("u")
+	if n := len(model.(pilotModel).measure.points); n != 2 {
+		t.Errorf("after undo %d points", n)
+	}
+
+	// Name an annotation: it is saved beside the map file.
+	at(6, 12)
+	key("n")
+	for _, k := range strings.Split("Sprinkler", "") {
+		key(k)
+	}
+	key("enter")
+	if st := model.(pilotModel).status; !strings.Contains(st, "lawn.notes.json") {
+		t.Errorf("status %q", st)
+	}
+	reloaded, err := LoadMap(mapFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reloaded.Notes) != 1 || reloaded.Notes[0].Name != "Sprinkler" || reloaded.Notes[0].X != 6 {
+		t.Fatalf("reloaded notes %+v", reloaded.Notes)
+	}
+	key("esc")
+	if m := model.(pilotModel); m.measure.on {
+		t.Error("esc left measure mode on")
+	}
+	if v := model.View(); !strings.Contains(v, "◆Sprinkler") {
+		t.Errorf("annotation not drawn:\n%s", v)
+	}
+
+	// x removes the annotation under the cursor.
+	key("m")
+	at(6.2, 12)
+	key("x")
+	if reloaded, _ = LoadMap(mapFile); len(reloaded.Notes) != 0 {
+		t.Errorf("notes after removal %+v", reloaded.Notes)
+	}
+}
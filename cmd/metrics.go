@@ -0,0 +1,269 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"mammo/mammotion"
+
+	"github.com/spf13/cobra"
+)
+
+// metricDef describes one exported metric family.
+type metricDef struct {
+	name string
+	kind string // "gauge" or "counter"
+	help string
+}
+
+// mowerMetricDefs are the families serve-metrics exports, in output order.
+// Every sample carries a device label.
+var mowerMetricDefs = []metricDef{
+	{"mammo_battery_percent", "gauge", "Battery charge (rpt_dev_status.battery_val)."},
+	{"mammo_sys_status", "gauge", "Device work state (rpt_dev_status.sys_status; 13 working, 15 charging, 19 paused)."},
+	{"mammo_charge_state", "gauge", "Charging state (rpt_dev_status.charge_state; non-zero on the dock)."},
+	{"mammo_position_x_meters", "gauge", "Mower X position in the map frame."},
+	{"mammo_position_y_meters", "gauge", "Mower Y position in the map frame."},
+	{"mammo_position_fix_type", "gauge", "Position fix type (4 RTK fix, 5 RTK float, 1 DGPS, 0 GPS)."},
+	{"mammo_rtk_status", "gauge", "RTK receiver status (rpt_rtk.status)."},
+	{"mammo_rtk_pos_level", "gauge", "RTK position level (rpt_rtk.pos_level)."},
+	{"mammo_rtk_satellites", "gauge", "Satellites tracked (rpt_rtk.gps_stars)."},
+	{"mammo_rtk_l2_satellites", "gauge", "Satellites tracked on L2 (rpt_rtk.l2_stars)."},
+	{"mammo_rtk_coview_satellites", "gauge", "Satellites seen by both mower and base (rpt_rtk.co_view_stars)."},
+	{"mammo_rtk_age", "gauge", "Age of the RTK corrections as reported (rpt_rtk.age)."},
+	{"mammo_job_progress_percent", "gauge", "Share of the current job done (rpt_work.area, high 16 bits)."},
+	{"mammo_job_area_square_meters", "gauge", "Area of the current job (rpt_work.area, low 16 bits)."},
+	{"mammo_job_elapsed_minutes", "gauge", "Time spent on the current job (rpt_work.progress, high 16 bits)."},
+	{"mammo_job_total_minutes", "gauge", "Estimated total time of the current job (rpt_work.progress, low 16 bits)."},
+	{"mammo_knife_height_mm", "gauge", "Cutting height (rpt_work.knife_height)."},
+	{"mammo_mileage_total", "counter", "Lifetime distance in the device's units (rpt_maintain.mileage)."},
+	{"mammo_work_time_total", "counter", "Lifetime work time in the device's units (rpt_maintain.work_time)."},
+	{"mammo_battery_cycles_total", "counter", "Battery charge cycles (rpt_maintain.bat_cycles)."},
+	{"mammo_connect_type", "gauge", "Link in use (rpt_connect_status.connect_type)."},
+	{"mammo_wifi_rssi_dbm", "gauge", "Wi-Fi signal strength."},
+	{"mammo_ble_rssi_dbm", "gauge", "Bluetooth signal strength."},
+	{"mammo_mnet_rssi_dbm", "gauge", "Cellular signal strength."},
+	{"mammo_last_report_timestamp_seconds", "gauge", "Unix time of the last message from the device."},
+	{"mammo_messages_total", "counter", "Messages received from the device."},
+	{"mammo_mqtt_connects_total", "counter", "MQTT connections made to the cloud broker."},
+	{"mammo_mqtt_disconnects_total", "counter", "MQTT connections lost."},
+	{"mammo_mqtt_messages_total", "counter", "MQTT messages received from the cloud broker."},
+	{"mammo_mqtt_message_errors_total", "counter", "MQTT messages that failed to parse."},
+	{"mammo_cloud_commands_total", "counter", "Commands sent through the cloud IoT gateway."},
+	{"mammo_cloud_command_errors_total", "counter", "Cloud commands that failed or were rejected."},
+}
+
+// metricSet holds the latest value of each metric per device.
+type metricSet struct {
+	mu     sync.Mutex
+	values map[string]map[string]float64 // name → device → value
+}
+
+func newMetricSet() *metricSet {
+	return &metricSet{values: make(map[string]map[string]float64)}
+}
+
+func (ms *metricSet) set(name, device string, v float64) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	byDevice := ms.values[name]
+	if byDevice == nil {
+		byDevice = make(map[string]float64)
+		ms.values[name] = byDevice
+	}
+	byDevice[device] = v
+}
+
+func (ms *metricSet) add(name, device string, delta float64) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	byDevice := ms.values[name]
+	if byDevice == nil {
+		byDevice = make(map[string]float64)
+		ms.values[name] = byDevice
+	}
+	byDevice[device] += delta
+}
+
+// writeTo writes the Prometheus text exposition format. Families with no
+// samples yet are left out.
+func (ms *metricSet) writeTo(w io.Writer, defs []metricDef) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	var b strings.Builder
+	for _, def := range defs {
+		byDevice := ms.values[def.name]
+		if len(byDevice) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", def.name, def.help, def.name, def.kind)
+		devices := make([]string, 0, len(byDevice))
+		for d := range byDevice {
+			devices = append(devices, d)
+		}
+		sort.Strings(devices)
+		for _, d := range devices {
+			fmt.Fprintf(&b, "%s{device=%s} %s\n", def.name, strconv.Quote(d), strconv.FormatFloat(byDevice[d], 'g', -1, 64))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// watchMetrics feeds ms from the session's decoded reports. The returned
+// collect func copies the cloud counters in and is called before each
+// scrape; it is a no-op for transports without them.
+func watchMetrics(s *cloudSession, ms *metricSet) (collect func(), unsubscribe func()) {
+	dev := s.device.DeviceName
+	sm := s.stateManager
+	sm.OnPropertiesReceived = func() {
+		ms.set("mammo_battery_percent", dev, float64(s.mowingDevice.BatteryPercentage))
+	}
+	sm.OnDeviceStatus = func(sysStatus, chargeState int32) {
+		ms.set("mammo_sys_status", dev, float64(sysStatus))
+		ms.set("mammo_charge_state", dev, float64(chargeState))
+	}
+	sm.OnPositionUpdate = func(x, y float32, _ int32, posType int32) {
+		// RealPos is in 0.1mm units.
+		ms.set("mammo_position_x_meters", dev, float64(x)/10000)
+		ms.set("mammo_position_y_meters", dev, float64(y)/10000)
+		ms.set("mammo_position_fix_type", dev, float64(posType))
+	}
+	sm.OnRtkReport = func(r *mammotion.RtkData) {
+		ms.set("mammo_rtk_status", dev, float64(r.Status))
+		ms.set("mammo_rtk_pos_level", dev, float64(r.PosLevel))
+		ms.set("mammo_rtk_satellites", dev, float64(r.GpsStars))
+		ms.set("mammo_rtk_l2_satellites", dev, float64(r.L2Stars))
+		ms.set("mammo_rtk_coview_satellites", dev, float64(r.CoViewStars))
+		ms.set("mammo_rtk_age", dev, float64(r.Age))
+	}
+	sm.OnWorkReport = func(w *mammotion.WorkData) {
+		ms.set("mammo_job_progress_percent", dev, float64(w.Area>>16))
+		ms.set("mammo_job_area_square_meters", dev, float64(w.Area&0xffff))
+		ms.set("mammo_job_elapsed_minutes", dev, float64(w.Progress>>16))
+		ms.set("mammo_job_total_minutes", dev, float64(w.Progress&0xffff))
+		ms.set("mammo_knife_height_mm", dev, float64(w.KnifeHeight))
+	}
+	sm.OnMaintainReport = func(m *mammotion.MaintainData) {
+		ms.set("mammo_mileage_total", dev, float64(m.Mileage))
+		ms.set("mammo_work_time_total", dev, float64(m.WorkTime))
+		ms.set("mammo_battery_cycles_total", dev, float64(m.BatCycles))
+	}
+	sm.OnConnectReport = func(c *mammotion.ConnectData) {
+		ms.set("mammo_connect_type", dev, float64(c.ConnectType))
+		ms.set("mammo_wifi_rssi_dbm", dev, float64(c.WifiRssi))
+		ms.set("mammo_ble_rssi_dbm", dev, float64(c.BleRssi))
+		ms.set("mammo_mnet_rssi_dbm", dev, float64(c.MnetRssi))
+	}
+	unsubscribe = s.transport.Subscribe(func([]byte) {
+		ms.add("mammo_messages_total", dev, 1)
+		ms.set("mammo_last_report_timestamp_seconds", dev, float64(time.Now().UnixMilli())/1000)
+	})
+
+	collect = func() {
+		st, ok := s.transport.(interface{ Stats() mammotion.CloudStats })
+		if !ok {
+			return
+		}
+		c := st.Stats()
+		ms.set("mammo_mqtt_connects_total", dev, float64(c.Connects))
+		ms.set("mammo_mqtt_disconnects_total", dev, float64(c.Disconnects))
+		ms.set("mammo_mqtt_messages_total", dev, float64(c.Messages))
+		ms.set("mammo_mqtt_message_errors_total", dev, float64(c.MessageErrors))
+		ms.set("mammo_cloud_commands_total", dev, float64(c.Commands))
+		ms.set("mammo_cloud_command_errors_total", dev, float64(c.CommandErrors))
+	}
+	return collect, unsubscribe
+}
+
+// watchSessionsMetrics is watchMetrics for each session, with one collect
+// and one unsubscribe covering them all.
+func watchSessionsMetrics(sessions []*cloudSession, ms *metricSet) (collect func(), unsubscribe func()) {
+	var collects, unsubscribes []func()
+	for _, s := range sessions {
+		c, u := watchMetrics(s, ms)
+		collects = append(collects, c)
+		unsubscribes = append(unsubscribes, u)
+	}
+	collect = func() {
+		for _, c := range collects {
+			c()
+		}
+	}
+	unsubscribe = func() {
+		for _, u := range unsubscribes {
+			u()
+		}
+	}
+	return collect, unsubscribe
+}
+
+// metricsHandler serves ms at /metrics, calling collect before each scrape.
+func metricsHandler(ms *metricSet, collect func()) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		collect()
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		ms.writeTo(w, mowerMetricDefs)
+	})
+	return mux
+}
+
+var (
+	metricsListen  string
+	metricsDevices []string
+)
+
+var serveMetricsCmd = &cobra.Command{
+	Use:   "serve-metrics",
+	Short: "Serve mower telemetry as Prometheus metrics on /metrics",
+	Long: `Keeps a session open, polls the mower's reports and serves the latest
+values at http://<listen>/metrics in the Prometheus text format: battery, work
+and charge state, position and fix type, RTK satellites and correction age,
+job progress, cutting height, lifetime mileage, work time and battery cycles,
+link signal strength, and counters for MQTT connections, messages and cloud
+command errors. Every sample is labelled with the device name; --devices
+exports several mowers from one server. Runs until Ctrl-C.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		withSessions(metricsDevices, func(sessions []*cloudSession) error {
+			ms := newMetricSet()
+			collect, unsubscribe := watchSessionsMetrics(sessions, ms)
+			defer unsubscribe()
+			names := make([]string, len(sessions))
+			for i, s := range sessions {
+				stopPolling := startPolling(s)
+				defer stopPolling()
+				names[i] = s.device.DeviceName
+			}
+
+			srv := &http.Server{Addr: metricsListen, Handler: metricsHandler(ms, collect)}
+			errc := make(chan error, 1)
+			go func() { errc <- srv.ListenAndServe() }()
+			defer srv.Close()
+			fmt.Printf("Serving metrics for %s on http://%s/metrics. Ctrl-C to stop.\n", strings.Join(names, ", "), metricsListen)
+
+			sig := make(chan os.Signal, 1)
+			signal.Notify(sig, os.Interrupt)
+			select {
+			case err := <-errc:
+				return fmt.Errorf("metrics server: %w", err)
+			case <-sig:
+				return nil
+			}
+		})
+	},
+}
+
+func init() {
+	serveMetricsCmd.Flags().StringVar(&metricsListen, "listen", "127.0.0.1:9469", "address to serve /metrics on")
+	serveMetricsCmd.Flags().StringSliceVar(&metricsDevices, "devices", nil, "mowers to export, by device name or nickname, or all (default the first)")
+	rootCmd.AddCommand(serveMetricsCmd)
+}
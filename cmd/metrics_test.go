@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeMetrics(t *testing.T) {
+	s, sim := newSimSession(simLawn())
+	ms := newMetricSet()
+	collect, unsubscribe := watchMetrics(s, ms)
+	defer unsubscribe()
+	sim.SetPose(3.25, -1.5, 90)
+	if err := primeSession(s); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(metricsHandler(ms, collect))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	text := string(body)
+
+	for _, want := range []string{
+		"# TYPE mammo_battery_percent gauge\nmammo_battery_percent{device=\"Luba-SIM\"} 100\n",
+		"mammo_sys_status{device=\"Luba-SIM\"} 15\n",
+		"mammo_position_x_meters{device=\"Luba-SIM\"} 3.25\n",
+		"mammo_rtk_satellites{device=\"Luba-SIM\"} 30\n",
+		"mammo_knife_height_mm{device=\"Luba-SIM\"} 60\n",
+		"# TYPE mammo_battery_cycles_total counter\nmammo_battery_cycles_total{device=\"Luba-SIM\"} 40\n",
+		"mammo_wifi_rssi_dbm{device=\"Luba-SIM\"} -55\n",
+		"mammo_messages_total{device=\"Luba-SIM\"} 2\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("metrics missing %q:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "mammo_mqtt_") {
+		t.Error("cloud counters exported for a transport without them")
+	}
+}
+
+func TestServeMetricsSeveralDevices(t *testing.T) {
+	s1, sim1 := newSimSession(simLawn())
+	s2, sim2 := newSimSession(simLawn())
+	s2.device.DeviceName = "Yuka-SIM"
+	ms := newMetricSet()
+	collect, unsubscribe := watchSessionsMetrics([]*cloudSession{s1, s2}, ms)
+	defer unsubscribe()
+	sim1.SetPose(1, 0, 0)
+	sim2.SetPose(-2.5, 0, 0)
+	for _, s := range []*cloudSession{s1, s2} {
+		if err := primeSession(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	srv := httptest.NewServer(metricsHandler(ms, collect))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	text := string(body)
+
+	want := "# TYPE mammo_position_x_meters gauge\n" +
+		"mammo_position_x_meters{device=\"Luba-SIM\"} 1\n" +
+		"mammo_position_x_meters{device=\"Yuka-SIM\"} -2.5\n"
+	if !strings.Contains(text, want) {
+		t.Errorf("metrics missing %q:\n%s", want, text)
+	}
+	if n := strings.Count(text, "# TYPE mammo_battery_percent gauge"); n != 1 {
+		t.Errorf("battery family written %d times, want once", n)
+	}
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// pilotMowerMsg carries a message from one of several mowers, by index.
+// Messages without it belong to the active mower.
+type pilotMowerMsg struct {
+	mower int
+	msg   tea.Msg
+}
+
+// Several mowers: pilot keeps a full model per mower in mowers, indexed as
+// the devices were given. The model being run is the active mower's; its
+// entry in mowers is stale until it is switched away from. The others are
+// updated in the background and drawn in their own colours.
+
+// mowerName is the active mower's device name.
+func (m pilotModel) mowerName() string {
+	if m.session == nil || m.session.device == nil {
+		return ""
+	}
+	return m.session.device.DeviceName
+}
+
+// mowerColor is the palette entry of mower i.
+func mowerColor(i int) struct{ mower, trailOld, trailNew int } {
+	return mowerColors[i%len(mowerColors)]
+}
+
+// updateMower delivers msg to mower i. A background mower's status lines
+// reach the shared status line prefixed with its name.
+func (m pilotModel) updateMower(i int, msg tea.Msg) (tea.Model, tea.Cmd) {
+	if i == m.active || i < 0 || i >= len(m.mowers) {
+		return m.Update(msg)
+	}
+	bg := m.mowers[i]
+	before := bg.status
+	next, cmd := bg.Update(msg)
+	bg = next.(pilotModel)
+	m.mowers[i] = bg
+	if bg.status != before && bg.status != "" {
+		m.status = bg.mowerName() + ": " + bg.status
+	}
+	if bg.err != nil {
+		m.err = fmt.Errorf("%s: %w", bg.mowerName(), bg.err)
+	}
+	return m, cmd
+}
+
+// switchMower hands the driving keys to the next mower, or the previous one
+// when step is -1. The mower left behind is stopped first, and the view
+// settings carry over.
+func (m pilotModel) switchMower(step int) pilotModel {
+	if len(m.mowers) < 2 {
+		m.status = "only one mower connected (--devices adds more)"
+		return m
+	}
+	if !m.viewOnly {
+		m.nav, m.route = nil, nil
+		m.endCruise("")
+	}
+	m.picking, m.zoneBrowse, m.zoneConfirm = false, false, ""
+	left := m.mowerName()
+
+	i := (m.active + step + len(m.mowers)) % len(m.mowers)
+	m.mowers[m.active] = m
+	next := m.mowers[i]
+	next.mowers, next.active = m.mowers, i
+	next.width, next.height = m.width, m.height
+	next.speed, next.turnRate = m.speed, m.turnRate
+	next.zoom, next.panX, next.panY = m.zoom, m.panX, m.panY
+	next.showPlanned, next.showPerception = m.showPlanned, m.showPerception
+	next.dash.show, next.dash.hidden, next.dash.width = m.dash.show, m.dash.hidden, m.dash.width
+	next.status = fmt.Sprintf("now driving %s (%d/%d)", next.mowerName(), i+1, len(m.mowers))
+	if !m.viewOnly {
+		next.status += "; " + left + " stopped"
+	}
+	return next
+}
+
+// otherMowers are the mowers other than the active one, with their indexes.
+func (m pilotModel) otherMowers(fn func(i int, o pilotModel)) {
+	for i, o := range m.mowers {
+		if i != m.active {
+			fn(i, o)
+		}
+	}
+}
+
+// drawOtherMowers draws the background mowers' trails and arrows, or an
+// edge marker for one off the view.
+func (m pilotModel) drawOtherMowers(canvas *Canvas, vp *Viewport) {
+	m.otherMowers(func(i int, o pilotModel) {
+		col := mowerColor(i)
+		DrawTrailColor(canvas, vp, o.trail, col.trailOld, col.trailNew)
+		if !o.posValid {
+			return
+		}
+		px, py := vp.ToPixel(o.posX, o.posY)
+		if px >= 0 && px < canvas.PixelW() && py >= 0 && py < canvas.PixelH() {
+			DrawMowerColor(canvas, vp, o.posX, o.posY, o.heading, col.mower)
+		} else {
+			DrawOffScreenMarker(canvas, vp, o.posX, o.posY, col.mower)
+		}
+	})
+}
+
+// mowersLabel is the header's mower list: the active one first, then each
+// other's name, battery and distance from it.
+func (m pilotModel) mowersLabel() string {
+	if len(m.mowers) < 2 {
+		return ""
+	}
+	s := fmt.Sprintf("%s %d/%d", m.mowerName(), m.active+1, len(m.mowers))
+	m.otherMowers(func(i int, o pilotModel) {
+		s += fmt.Sprintf(" · %s %d%%", o.mowerName(), o.battery)
+		if o.posValid && m.posValid {
+			s += fmt.Sprintf(" %.0fm", math.Hypot(o.posX-m.posX, o.posY-m.posY))
+		}
+	})
+	return s
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"mammo/aliyuniot"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestPilotSeveralMowers(t *testing.T) {
+	devices := []aliyuniot.Device{{DeviceName: "Luba-VS1"}, {DeviceName: "Yuka-2", NickName: "back"}}
+	if got, _ := pickDevices(devices, nil); len(got) != 1 || got[0].DeviceName != "Luba-VS1" {
+		t.Errorf("default pick %v", got)
+	}
+	if got, _ := pickDevices(devices, []string{"back"}); len(got) != 1 || got[0].DeviceName != "Yuka-2" {
+		t.Errorf("pick by nickname %v", got)
+	}
+	if got, _ := pickDevices(devices, []string{"all"}); len(got) != 2 {
+		t.Errorf("all picked %d", len(got))
+	}
+	if _, err := pickDevices(devices, []string{"Luba-VS9"}); err == nil || !strings.Contains(err.Error(), "Yuka-2") {
+		t.Errorf("unknown device: %v", err)
+	}
+
+	s1, _ := newSimSession(simLawn())
+	s2, _ := newSimSession(simLawn())
+	s2.device.DeviceName = "Yuka-SIM"
+	mc1 := &motionController{session: s1, notify: func(string) {}}
+	mc2 := &motionController{session: s2, notify: func(string) {}}
+	mowers := []pilotModel{
+		{session: s1, motion: mc1, speed: 400, turnRate: 450, minBattery: 15, zoom: 1},
+		{session: s2, motion: mc2, speed: 400, turnRate: 450, minBattery: 15, zoom: 1, active: 1},
+	}
+	model := mowers[0]
+	model.mowers = mowers
+	var tm tea.Model = model
+	for _, msg := range []tea.Msg{
+		tea.WindowSizeMsg{Width: 120, Height: 40},
+		pilotMowerMsg{0, pilotMapMsg(simLawn())},
+		pilotMowerMsg{1, pilotMapMsg(simLawn())},
+		pilotMowerMsg{0, pilotPosMsg{x: 10, y: 5, heading: 0, posType: 4}},
+		pilotMowerMsg{0, pilotBatteryMsg(80)},
+		pilotMowerMsg{1, pilotPosMsg{x: 10, y: 9, heading: 90, posType: 4}},
+		pilotMowerMsg{1, pilotBatteryMsg(55)},
+		pilotMowerMsg{1, pilotStatusMsg("map saved")},
+	} {
+		tm, _ = tm.Update(msg)
+	}
+	m := tm.(pilotModel)
+	if m.battery != 80 || m.posY != 5 || m.mowers[1].battery != 55 || m.mowers[1].posY != 9 {
+		t.Fatalf("messages reached the wrong mower: active bat %d y %.0f, other bat %d y %.0f",
+			m.battery, m.posY, m.mowers[1].battery, m.mowers[1].posY)
+	}
+	if m.status != "Yuka-SIM: map saved" {
+		t.Errorf("background status %q", m.status)
+	}
+	view := m.View()
+	for _, want := range []string{"Luba-SIM 1/2", "Yuka-SIM 55% 4m", "bat 80%", "→"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("view is missing %q", want)
+		}
+	}
+
+	// The keys drive the active mower only; n hands them over, stopping
+	// the first.
+	key := func(k string) {
+		tm, _ = tm.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)})
+	}
+	key("w")
+	if mc1.linear != 400 || !mc2.linUntil.IsZero() {
+		t.Fatalf("w: first %d, second held %v", mc1.linear, mc2.linUntil)
+	}
+	key("+")
+	key("n")
+	m = tm.(pilotModel)
+	if m.active != 1 || m.mowerName() != "Yuka-SIM" || m.battery != 55 || m.zoom != 1.5 || m.width != 120 {
+		t.Fatalf("after n: active %d %s, bat %d, zoom %.1f", m.active, m.mowerName(), m.battery, m.zoom)
+	}
+	if !mc1.linUntil.IsZero() || !strings.Contains(m.status, "Luba-SIM stopped") {
+		t.Errorf("first mower not stopped on switch: %q", m.status)
+	}
+	key("w")
+	if mc2.linear != 400 || !mc1.linUntil.IsZero() {
+		t.Errorf("w after n: second %d", mc2.linear)
+	}
+	if view := tm.View(); !strings.Contains(view, "Yuka-SIM 2/2 · Luba-SIM 80%") {
+		t.Error("header does not name the new active mower")
+	}
+	tm, _ = tm.Update(pilotMowerMsg{0, pilotPosMsg{x: 11, y: 5, posType: 4}})
+	if m := tm.(pilotModel); m.posX != 10 || m.mowers[0].posX != 11 || len(m.mowers[0].trail) != 2 {
+		t.Errorf("background position: active x %.0f, first x %.0f", m.posX, m.mowers[0].posX)
+	}
+	key("N")
+	if m := tm.(pilotModel); m.active != 0 || m.posX != 11 {
+		t.Errorf("N: active %d at x %.0f", m.active, m.posX)
+	}
+}
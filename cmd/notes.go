@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// colNote draws annotations.
+const colNote = 117 // sky blue
+
+// MapNote is a named point dropped on the map, such as a sprinkler head or
+// a hole, kept in a sidecar file rather than the map itself.
+type MapNote struct {
+	Name    string    `json:"name"`
+	X       float64   `json:"x"`
+	Y       float64   `json:"y"`
+	Created time.Time `json:"created"`
+}
+
+// mapNotesFile is the sidecar file format.
+type mapNotesFile struct {
+	FormatVersion int       `json:"formatVersion"`
+	Device        string    `json:"device,omitempty"`
+	Notes         []MapNote `json:"notes"`
+}
+
+// notesSidecar is the notes file beside a map file: lawn.json keeps its
+// notes in lawn.notes.json.
+func notesSidecar(mapFile string) string {
+	return strings.TrimSuffix(mapFile, filepath.Ext(mapFile)) + ".notes.json"
+}
+
+// deviceNotesPath is where a device's notes are kept for a map that has no
+// file of its own, such as one pilot fetched live; "" without a device name.
+func deviceNotesPath(device string) string {
+	if device == "" {
+		return ""
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".mammo", "notes", sanitizeFileName(device)+".notes.json")
+}
+
+// loadMapNotes attaches m's notes: from the sidecar of mapFile if there is
+// one, else from the device's notes file. New notes are saved where they were
+// found, else beside the map file, or in the device's file for a map without
+// one.
+func loadMapNotes(m *MowerMap, mapFile string) error {
+	var candidates []string
+	if mapFile != "" {
+		candidates = append(candidates, notesSidecar(mapFile))
+	}
+	if p := deviceNotesPath(m.Device); p != "" {
+		candidates = append(candidates, p)
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	m.NotesPath = candidates[0]
+	for _, path := range candidates {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		var f mapNotesFile
+		if err := json.Unmarshal(data, &f); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		m.Notes, m.NotesPath = f.Notes, path
+		return nil
+	}
+	return nil
+}
+
+// saveMapNotes writes m's notes to its notes file.
+func saveMapNotes(m *MowerMap) error {
+	if m.NotesPath == "" {
+		return fmt.Errorf("nowhere to save notes: the map has no file or device name")
+	}
+	if err := os.MkdirAll(filepath.Dir(m.NotesPath), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(mapNotesFile{FormatVersion: 1, Device: m.Device, Notes: m.Notes}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(m.NotesPath, data, 0644)
+}
+
+// nearestNote is the index of the note closest to x, y within reach metres,
+// or -1.
+func nearestNote(notes []MapNote, x, y, reach float64) int {
+	best, bestD := -1, reach
+	for i, n := range notes {
+		if d := math.Hypot(n.X-x, n.Y-y); d <= bestD {
+			best, bestD = i, d
+		}
+	}
+	return best
+}
+
+// DrawNotes marks each note with a diamond and its name.
+func DrawNotes(c *Canvas, v *Viewport, notes []MapNote) {
+	for _, n := range notes {
+		px, py := v.ToPixel(n.X, n.Y)
+		cx, cy := px/2, py/4
+		if cx < 0 || cx >= c.W || cy < 0 || cy >= c.H {
+			continue
+		}
+		c.SetOverlay(cx, cy, '◆', colNote)
+		c.OverlayString(cx+1, cy, n.Name, colNote)
+	}
+}
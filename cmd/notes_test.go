@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestPilotFetchedMapNotes(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	notes := &MowerMap{Device: "Luba-SIM", Notes: []MapNote{{Name: "Drain", X: 3, Y: 4}}}
+	notes.NotesPath = deviceNotesPath(notes.Device)
+	if err := saveMapNotes(notes); err != nil {
+		t.Fatal(err)
+	}
+
+	s, _ := newSimSession(simLawn())
+	var got *MowerMap
+	fetchPilotMap(s, func(msg tea.Msg) {
+		if m, ok := msg.(pilotMapMsg); ok {
+			got = (*MowerMap)(m)
+		}
+	}, false)
+	if got == nil {
+		t.Fatal("no map fetched")
+	}
+	if len(got.Notes) != 1 || got.Notes[0].Name != "Drain" || got.NotesPath != notes.NotesPath {
+		t.Errorf("fetched map notes %+v at %q", got.Notes, got.NotesPath)
+	}
+}
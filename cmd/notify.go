@@ -0,0 +1,598 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"strconv"
+	"sync"
+	"text/template"
+	"time"
+
+	"mammo/mammotion"
+
+	"github.com/spf13/cobra"
+)
+
+// Notification event kinds. Rules match on these, or "*" for all of them.
+const (
+	eventStuck        = "stuck"        // mowing but not moving for stuckAfter
+	eventJobFinished  = "job_finished" // back on the dock with no breakpoint left
+	eventError        = "error"        // a new non-zero toapp_err_code
+	eventLifted       = "lifted"       // lock_state went non-zero
+	eventRTKLost      = "rtk_lost"     // no RTK fix for rtkLostAfter
+	eventWarning      = "warning"      // cloud device_warning_event
+	eventNotification = "notification" // cloud device_notification_event
+	eventTest         = "test"         // notify --test
+)
+
+// notifyEvent is what sinks receive; webhook templates see its fields.
+type notifyEvent struct {
+	Kind    string    `json:"kind"`
+	Device  string    `json:"device"`
+	Message string    `json:"message"`
+	Code    int       `json:"code,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// notifyDuration is a time.Duration written as a Go duration string ("10m").
+type notifyDuration time.Duration
+
+func (d *notifyDuration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = notifyDuration(v)
+	return nil
+}
+
+// notifyConfig is the --config file.
+type notifyConfig struct {
+	Debounce     notifyDuration        `json:"debounce"`     // default per-rule repeat window
+	StuckAfter   notifyDuration        `json:"stuckAfter"`   // no movement while mowing
+	RTKLostAfter notifyDuration        `json:"rtkLostAfter"` // no RTK fix
+	QuietHours   *quietHours           `json:"quietHours"`
+	Sinks        map[string]sinkConfig `json:"sinks"`
+	Rules        []notifyRule          `json:"rules"`
+}
+
+// quietHours is a daily local-time window, "22:00" to "07:00", in which only
+// rules marked ignoreQuietHours notify.
+type quietHours struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+
+	start, end int // minutes since midnight
+}
+
+// sinkConfig configures one destination. Type is webhook (POST of Template,
+// default the event as JSON), ntfy (POST of the message to an ntfy topic URL)
+// or command (run with sh -c, the event in MAMMO_* variables and as JSON on
+// stdin).
+type sinkConfig struct {
+	Type     string            `json:"type"`
+	URL      string            `json:"url"`
+	Template string            `json:"template"`
+	Headers  map[string]string `json:"headers"`
+	Priority string            `json:"priority"`
+	Token    string            `json:"token"`
+	Command  string            `json:"command"`
+}
+
+// notifyRule sends events of one kind to the named sinks.
+type notifyRule struct {
+	Event            string          `json:"event"`
+	Sinks            []string        `json:"sinks"`
+	Debounce         *notifyDuration `json:"debounce"`
+	IgnoreQuietHours bool            `json:"ignoreQuietHours"`
+}
+
+var notifyEventKinds = []string{eventStuck, eventJobFinished, eventError, eventLifted, eventRTKLost, eventWarning, eventNotification, eventTest}
+
+// defaultNotifyConfig has the default thresholds and no rules.
+func defaultNotifyConfig() *notifyConfig {
+	return &notifyConfig{
+		Debounce:     notifyDuration(15 * time.Minute),
+		StuckAfter:   notifyDuration(3 * time.Minute),
+		RTKLostAfter: notifyDuration(30 * time.Second),
+	}
+}
+
+// parseNotifyConfig decodes and checks a config, filling in the defaults:
+// 15 minute debounce, stuck after 3 minutes, RTK lost after 30 seconds.
+func parseNotifyConfig(data []byte) (*notifyConfig, error) {
+	cfg := defaultNotifyConfig()
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return nil, err
+	}
+	if q := cfg.QuietHours; q != nil {
+		var err error
+		if q.start, err = parseClock(q.Start); err != nil {
+			return nil, fmt.Errorf("quietHours.start: %w", err)
+		}
+		if q.end, err = parseClock(q.End); err != nil {
+			return nil, fmt.Errorf("quietHours.end: %w", err)
+		}
+	}
+	for name, sc := range cfg.Sinks {
+		if _, err := newNotifySink(sc); err != nil {
+			return nil, fmt.Errorf("sink %q: %w", name, err)
+		}
+	}
+	if len(cfg.Rules) == 0 {
+		return nil, errors.New("no rules")
+	}
+	for i, r := range cfg.Rules {
+		known := r.Event == "*"
+		for _, k := range notifyEventKinds {
+			known = known || r.Event == k
+		}
+		if !known {
+			return nil, fmt.Errorf("rule %d: unknown event %q", i+1, r.Event)
+		}
+		if len(r.Sinks) == 0 {
+			return nil, fmt.Errorf("rule %d: no sinks", i+1)
+		}
+		for _, name := range r.Sinks {
+			if _, ok := cfg.Sinks[name]; !ok {
+				return nil, fmt.Errorf("rule %d: no sink named %q", i+1, name)
+			}
+		}
+	}
+	return cfg, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("want HH:MM, got %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// contains reports whether t falls in the window, which may span midnight.
+func (q *quietHours) contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	if q.start <= q.end {
+		return m >= q.start && m < q.end
+	}
+	return m >= q.start || m < q.end
+}
+
+type notifySink interface {
+	Notify(ev notifyEvent) error
+}
+
+func newNotifySink(sc sinkConfig) (notifySink, error) {
+	client := &http.Client{Timeout: 15 * time.Second}
+	switch sc.Type {
+	case "webhook":
+		if sc.URL == "" {
+			return nil, errors.New("webhook needs a url")
+		}
+		src := sc.Template
+		if src == "" {
+			src = "{{json .}}"
+		}
+		tmpl, err := template.New("webhook").Funcs(template.FuncMap{"json": templateJSON}).Parse(src)
+		if err != nil {
+			return nil, err
+		}
+		return &webhookSink{url: sc.URL, tmpl: tmpl, headers: sc.Headers, client: client}, nil
+	case "ntfy":
+		if sc.URL == "" {
+			return nil, errors.New("ntfy needs the topic url")
+		}
+		return &ntfySink{url: sc.URL, priority: sc.Priority, token: sc.Token, client: client}, nil
+	case "command":
+		if sc.Command == "" {
+			return nil, errors.New("command sink needs a command")
+		}
+		return &commandSink{command: sc.Command}, nil
+	}
+	return nil, fmt.Errorf("unknown sink type %q (want webhook, ntfy or command)", sc.Type)
+}
+
+// templateJSON is the webhook template's json function, for embedding values
+// as correctly quoted JSON.
+func templateJSON(v any) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+type webhookSink struct {
+	url     string
+	tmpl    *template.Template
+	headers map[string]string
+	client  *http.Client
+}
+
+func (s *webhookSink) Notify(ev notifyEvent) error {
+	var body bytes.Buffer
+	if err := s.tmpl.Execute(&body, ev); err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+	return doNotifyRequest(s.client, req)
+}
+
+type ntfySink struct {
+	url      string
+	priority string
+	token    string
+	client   *http.Client
+}
+
+func (s *ntfySink) Notify(ev notifyEvent) error {
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewBufferString(ev.Message))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", fmt.Sprintf("%s: %s", ev.Device, ev.Kind))
+	req.Header.Set("Tags", ev.Kind)
+	if s.priority != "" {
+		req.Header.Set("Priority", s.priority)
+	}
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	return doNotifyRequest(s.client, req)
+}
+
+func doNotifyRequest(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: %s", req.URL.Host, resp.Status)
+	}
+	return nil
+}
+
+type commandSink struct {
+	command string
+}
+
+func (s *commandSink) Notify(ev notifyEvent) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	js, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, "sh", "-c", s.command)
+	cmd.Env = append(os.Environ(),
+		"MAMMO_EVENT="+ev.Kind,
+		"MAMMO_DEVICE="+ev.Device,
+		"MAMMO_MESSAGE="+ev.Message,
+		"MAMMO_CODE="+strconv.Itoa(ev.Code),
+	)
+	cmd.Stdin = bytes.NewReader(js)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// notifier applies the rules to events: quiet hours, then debouncing per
+// rule, kind and code, then delivery to each sink in the background.
+type notifier struct {
+	cfg   *notifyConfig
+	sinks map[string]notifySink
+	now   func() time.Time
+
+	mu   sync.Mutex
+	last map[string]time.Time
+	wg   sync.WaitGroup
+}
+
+func newNotifier(cfg *notifyConfig) (*notifier, error) {
+	n := &notifier{cfg: cfg, sinks: make(map[string]notifySink), now: time.Now, last: make(map[string]time.Time)}
+	for name, sc := range cfg.Sinks {
+		sink, err := newNotifySink(sc)
+		if err != nil {
+			return nil, fmt.Errorf("sink %q: %w", name, err)
+		}
+		n.sinks[name] = sink
+	}
+	return n, nil
+}
+
+// Dispatch sends ev to the sinks of every rule it passes, and returns how
+// many sink deliveries were started.
+func (n *notifier) Dispatch(ev notifyEvent) int {
+	now := n.now()
+	quiet := n.cfg.QuietHours != nil && n.cfg.QuietHours.contains(now)
+	started := 0
+	for i, r := range n.cfg.Rules {
+		if r.Event != "*" && r.Event != ev.Kind {
+			continue
+		}
+		if quiet && !r.IgnoreQuietHours {
+			log.Printf("notify: %s suppressed by quiet hours", ev.Kind)
+			continue
+		}
+		window := time.Duration(n.cfg.Debounce)
+		if r.Debounce != nil {
+			window = time.Duration(*r.Debounce)
+		}
+		key := fmt.Sprintf("%d/%s/%d", i, ev.Kind, ev.Code)
+		n.mu.Lock()
+		last, seen := n.last[key]
+		if seen && now.Sub(last) < window {
+			n.mu.Unlock()
+			log.Printf("notify: %s debounced (last sent %s ago)", ev.Kind, now.Sub(last).Round(time.Second))
+			continue
+		}
+		n.last[key] = now
+		n.mu.Unlock()
+
+		for _, name := range r.Sinks {
+			sink := n.sinks[name]
+			started++
+			n.wg.Add(1)
+			go func() {
+				defer n.wg.Done()
+				if err := sink.Notify(ev); err != nil {
+					log.Printf("notify: %s to %s: %v", ev.Kind, name, err)
+				}
+			}()
+		}
+	}
+	return started
+}
+
+// Wait blocks until every started delivery has finished.
+func (n *notifier) Wait() {
+	n.wg.Wait()
+}
+
+// stuckRadius is how far (metres) the mower must move to count as moving.
+const stuckRadius = 0.5
+
+// eventDetector turns the decoded state stream into notifyEvents.
+type eventDetector struct {
+	device       string
+	stuckAfter   time.Duration
+	rtkLostAfter time.Duration
+	emit         func(notifyEvent)
+	now          func() time.Time
+
+	mu         sync.Mutex
+	sysStatus  int32
+	breakPoint bool
+	lifted     bool
+	lastError  int32
+
+	// stuck: where the mower was when last seen moving while mowing.
+	anchorX, anchorY float64
+	anchorAt         time.Time
+	stuckSent        bool
+
+	// rtk_lost: an RTK fix was seen, then lost at lostAt.
+	hadFix   bool
+	lostAt   time.Time
+	lostSent bool
+}
+
+func newEventDetector(device string, cfg *notifyConfig, emit func(notifyEvent)) *eventDetector {
+	return &eventDetector{
+		device:       device,
+		stuckAfter:   time.Duration(cfg.StuckAfter),
+		rtkLostAfter: time.Duration(cfg.RTKLostAfter),
+		emit:         emit,
+		now:          time.Now,
+	}
+}
+
+// fire emits outside the lock; callers collect events while holding it.
+func (d *eventDetector) fire(events []notifyEvent) {
+	for _, ev := range events {
+		d.emit(ev)
+	}
+}
+
+func (d *eventDetector) event(kind, msg string, code int) notifyEvent {
+	return notifyEvent{Kind: kind, Device: d.device, Message: msg, Code: code, Time: d.now()}
+}
+
+// Watch connects the detector to the session's decoded state.
+func (d *eventDetector) Watch(sm *mammotion.StateManager) {
+	sm.OnDeviceStatus = d.Status
+	sm.OnWorkReport = d.Work
+	sm.OnPositionUpdate = func(x, y float32, _ int32, posType int32) {
+		// RealPos is in 0.1mm units.
+		d.Position(float64(x)/10000, float64(y)/10000, posType)
+	}
+	sm.OnErrorCode = d.ErrorCode
+	sm.OnLockState = d.LockState
+	sm.OnDeviceEvent = d.DeviceEvent
+}
+
+func (d *eventDetector) Status(sysStatus, chargeState int32) {
+	d.mu.Lock()
+	var out []notifyEvent
+	prev := d.sysStatus
+	if (prev == sysStatusWorking || prev == sysStatusReturning) && sysStatus == sysStatusCharging && !d.breakPoint {
+		out = append(out, d.event(eventJobFinished, d.device+" finished its job and is back on the dock", 0))
+	}
+	d.sysStatus = sysStatus
+	if sysStatus != sysStatusWorking {
+		d.anchorAt = time.Time{}
+	}
+	d.mu.Unlock()
+	d.fire(out)
+}
+
+func (d *eventDetector) Work(w *mammotion.WorkData) {
+	d.mu.Lock()
+	d.breakPoint = w.BreakPoint != nil
+	d.mu.Unlock()
+}
+
+func (d *eventDetector) Position(x, y float64, posType int32) {
+	d.mu.Lock()
+	var out []notifyEvent
+	now := d.now()
+
+	if d.sysStatus == sysStatusWorking {
+		if d.anchorAt.IsZero() || math.Hypot(x-d.anchorX, y-d.anchorY) > stuckRadius {
+			d.anchorX, d.anchorY, d.anchorAt, d.stuckSent = x, y, now, false
+		} else if !d.stuckSent && now.Sub(d.anchorAt) >= d.stuckAfter {
+			d.stuckSent = true
+			out = append(out, d.event(eventStuck, fmt.Sprintf("%s has not moved for %s while mowing (at %.1f, %.1f)",
+				d.device, now.Sub(d.anchorAt).Round(time.Second), x, y), 0))
+		}
+	}
+
+	if posType == 4 {
+		d.hadFix, d.lostAt, d.lostSent = true, time.Time{}, false
+	} else if d.hadFix {
+		if d.lostAt.IsZero() {
+			d.lostAt = now
+		} else if !d.lostSent && now.Sub(d.lostAt) >= d.rtkLostAfter {
+			d.lostSent = true
+			out = append(out, d.event(eventRTKLost, fmt.Sprintf("%s lost its RTK fix %s ago (now %s)",
+				d.device, now.Sub(d.lostAt).Round(time.Second), rtkLabel(posType)), int(posType)))
+		}
+	}
+	d.mu.Unlock()
+	d.fire(out)
+}
+
+func (d *eventDetector) ErrorCode(code int32) {
+	d.mu.Lock()
+	var out []notifyEvent
+	if code != 0 && code != d.lastError {
+		out = append(out, d.event(eventError, fmt.Sprintf("%s reported error code %d", d.device, code), int(code)))
+	}
+	d.lastError = code
+	d.mu.Unlock()
+	d.fire(out)
+}
+
+func (d *eventDetector) LockState(state uint32) {
+	d.mu.Lock()
+	var out []notifyEvent
+	lifted := state != 0
+	if lifted && !d.lifted {
+		out = append(out, d.event(eventLifted, fmt.Sprintf("%s was lifted or locked (lock_state %d)", d.device, state), int(state)))
+	}
+	d.lifted = lifted
+	d.mu.Unlock()
+	d.fire(out)
+}
+
+func (d *eventDetector) DeviceEvent(ev *mammotion.DeviceEvent) {
+	if ev.Identifier == "device_warning_event" {
+		d.emit(d.event(eventWarning, fmt.Sprintf("%s warning code %d", d.device, ev.Code), ev.Code))
+		return
+	}
+	d.emit(d.event(eventNotification, fmt.Sprintf("%s: %s", d.device, ev.Data), 0))
+}
+
+var (
+	notifyConfigFile string
+	notifyTest       bool
+)
+
+var notifyCmd = &cobra.Command{
+	Use:   "notify --config <rules.json>",
+	Short: "Watch the mower and send notifications when rules match",
+	Long: `Keeps a session open and watches for events:
+
+  stuck         mowing but not moved ` + fmt.Sprint(stuckRadius) + `m for stuckAfter (default 3m)
+  job_finished  back on the dock with no interrupted job left
+  error         a new device error code
+  lifted        lock_state went non-zero
+  rtk_lost      no RTK fix for rtkLostAfter (default 30s)
+  warning       a device_warning_event from the cloud
+  notification  a device_notification_event from the cloud
+
+Rules in the JSON config send events to sinks: webhook (templated JSON
+POST), ntfy (push to an ntfy topic) or command (shell). A rule repeats the
+same event no more often than its debounce (default 15m), and stays quiet
+during quietHours unless ignoreQuietHours is set. --test sends a test event
+through every rule for "*" or "test" without connecting. Runs until Ctrl-C.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if notifyConfigFile == "" {
+			fmt.Println("Error: --config is required")
+			return
+		}
+		data, err := os.ReadFile(notifyConfigFile)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		cfg, err := parseNotifyConfig(data)
+		if err != nil {
+			fmt.Printf("Error: %s: %v\n", notifyConfigFile, err)
+			return
+		}
+		n, err := newNotifier(cfg)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+
+		if notifyTest {
+			sent := n.Dispatch(notifyEvent{Kind: eventTest, Device: "mammo", Message: "Test notification from mammo", Time: time.Now()})
+			n.Wait()
+			fmt.Printf("Test event sent to %d sink(s); errors, if any, are logged above.\n", sent)
+			return
+		}
+
+		withSession(func(s *cloudSession) error {
+			d := newEventDetector(s.device.DeviceName, cfg, func(ev notifyEvent) {
+				log.Printf("notify: %s: %s", ev.Kind, ev.Message)
+				n.Dispatch(ev)
+			})
+			d.Watch(s.stateManager)
+			stopPolling := startPolling(s)
+			defer stopPolling()
+
+			kinds := make([]string, 0, len(cfg.Rules))
+			for _, r := range cfg.Rules {
+				kinds = append(kinds, r.Event)
+			}
+			sort.Strings(kinds)
+			fmt.Printf("Watching %s for %v. Ctrl-C to stop.\n", s.device.DeviceName, kinds)
+			sig := make(chan os.Signal, 1)
+			signal.Notify(sig, os.Interrupt)
+			<-sig
+			n.Wait()
+			return nil
+		})
+	},
+}
+
+func init() {
+	notifyCmd.Flags().StringVar(&notifyConfigFile, "config", "", "rules and sinks (JSON)")
+	notifyCmd.Flags().BoolVar(&notifyTest, "test", false, "send a test event through the sinks and exit")
+	rootCmd.AddCommand(notifyCmd)
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"mammo/mammotion"
+)
+
+func TestNotifyDetector(t *testing.T) {
+	cfg, err := parseNotifyConfig([]byte(`{"stuckAfter":"2m","rtkLostAfter":"30s","rules":[{"event":"*","sinks":["log"]}],
+		"sinks":{"log":{"type":"command","command":"true"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.Local)
+	var got []string
+	d := newEventDetector("Luba-SIM", cfg, func(ev notifyEvent) { got = append(got, ev.Kind) })
+	d.now = func() time.Time { return now }
+
+	d.Status(sysStatusWorking, 0)
+	d.Position(1, 1, 4)
+	now = now.Add(time.Minute)
+	d.Position(1.2, 1, 4) // within stuckRadius
+	now = now.Add(90 * time.Second)
+	d.Position(1.1, 1.1, 5) // stuck; fix lost
+	now = now.Add(10 * time.Second)
+	d.Position(1.1, 1.1, 5) // still stuck: no repeat
+	now = now.Add(30 * time.Second)
+	d.Position(3, 1, 5) // moving again; RTK lost for 40s
+	d.ErrorCode(1202)
+	d.ErrorCode(1202)
+	d.LockState(1)
+	d.LockState(1)
+	d.Work(&mammotion.WorkData{})
+	d.Status(sysStatusReturning, 0)
+	d.Status(sysStatusCharging, 1)
+	d.DeviceEvent(&mammotion.DeviceEvent{Identifier: "device_warning_event", Code: 1005})
+
+	want := []string{eventStuck, eventRTKLost, eventError, eventLifted, eventJobFinished, eventWarning}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+
+	// Returning to the dock with a breakpoint left is not a finished job.
+	got = nil
+	d.Status(sysStatusWorking, 0)
+	d.Work(&mammotion.WorkData{BreakPoint: &mammotion.BreakPointData{}})
+	d.Status(sysStatusCharging, 1)
+	if len(got) != 0 {
+		t.Errorf("events for an interrupted job = %v", got)
+	}
+}
+
+func TestNotifierRules(t *testing.T) {
+	var mu sync.Mutex
+	var hooks, pushes []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path == "/hook" {
+			hooks = append(hooks, r.Header.Get("X-Key")+" "+string(body))
+		} else {
+			pushes = append(pushes, r.Header.Get("Title")+"|"+r.Header.Get("Priority")+"|"+string(body))
+		}
+	}))
+	defer srv.Close()
+	out := filepath.Join(t.TempDir(), "cmd.out")
+
+	cfg, err := parseNotifyConfig([]byte(`{
+		"debounce": "10m",
+		"quietHours": {"start": "22:00", "end": "07:00"},
+		"sinks": {
+			"hook": {"type": "webhook", "url": "` + srv.URL + `/hook", "headers": {"X-Key": "k"},
+				"template": "{\"text\": {{json .Message}}, \"code\": {{.Code}}}"},
+			"phone": {"type": "ntfy", "url": "` + srv.URL + `/mower", "priority": "high"},
+			"script": {"type": "command", "command": "echo \"$MAMMO_EVENT $MAMMO_CODE\" >> ` + out + `"}
+		},
+		"rules": [
+			{"event": "error", "sinks": ["hook", "script"]},
+			{"event": "lifted", "sinks": ["phone"], "ignoreQuietHours": true, "debounce": "0s"}
+		]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := newNotifier(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.Local)
+	n.now = func() time.Time { return now }
+	ev := func(kind string, code int) notifyEvent {
+		return notifyEvent{Kind: kind, Device: "Luba-SIM", Message: fmt.Sprintf("%s \"%d\"", kind, code), Code: code, Time: now}
+	}
+
+	counts := []int{
+		n.Dispatch(ev(eventError, 1202)), // 2 sinks
+		n.Dispatch(ev(eventError, 1202)), // debounced
+		n.Dispatch(ev(eventError, 1300)), // a different code is not
+		n.Dispatch(ev(eventStuck, 0)),    // no rule
+	}
+	now = now.Add(11 * time.Hour) // 23:00
+	counts = append(counts,
+		n.Dispatch(ev(eventError, 1400)), // quiet hours
+		n.Dispatch(ev(eventLifted, 1)),   // allowed through
+		n.Dispatch(ev(eventLifted, 1)),   // no debounce
+	)
+	n.Wait()
+	if fmt.Sprint(counts) != "[2 0 2 0 0 1 1]" {
+		t.Errorf("deliveries = %v", counts)
+	}
+
+	mu.Lock()
+	sort.Strings(hooks)
+	if len(hooks) != 2 || hooks[0] != `k {"text": "error \"1202\"", "code": 1202}` {
+		t.Errorf("webhook bodies = %q", hooks)
+	}
+	if len(pushes) != 2 || pushes[0] != `Luba-SIM: lifted|high|lifted "1"` {
+		t.Errorf("ntfy pushes = %q", pushes)
+	}
+	mu.Unlock()
+	data, err := os.ReadFile(out)
+	if lines := strings.Fields(string(data)); err != nil || len(lines) != 4 {
+		t.Errorf("command sink output = %q, %v", data, err)
+	}
+
+	if _, err := parseNotifyConfig([]byte(`{"rules":[{"event":"mowed","sinks":["x"]}]}`)); err == nil {
+		t.Error("accepted an unknown event")
+	}
+	if _, err := parseNotifyConfig([]byte(`{"rules":[{"event":"error","sinks":["x"]}]}`)); err == nil {
+		t.Error("accepted a rule with a missing sink")
+	}
+}
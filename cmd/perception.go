@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"math"
+
+	"mammo/mammotion"
+)
+
+// costLethal is the cost at and above which a costmap cell is treated as
+// blocked; lower non-zero costs are the inflation band around obstacles.
+const costLethal = 100
+
+// costmapCellToWorld returns the map-frame centre of costmap cell (i, j).
+// The grid is centred on CenterX, CenterY and rotated by Yaw (radians,
+// counter-clockwise); row j grows along the grid's +Y axis.
+func costmapCellToWorld(cm *mammotion.CostmapData, i, j int) (float64, float64) {
+	res := float64(cm.Res)
+	lx := (float64(i) + 0.5 - float64(cm.Width)/2) * res
+	ly := (float64(j) + 0.5 - float64(cm.Height)/2) * res
+	cos, sin := math.Cos(float64(cm.Yaw)), math.Sin(float64(cm.Yaw))
+	return float64(cm.CenterX) + lx*cos - ly*sin, float64(cm.CenterY) + lx*sin + ly*cos
+}
+
+// DrawCostmap shades the occupied cells of the mower's local costmap. Cells
+// larger than a braille dot are filled so the blocked footprint is visible
+// at any zoom.
+func DrawCostmap(c *Canvas, v *Viewport, cm *mammotion.CostmapData) {
+	if cm == nil || cm.Width <= 0 || cm.Height <= 0 || cm.Res <= 0 {
+		return
+	}
+	w, h := int(cm.Width), int(cm.Height)
+	if len(cm.Cells) < w*h {
+		return
+	}
+	// Sub-samples per cell edge so a cell spanning several pixels is filled.
+	steps := int(math.Ceil(float64(cm.Res) / v.MetersPerPixel()))
+	if steps < 1 {
+		steps = 1
+	}
+	if steps > 8 {
+		steps = 8
+	}
+	res := float64(cm.Res)
+	cos, sin := math.Cos(float64(cm.Yaw)), math.Sin(float64(cm.Yaw))
+	for j := 0; j < h; j++ {
+		for i := 0; i < w; i++ {
+			cost := cm.Cells[j*w+i]
+			if cost <= 0 {
+				continue
+			}
+			col := colCostInflated
+			if cost >= costLethal {
+				col = colCostLethal
+			}
+			cx, cy := costmapCellToWorld(cm, i, j)
+			for sy := 0; sy < steps; sy++ {
+				for sx := 0; sx < steps; sx++ {
+					ox := (float64(sx)+0.5)/float64(steps)*res - res/2
+					oy := (float64(sy)+0.5)/float64(steps)*res - res/2
+					px, py := v.ToPixel(cx+ox*cos-oy*sin, cy+ox*sin+oy*cos)
+					c.SetDot(px, py, col)
+				}
+			}
+		}
+	}
+}
+
+// DrawObstacles outlines obstacles detected by the mower's perception.
+func DrawObstacles(c *Canvas, v *Viewport, obstacles [][]MapPoint) {
+	for _, ob := range obstacles {
+		switch len(ob) {
+		case 0:
+			continue
+		case 1:
+			px, py := v.ToPixel(ob[0].X, ob[0].Y)
+			c.SetDot(px, py, colPercept)
+			continue
+		}
+		DrawPolyline(c, v, ob, colPercept)
+		if len(ob) > 2 {
+			DrawPolyline(c, v, []MapPoint{ob[len(ob)-1], ob[0]}, colPercept)
+		}
+	}
+}
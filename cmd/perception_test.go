@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"mammo/mammotion"
+)
+
+func TestCostmapCellPlacement(t *testing.T) {
+	cm := &mammotion.CostmapData{Width: 4, Height: 2, CenterX: 10, CenterY: 20, Res: 0.5, Cells: make([]int32, 8)}
+	x, y := costmapCellToWorld(cm, 0, 0)
+	if math.Abs(x-9.25) > 1e-9 || math.Abs(y-19.75) > 1e-9 {
+		t.Errorf("cell (0,0) of an unrotated grid should be bottom-left of centre, got %.3f, %.3f", x, y)
+	}
+	cm.Yaw = math.Pi / 2 // grid +X now points north
+	x, y = costmapCellToWorld(cm, 3, 1)
+	if math.Abs(x-9.75) > 1e-6 || math.Abs(y-20.75) > 1e-6 {
+		t.Errorf("rotated cell placement wrong: %.3f, %.3f", x, y)
+	}
+}
+
+func TestDrawCostmapOnlyOccupiedCells(t *testing.T) {
+	cm := &mammotion.CostmapData{Width: 2, Height: 1, CenterX: 5, CenterY: 5, Res: 1, Cells: []int32{0, 254}}
+	c := NewCanvas(40, 20)
+	v := NewViewport(0, 0, 10, 10, c.PixelW(), c.PixelH(), 0)
+	DrawCostmap(c, v, cm)
+	out := strings.Join(c.Render(), "")
+	if !strings.Contains(out, fmt.Sprintf("\033[38;5;%dm", colCostLethal)) {
+		t.Error("expected the lethal cell to be drawn")
+	}
+	if strings.Contains(out, fmt.Sprintf("\033[38;5;%dm", colCostInflated)) {
+		t.Error("free cells must not be drawn")
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"log"
 	"math"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -18,39 +20,211 @@ import (
 
 // motionController converts key presses into the continuous 200ms motion
 // stream the mower expects, and guarantees a stop command when input ceases.
+//
+// Linear and angular speed each have their own deadline, so steering can
+// join a forward motion. With ramp rates set, the streamed command moves
+// toward the requested one at those rates instead of jumping; Stop is always
+// immediate.
+//
+// With staleAfter or maxLatency set it also runs a watchdog while driving:
+// if position reports go quiet, sends keep failing or take too long, it stops
+// the mower (repeating the stop) and refuses to drive until reports resume.
 type motionController struct {
 	mu       sync.Mutex
 	linear   int32
 	angular  int32
-	deadline time.Time
+	linUntil time.Time
+	angUntil time.Time
 	moving   bool
 	session  *cloudSession
 	notify   func(string)
+
+	accel, decel, turnAccel float64 // ramp rates per second; 0 is instant
+	outLinear, outAngular   float64 // the command being streamed
+
+	staleAfter    time.Duration // position report age that trips; 0 disables
+	maxLatency    time.Duration // motion send time that trips; 0 disables
+	lastTelemetry time.Time
+	latency       time.Duration
+	failures      int
+	tripped       string
+	recovered     int
+
+	refreshFailing bool // the last session refresh failed
+}
+
+const (
+	watchdogMaxFailures    = 3 // consecutive failed motion sends that trip
+	watchdogStopRetries    = 3 // stops sent when tripping
+	watchdogRecoverReports = 2 // fresh position reports that re-enable driving
+)
+
+// linkHealth is the watchdog's view of the link, for the pilot header.
+type linkHealth struct {
+	Latency        time.Duration // last motion send, after the session refresh
+	Age            time.Duration // since the last position report; 0 before the first
+	Failures       int           // consecutive failed sends
+	Tripped        string        // why driving is disabled, or ""
+	RefreshFailing bool          // the session refresh is failing
 }
 
+// motionTick is the motion stream interval.
+const motionTick = 150 * time.Millisecond
+
 // Drive sets the motion vector and extends the deadline. Holding a key keeps
 // extending it via key-repeat; releasing lets it expire and triggers a stop.
-func (mc *motionController) Drive(linear, angular int32, hold time.Duration) {
+// It returns false, and does nothing, while the watchdog has driving disabled.
+func (mc *motionController) Drive(linear, angular int32, hold time.Duration) bool {
+	return mc.DriveAxes(linear, hold, angular, hold)
+}
+
+// DriveAxes is Drive with a separate hold for each axis; an axis with no
+// hold is idle.
+func (mc *motionController) DriveAxes(linear int32, linHold time.Duration, angular int32, angHold time.Duration) bool {
 	mc.mu.Lock()
-	mc.linear = linear
-	mc.angular = angular
-	mc.deadline = time.Now().Add(hold)
-	mc.mu.Unlock()
+	defer mc.mu.Unlock()
+	if mc.tripped != "" {
+		return false
+	}
+	now := time.Now()
+	mc.linear, mc.linUntil = linear, now.Add(linHold)
+	mc.angular, mc.angUntil = angular, now.Add(angHold)
+	return true
 }
 
-// Stop cancels motion immediately.
+// Stop cancels motion immediately, without ramping down.
 func (mc *motionController) Stop() {
 	mc.mu.Lock()
-	mc.deadline = time.Time{}
+	mc.linUntil, mc.angUntil = time.Time{}, time.Time{}
+	mc.outLinear, mc.outAngular = 0, 0
 	mc.mu.Unlock()
 	if data, err := mammotion.StopMotion(); err == nil {
-		mc.session.gateway.SendCloudCommand(mc.session.device.IotId, data)
+		mc.session.send(data)
+	}
+}
+
+// Telemetry records a position report. Once tripped, enough of them in a row
+// re-enable driving.
+func (mc *motionController) Telemetry() {
+	mc.mu.Lock()
+	mc.lastTelemetry = time.Now()
+	recovered := false
+	if mc.tripped != "" {
+		mc.recovered++
+		if mc.recovered >= watchdogRecoverReports {
+			mc.tripped, mc.recovered, mc.failures = "", 0, 0
+			recovered = true
+		}
+	}
+	mc.mu.Unlock()
+	if recovered {
+		mc.notify("link recovered — driving re-enabled")
+	}
+}
+
+// Health reports the link as the watchdog sees it.
+func (mc *motionController) Health() linkHealth {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	h := linkHealth{Latency: mc.latency, Failures: mc.failures, Tripped: mc.tripped, RefreshFailing: mc.refreshFailing}
+	if !mc.lastTelemetry.IsZero() {
+		h.Age = time.Since(mc.lastTelemetry)
+	}
+	return h
+}
+
+// stale returns why the position reports are too old to drive on, or "".
+func (mc *motionController) stale() string {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	switch {
+	case mc.staleAfter == 0:
+		return ""
+	case mc.lastTelemetry.IsZero():
+		return "no position reports yet"
+	case time.Since(mc.lastTelemetry) > mc.staleAfter:
+		return fmt.Sprintf("no position report for %s", time.Since(mc.lastTelemetry).Round(100*time.Millisecond))
+	}
+	return ""
+}
+
+// recordSend notes a motion send's outcome and returns why it trips the
+// watchdog, or "".
+func (mc *motionController) recordSend(latency time.Duration, err error) string {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.latency = latency
+	if err != nil {
+		mc.failures++
+		if mc.staleAfter != 0 || mc.maxLatency != 0 {
+			if mc.failures >= watchdogMaxFailures {
+				return fmt.Sprintf("%d motion sends failed", mc.failures)
+			}
+		}
+		return ""
+	}
+	mc.failures = 0
+	if mc.maxLatency != 0 && latency > mc.maxLatency {
+		return fmt.Sprintf("motion send took %s", latency.Round(time.Millisecond))
+	}
+	return ""
+}
+
+// trip disables driving and stops the mower, sending the stop several times
+// 200ms apart so one lost on a failing link can't leave it moving.
+func (mc *motionController) trip(reason string) {
+	mc.mu.Lock()
+	mc.tripped = reason
+	mc.recovered = 0
+	mc.linUntil, mc.angUntil = time.Time{}, time.Time{}
+	mc.outLinear, mc.outAngular = 0, 0
+	mc.moving = false
+	mc.mu.Unlock()
+	mc.notify("WATCHDOG: " + reason + " — stopped")
+	data, err := mammotion.StopMotion()
+	if err != nil {
+		return
+	}
+	for i := 0; i < watchdogStopRetries; i++ {
+		if i > 0 {
+			time.Sleep(200 * time.Millisecond)
+		}
+		mc.session.send(data)
+	}
+}
+
+// refreshSession renews the session before a motion send. A renewal can take
+// an HTTP round trip, so it is kept out of the send latency the watchdog
+// checks; failures are reported once, and again when they clear.
+func (mc *motionController) refreshSession() {
+	err := mc.session.refresh()
+	mc.mu.Lock()
+	was := mc.refreshFailing
+	mc.refreshFailing = err != nil
+	mc.mu.Unlock()
+	switch {
+	case err != nil && !was:
+		mc.notify(fmt.Sprintf("session refresh: %v", err))
+	case err == nil && was:
+		mc.notify("session refresh recovered")
 	}
 }
 
+// stream sends one motion command.
+func (mc *motionController) stream(linear, angular int32) error {
+	data, err := mammotion.SendMotionControl(linear, angular)
+	if err != nil {
+		return fmt.Errorf("motion build: %w", err)
+	}
+	if err := mc.session.send(data); err != nil {
+		return fmt.Errorf("motion send: %w", err)
+	}
+	return nil
+}
+
 // run streams motion commands until the stop channel closes.
 func (mc *motionController) run(stop chan struct{}) {
-	ticker := time.NewTicker(150 * time.Millisecond)
+	ticker := time.NewTicker(motionTick)
 	defer ticker.Stop()
 	for {
 		select {
@@ -63,29 +237,41 @@ func (mc *motionController) run(stop chan struct{}) {
 			}
 			return
 		case <-ticker.C:
+			now := time.Now()
 			mc.mu.Lock()
-			driving := time.Now().Before(mc.deadline)
-			linear, angular := mc.linear, mc.angular
+			var linear, angular int32
+			if now.Before(mc.linUntil) {
+				linear = mc.linear
+			}
+			if now.Before(mc.angUntil) {
+				angular = mc.angular
+			}
+			mc.outLinear = ramp(mc.outLinear, float64(linear), mc.accel, mc.decel, motionTick)
+			mc.outAngular = ramp(mc.outAngular, float64(angular), mc.turnAccel, mc.turnAccel, motionTick)
+			linear, angular = int32(math.Round(mc.outLinear)), int32(math.Round(mc.outAngular))
+			// Still driving while a deadline runs or the ramp winds down.
+			driving := now.Before(mc.linUntil) || now.Before(mc.angUntil) || linear != 0 || angular != 0
 			wasMoving := mc.moving
 			mc.moving = driving
 			mc.mu.Unlock()
 
 			if driving {
-				if err := mc.session.gateway.CheckOrRefreshSession(); err != nil {
-					mc.notify(fmt.Sprintf("session refresh: %v", err))
+				if reason := mc.stale(); reason != "" {
+					mc.trip(reason)
 					continue
 				}
-				data, err := mammotion.SendMotionControl(linear, angular)
+				mc.refreshSession()
+				start := time.Now()
+				err := mc.stream(linear, angular)
 				if err != nil {
-					mc.notify(fmt.Sprintf("motion build: %v", err))
-					continue
+					mc.notify(err.Error())
 				}
-				if _, err := mc.session.gateway.SendCloudCommand(mc.session.device.IotId, data); err != nil {
-					mc.notify(fmt.Sprintf("motion send: %v", err))
+				if reason := mc.recordSend(time.Since(start), err); reason != "" {
+					mc.trip(reason)
 				}
 			} else if wasMoving {
 				if data, err := mammotion.StopMotion(); err == nil {
-					mc.session.gateway.SendCloudCommand(mc.session.device.IotId, data)
+					mc.session.send(data)
 					mc.notify("stopped")
 				}
 			}
@@ -97,6 +283,7 @@ type pilotPosMsg struct {
 	x, y    float64 // meters
 	heading float64 // degrees
 	posType int32
+	t       time.Time // when it was reported; zero is now
 }
 type pilotDevStatusMsg struct {
 	sysStatus   int32
@@ -107,13 +294,27 @@ type pilotDockMsg DockPosition
 type pilotZigZagMsg struct {
 	jobID  uint64
 	zone   int32
+	hash   uint64 // the zone's hash
 	frame  int32
 	points []MapPoint
 }
+type pilotCostmapMsg *mammotion.CostmapData
+type pilotPerceptionMsg [][]MapPoint
+type pilotBreakPointMsg *mammotion.BreakPointData
+type pilotWorkMsg *mammotion.WorkData
 type pilotMapMsg *MowerMap
 type pilotProgressMsg string
 type pilotStatusMsg string
 type pilotErrMsg struct{ err error }
+type pilotRtkMsg *mammotion.RtkData
+type pilotEventMsg notifyEvent
+
+// pilotPadMsg is the gamepad's stick command as fractions of full speed and
+// turn rate; both zero when the sticks return to centre.
+type pilotPadMsg struct{ linear, angular float64 }
+
+// pilotPadButtonMsg is a mapped gamepad button: stop, pause or dock.
+type pilotPadButtonMsg string
 
 type pilotModel struct {
 	session  *cloudSession
@@ -126,6 +327,8 @@ type pilotModel struct {
 	mapStatus string
 
 	trail      []MapPoint
+	track      []TrailPoint // the whole session's trail with times, headings and fixes, for the session file
+	breakPoint *mammotion.BreakPointData
 	posValid   bool
 	posX, posY float64
 	heading    float64
@@ -138,6 +341,17 @@ type pilotModel struct {
 	turnRate   int32
 	minBattery int
 
+	dash dashboard // side panels
+
+	// Held drive keys, one deadline per axis, and cruise mode, which holds
+	// cruiseSpeed without key-repeat until cancelled.
+	keyLinear       int32
+	keyLinearUntil  time.Time
+	keyAngular      int32
+	keyAngularUntil time.Time
+	cruise          bool
+	cruiseSpeed     int32
+
 	zoom       float64
 	panX, panY float64
 	paused     bool // last pause/resume command sent
@@ -145,9 +359,43 @@ type pilotModel struct {
 	// Planned coverage path (zigzag) streamed during the current task.
 	showPlanned bool
 	plannedPath []MapPoint
+	plannedZone int64 // hash of the zone the path covers
 	zzJobID     uint64
 	zzSeen      map[int64]bool
 
+	// What the mower itself sees: local costmap and perceived obstacles.
+	showPerception bool
+	costmap        *mammotion.CostmapData
+	obstacles      [][]MapPoint
+
+	// Zone browser: the map's mowing areas, the one selected, and the
+	// action waiting for y to confirm.
+	zoneBrowse  bool
+	zoneList    []zoneInfo
+	zoneSel     int
+	zoneConfirm string
+
+	// Click-to-go: picking a target with the cursor or mouse, the planned
+	// route to it, and the navigation in progress.
+	picking          bool
+	cursorX, cursorY float64
+	route            []MapPoint
+	routeErr         string
+	nav              *waypointNav
+
+	// Measure mode: distances, bearings and areas, and annotations.
+	measure measureTool
+
+	// Geofence on manual driving: built from the map's areas; F overrides.
+	fence       *geofence
+	fenceMargin float64
+	fenceOff    bool
+	fenceWarn   string
+
+	// Other mowers connected with --devices, and which one this is.
+	mowers []pilotModel
+	active int
+
 	status   string
 	err      error
 	quitting bool
@@ -159,16 +407,19 @@ func (m pilotModel) batteryLow() bool {
 	return m.battery > 0 && m.battery < m.minBattery
 }
 
-// sendNavCmd returns a tea.Cmd that fires a one-shot nav command off the UI
-// loop (refreshing the session first) and reports the outcome in the status line.
-func (m pilotModel) sendNavCmd(label string, nav *pb.MctlNav) tea.Cmd {
+// sendNavCmd returns a tea.Cmd that fires one-shot nav commands, in order,
+// off the UI loop (refreshing the session first) and reports the outcome in
+// the status line.
+func (m pilotModel) sendNavCmd(label string, navs ...*pb.MctlNav) tea.Cmd {
 	s := m.session
 	return func() tea.Msg {
-		if err := s.gateway.CheckOrRefreshSession(); err != nil {
+		if err := s.refresh(); err != nil {
 			return pilotStatusMsg(fmt.Sprintf("%s failed: %v", label, err))
 		}
-		if err := sendNav(s, nav); err != nil {
-			return pilotStatusMsg(fmt.Sprintf("%s failed: %v", label, err))
+		for _, nav := range navs {
+			if err := sendNav(s, nav); err != nil {
+				return pilotStatusMsg(fmt.Sprintf("%s failed: %v", label, err))
+			}
 		}
 		return pilotStatusMsg(label + " sent")
 	}
@@ -178,53 +429,374 @@ func (m pilotModel) canDrive() bool {
 	return !m.viewOnly && !m.batteryLow()
 }
 
+// pilotTrailLen is how many trail points pilot draws. The session file keeps
+// every point.
+const pilotTrailLen = 4000
+
+// gotoHold is how long each go-to command is held: longer than the report
+// interval, so the mower stops by itself if position reports stop coming.
+const gotoHold = 1500 * time.Millisecond
+
+// drive sends a motion command through the geofence, slowing or refusing
+// it near the edge. It reports whether the mower was allowed to move.
+func (m *pilotModel) drive(linear, angular int32, hold time.Duration) bool {
+	return m.driveAxes(linear, hold, angular, hold)
+}
+
+// driveAxes is drive with a separate hold for each axis.
+func (m *pilotModel) driveAxes(linear int32, linHold time.Duration, angular int32, angHold time.Duration) bool {
+	if m.fence != nil && !m.fenceOff && linear != 0 {
+		if !m.posValid {
+			m.fenceWarn = "geofence: no position yet"
+			linear = 0
+		} else {
+			linear, m.fenceWarn = m.fence.Check(m.posX, m.posY, m.heading, linear, angular)
+		}
+		if linear == 0 && angular == 0 {
+			m.motion.Stop()
+			return false
+		}
+	} else {
+		m.fenceWarn = ""
+	}
+	if !m.motion.DriveAxes(linear, linHold, angular, angHold) {
+		m.status = "driving disabled by the link watchdog"
+		return false
+	}
+	return true
+}
+
+const (
+	keyDriveHold = 500 * time.Millisecond // a forward/reverse key press
+	keyTurnHold  = 400 * time.Millisecond // a turn key press
+)
+
+// driveKey handles a drive key. Turn keys also keep a forward or reverse
+// motion going, since the terminal only repeats the last key held: holding w
+// then d arcs right until d is released.
+func (m *pilotModel) driveKey(linear, angular int32) {
+	if !m.canDrive() {
+		return
+	}
+	now := time.Now()
+	if linear != 0 {
+		m.keyLinear, m.keyLinearUntil = linear, now.Add(keyDriveHold)
+	}
+	if angular != 0 {
+		m.keyAngular, m.keyAngularUntil = angular, now.Add(keyTurnHold)
+		if now.Before(m.keyLinearUntil) {
+			m.keyLinearUntil = now.Add(keyDriveHold)
+		}
+	}
+	m.driveHeld()
+}
+
+// driveHeld streams the held keys, or cruise, as one command and describes
+// it in the status line.
+func (m *pilotModel) driveHeld() bool {
+	now := time.Now()
+	var linear, angular int32
+	var linHold, angHold time.Duration
+	switch {
+	case m.cruise:
+		linear, linHold = m.cruiseSpeed, gotoHold
+	case now.Before(m.keyLinearUntil):
+		linear, linHold = m.keyLinear, m.keyLinearUntil.Sub(now)
+	}
+	if now.Before(m.keyAngularUntil) {
+		angular, angHold = m.keyAngular, m.keyAngularUntil.Sub(now)
+	}
+	if !m.driveAxes(linear, linHold, angular, angHold) {
+		return false
+	}
+	m.status = driveLabel(linear, angular)
+	if m.cruise {
+		m.status = "cruise " + m.status
+	}
+	return true
+}
+
+// driveLabel describes a motion command for the status line.
+func driveLabel(linear, angular int32) string {
+	var parts []string
+	switch {
+	case linear > 0:
+		parts = append(parts, "▲ forward")
+	case linear < 0:
+		parts = append(parts, "▼ reverse")
+	}
+	switch {
+	case angular < 0:
+		parts = append(parts, "◀ left")
+	case angular > 0:
+		parts = append(parts, "▶ right")
+	}
+	if len(parts) == 0 {
+		return "stopped"
+	}
+	return strings.Join(parts, " ")
+}
+
+// endCruise leaves cruise mode and stops.
+func (m *pilotModel) endCruise(status string) {
+	m.cruise = false
+	m.keyLinearUntil, m.keyAngularUntil = time.Time{}, time.Time{}
+	m.motion.Stop()
+	m.status = status
+}
+
+// pickTarget enters target selection with the cursor at x, y and previews
+// the route to it.
+func (m *pilotModel) pickTarget(x, y float64) {
+	m.picking = true
+	m.cursorX, m.cursorY = x, y
+	m.route, m.routeErr = nil, ""
+	if !m.posValid {
+		m.routeErr = "no position yet"
+		return
+	}
+	if m.fence != nil && !m.fenceOff && m.fence.Clearance(x, y) < 0 {
+		m.routeErr = "target is outside the geofence"
+		return
+	}
+	route, err := planRoute(m.mowerMap, MapPoint{X: m.posX, Y: m.posY}, MapPoint{X: x, Y: y}, routeClearance)
+	if err != nil {
+		m.routeErr = err.Error()
+		return
+	}
+	m.route = route
+}
+
+// startGoto begins driving the previewed route.
+func (m *pilotModel) startGoto() {
+	switch {
+	case !m.canDrive():
+		m.status = "go-to unavailable: driving is disabled"
+		return
+	case m.route == nil:
+		m.status = "go-to: " + m.routeErr
+		return
+	case m.posType != 4:
+		m.status = fmt.Sprintf("go-to needs an RTK fix (have %s)", rtkLabel(m.posType))
+		return
+	}
+	m.picking = false
+	m.cruise = false
+	m.nav = newWaypointNav(m.route, m.speed, m.turnRate)
+	m.status = fmt.Sprintf("going to %.1f, %.1f — any key aborts", m.nav.target.X, m.nav.target.Y)
+	m.stepGoto()
+}
+
+// stepGoto issues the next go-to command from the current pose.
+func (m *pilotModel) stepGoto() {
+	linear, angular, done := m.nav.Step(m.posX, m.posY, m.heading)
+	if done {
+		m.endGoto(fmt.Sprintf("arrived at %.1f, %.1f", m.nav.target.X, m.nav.target.Y))
+		return
+	}
+	if !m.drive(linear, angular, gotoHold) {
+		if h := m.motion.Health(); h.Tripped != "" {
+			m.endGoto("go-to stopped by the link watchdog: " + h.Tripped)
+		} else {
+			m.endGoto("go-to stopped at the " + m.fenceWarn)
+		}
+	}
+}
+
+// endGoto stops the mower and clears the go-to state.
+func (m *pilotModel) endGoto(status string) {
+	m.nav, m.route, m.picking = nil, nil, false
+	m.motion.Stop()
+	m.status = status
+}
+
 func (m pilotModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
+	case pilotMowerMsg:
+		return m.updateMower(msg.mower, msg.msg)
+
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
 
+	case tea.MouseMsg:
+		if msg.Action != tea.MouseActionPress {
+			break
+		}
+		if m.nav != nil {
+			m.endGoto("go-to aborted")
+			break
+		}
+		if msg.Button == tea.MouseButtonLeft && m.measure.on && !m.measure.naming {
+			if c, vp := m.mapView(); vp != nil && msg.X < c.W && msg.Y >= 1 && msg.Y-1 < c.H {
+				x, y := vp.ToWorld(msg.X*2+1, (msg.Y-1)*4+2)
+				m.measure.cursor = MapPoint{X: x, Y: y}
+				m.measure.points = append(m.measure.points, m.measure.cursor)
+			}
+			break
+		}
+		if msg.Button != tea.MouseButtonLeft || !m.canDrive() {
+			break
+		}
+		// The map starts on the row below the header; aim at the middle of
+		// the clicked braille cell.
+		if c, vp := m.mapView(); vp != nil && msg.X < c.W && msg.Y >= 1 && msg.Y-1 < c.H {
+			x, y := vp.ToWorld(msg.X*2+1, (msg.Y-1)*4+2)
+			m.pickTarget(x, y)
+		}
+
 	case tea.KeyMsg:
+		if m.nav != nil && msg.String() != "ctrl+c" {
+			m.endGoto("go-to aborted")
+			if msg.String() != "q" {
+				return m, nil
+			}
+		}
+		if m.measure.on && msg.String() != "ctrl+c" {
+			if !m.measure.naming && (msg.String() == "esc" || msg.String() == "m") {
+				m.measure.on = false
+				m.status = "measure off"
+				return m, nil
+			}
+			step := 0.5
+			if _, vp := m.mapView(); vp != nil {
+				step = 4 * vp.MetersPerPixel()
+			}
+			if status, ok := m.measure.key(msg.String(), step, m.mowerMap); ok {
+				if status != "" {
+					m.status = status
+				}
+				return m, nil
+			}
+		}
+		if m.picking {
+			step := 0.5
+			if _, vp := m.mapView(); vp != nil {
+				step = 4 * vp.MetersPerPixel()
+			}
+			switch msg.String() {
+			case "up", "w":
+				m.pickTarget(m.cursorX, m.cursorY+step)
+				return m, nil
+			case "down", "s":
+				m.pickTarget(m.cursorX, m.cursorY-step)
+				return m, nil
+			case "left", "a":
+				m.pickTarget(m.cursorX-step, m.cursorY)
+				return m, nil
+			case "right", "d":
+				m.pickTarget(m.cursorX+step, m.cursorY)
+				return m, nil
+			case "enter":
+				m.startGoto()
+				return m, nil
+			case "esc", "g":
+				m.picking, m.route = false, nil
+				m.status = "go-to cancelled"
+				return m, nil
+			}
+		}
+		if m.zoneBrowse && msg.String() != "ctrl+c" && msg.String() != "q" {
+			if cmd, ok := m.zoneKey(msg.String()); ok {
+				return m, cmd
+			}
+		}
+		if m.panelKey(msg.String()) {
+			return m, nil
+		}
 		switch msg.String() {
 		case "ctrl+c", "q":
 			m.quitting = true
 			return m, tea.Quit
 
 		case "up", "w":
-			if m.canDrive() {
-				m.motion.Drive(m.speed, 0, 500*time.Millisecond)
-				m.status = "▲ forward"
-			}
+			m.driveKey(m.speed, 0)
 		case "down", "s":
-			if m.canDrive() {
-				m.motion.Drive(-m.speed, 0, 500*time.Millisecond)
-				m.status = "▼ reverse"
+			if m.cruise {
+				m.endCruise("cruise cancelled")
+				break
 			}
+			m.driveKey(-m.speed, 0)
 		case "left", "a":
-			if m.canDrive() {
-				m.motion.Drive(0, -m.turnRate, 400*time.Millisecond)
-				m.status = "◀ turning left"
-			}
+			m.driveKey(0, -m.turnRate)
 		case "right", "d":
-			if m.canDrive() {
-				m.motion.Drive(0, m.turnRate, 400*time.Millisecond)
-				m.status = "▶ turning right"
-			}
+			m.driveKey(0, m.turnRate)
 		case " ":
 			if !m.viewOnly {
-				m.motion.Stop()
-				m.status = "STOP sent"
+				m.endCruise("STOP sent")
+			}
+
+		case "c":
+			switch {
+			case m.cruise:
+				m.endCruise("cruise off")
+			case m.canDrive():
+				m.cruise = true
+				m.cruiseSpeed = m.speed
+				if time.Now().Before(m.keyLinearUntil) && m.keyLinear < 0 {
+					m.cruiseSpeed = -m.speed
+				}
+				if !m.driveHeld() {
+					m.cruise = false
+				}
+			}
+
+		case "z":
+			m.openZones()
+
+		case "m":
+			x, y := m.posX, m.posY
+			if _, vp := m.mapView(); vp != nil && !m.posValid {
+				x, y = (vp.MinX+vp.MaxX)/2, (vp.MinY+vp.MaxY)/2
+			}
+			m.picking, m.route = false, nil
+			m.measure.start(x, y)
+			m.status = "measuring: enter places points, n names an annotation, esc ends"
+
+		case "n", "N":
+			step := 1
+			if msg.String() == "N" {
+				step = -1
+			}
+			return m.switchMower(step), nil
+
+		case "F":
+			if !m.viewOnly {
+				m.fenceOff = !m.fenceOff
+				m.fenceWarn = ""
+				switch {
+				case m.fence == nil:
+					m.status = "no geofence (needs a map with mowing areas)"
+				case m.fenceOff:
+					m.status = "geofence OVERRIDDEN — driving is unrestricted"
+				default:
+					m.status = "geofence on"
+				}
+			}
+
+		case "g":
+			if m.canDrive() {
+				x, y := m.posX, m.posY
+				if _, vp := m.mapView(); vp != nil && !m.posValid {
+					x, y = (vp.MinX+vp.MaxX)/2, (vp.MinY+vp.MaxY)/2
+				}
+				m.pickTarget(x, y)
+				m.status = "pick a target: arrows move, enter goes, esc cancels"
 			}
 
-		case "[":
-			m.speed -= 100
-			if m.speed < 100 {
-				m.speed = 100
+		case "[", "]":
+			if msg.String() == "[" {
+				m.speed = max(m.speed-100, 100)
+			} else {
+				m.speed = min(m.speed+100, 1000)
 			}
-		case "]":
-			m.speed += 100
-			if m.speed > 1000 {
-				m.speed = 1000
+			if m.cruise {
+				if m.cruiseSpeed < 0 {
+					m.cruiseSpeed = -m.speed
+				} else {
+					m.cruiseSpeed = m.speed
+				}
+				m.driveHeld()
 			}
 
 		case "+", "=":
@@ -260,6 +832,17 @@ func (m pilotModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.status = "planned path shown"
 			}
 
+		case "o":
+			m.showPerception = !m.showPerception
+			switch {
+			case !m.showPerception:
+				m.status = "perception layer hidden"
+			case m.costmap == nil && len(m.obstacles) == 0:
+				m.status = "perception layer shown (nothing received yet)"
+			default:
+				m.status = "perception layer shown"
+			}
+
 		case "p":
 			if !m.viewOnly {
 				// NavTaskCtrl type=1: action 1 pauses the running task, 0 resumes.
@@ -286,8 +869,53 @@ func (m pilotModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
+	case pilotPadMsg:
+		moving := msg.linear != 0 || msg.angular != 0
+		if m.nav != nil {
+			if moving {
+				m.endGoto("go-to aborted")
+			}
+			break
+		}
+		if !m.canDrive() {
+			break
+		}
+		m.cruise = false
+		if !moving {
+			m.motion.Stop()
+			m.status = "gamepad: centred, stopped"
+			break
+		}
+		linear := int32(math.Round(msg.linear * float64(m.speed)))
+		angular := int32(math.Round(msg.angular * float64(m.turnRate)))
+		if m.drive(linear, angular, 300*time.Millisecond) {
+			m.status = fmt.Sprintf("gamepad %+d mm/s, %+d mrad/s", linear, angular)
+		}
+
+	case pilotPadButtonMsg:
+		// Buttons act exactly as their keys.
+		key, ok := map[string]tea.KeyMsg{
+			"stop":  {Type: tea.KeySpace, Runes: []rune{' '}},
+			"pause": {Type: tea.KeyRunes, Runes: []rune{'p'}},
+			"dock":  {Type: tea.KeyRunes, Runes: []rune{'r'}},
+		}[string(msg)]
+		if ok {
+			return m.Update(key)
+		}
+
 	case pilotDevStatusMsg:
 		m.charging = msg.chargeState == 1
+		m.dash.Status(time.Now(), msg.sysStatus)
+
+	case pilotRtkMsg:
+		m.dash.rtk, m.dash.rtkAt = (*mammotion.RtkData)(msg), time.Now()
+
+	case pilotEventMsg:
+		// The panel is per mower, so drop the device name the message
+		// leads with.
+		text := strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(msg.Message, msg.Device), ":"))
+		warn := msg.Kind != eventJobFinished && msg.Kind != eventNotification
+		m.dash.Log(msg.Time, text, warn)
 
 	case pilotPosMsg:
 		msg.heading = math.Mod(msg.heading, 360)
@@ -296,25 +924,69 @@ func (m pilotModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		// Position and map share one coordinate frame, so no transform is
 		// needed beyond the scale applied in the callback.
+		keep := true
 		if m.posValid && len(m.trail) > 0 {
 			last := m.trail[len(m.trail)-1]
-			if math.Hypot(msg.x-last.X, msg.y-last.Y) > 0.02 { // 2cm jitter gate
-				m.trail = append(m.trail, MapPoint{X: msg.x, Y: msg.y})
-				if len(m.trail) > 4000 {
-					m.trail = m.trail[len(m.trail)-4000:]
-				}
+			keep = math.Hypot(msg.x-last.X, msg.y-last.Y) > 0.02 // 2cm jitter gate
+		}
+		if keep {
+			if msg.t.IsZero() {
+				msg.t = time.Now()
 			}
-		} else {
 			m.trail = append(m.trail, MapPoint{X: msg.x, Y: msg.y})
+			m.track = append(m.track, TrailPoint{T: msg.t, X: msg.x, Y: msg.y, Heading: msg.heading, PosType: msg.posType})
+			if len(m.trail) > pilotTrailLen {
+				m.trail = m.trail[len(m.trail)-pilotTrailLen:]
+			}
 		}
 		m.posValid = true
 		m.posX, m.posY = msg.x, msg.y
 		m.heading = msg.heading
 		m.posType = msg.posType
 		m.posUpdates++
+		if m.nav != nil {
+			switch {
+			case msg.posType != 4:
+				m.endGoto(fmt.Sprintf("go-to stopped: RTK fix lost (%s)", rtkLabel(msg.posType)))
+			case !m.canDrive():
+				m.endGoto("go-to stopped: driving disabled")
+			default:
+				m.stepGoto()
+			}
+		}
+		if m.cruise {
+			// Re-issue each report: it renews the hold and re-runs the
+			// geofence check from the new pose.
+			switch {
+			case !m.canDrive():
+				m.endCruise("cruise stopped: driving disabled")
+			case !m.driveHeld():
+				if h := m.motion.Health(); h.Tripped != "" {
+					m.endCruise("cruise stopped by the link watchdog: " + h.Tripped)
+				} else {
+					m.endCruise("cruise stopped at the " + m.fenceWarn)
+				}
+			}
+		}
 
 	case pilotBatteryMsg:
 		m.battery = int(msg)
+		m.dash.AddBattery(time.Now(), m.battery)
+
+	case pilotBreakPointMsg:
+		m.breakPoint = msg
+
+	case pilotWorkMsg:
+		m.dash.work = (*mammotion.WorkData)(msg)
+		// rpt_work streams continuously: an empty breakpoint means the job
+		// finished or was cancelled. Don't overwrite a toapp_bp breakpoint,
+		// which also carries the resume heading.
+		switch {
+		case msg.BreakPoint == nil:
+			m.breakPoint = nil
+		case m.breakPoint == nil || m.breakPoint.FromWork:
+			m.breakPoint = msg.BreakPoint
+		}
 
 	case pilotDockMsg:
 		if m.mowerMap != nil {
@@ -330,6 +1002,7 @@ func (m pilotModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.plannedPath = m.plannedPath[:0]
 			m.zzSeen = map[int64]bool{}
 		}
+		m.plannedZone = int64(msg.hash)
 		if m.zzSeen == nil {
 			m.zzSeen = map[int64]bool{}
 		}
@@ -339,9 +1012,16 @@ func (m pilotModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.plannedPath = append(m.plannedPath, msg.points...)
 		}
 
+	case pilotCostmapMsg:
+		m.costmap = (*mammotion.CostmapData)(msg)
+
+	case pilotPerceptionMsg:
+		m.obstacles = [][]MapPoint(msg)
+
 	case pilotMapMsg:
 		m.mowerMap = (*MowerMap)(msg)
 		m.mapStatus = ""
+		m.fence = newGeofence(m.mowerMap, m.fenceMargin)
 
 	case pilotProgressMsg:
 		m.mapStatus = string(msg)
@@ -357,6 +1037,79 @@ func (m pilotModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// linkLabel summarises link health for the header: motion send latency, the
+// age of the last position report, any failing sends and a failing session
+// refresh.
+func linkLabel(h linkHealth) string {
+	s := "link"
+	if h.Latency > 0 {
+		s += fmt.Sprintf(" %dms", h.Latency.Milliseconds())
+	}
+	if h.Age > 0 {
+		s += fmt.Sprintf(" rpt %.1fs", h.Age.Seconds())
+	} else {
+		s += " no rpt"
+	}
+	if h.Failures > 0 {
+		s += fmt.Sprintf(" %d✕", h.Failures)
+	}
+	if h.RefreshFailing {
+		s += " refresh✕"
+	}
+	return s
+}
+
+// mapView lays out the map canvas below the header and fits the viewport to
+// the map extent and mower position, with the user's zoom and pan applied.
+// The viewport is nil until there is anything to show.
+func (m pilotModel) mapView() (*Canvas, *Viewport) {
+	mapHeight := m.height - 3
+	if mapHeight < 5 {
+		mapHeight = 5
+	}
+	canvas := NewCanvas(m.width-m.panelWidth(), mapHeight)
+
+	// Determine world bounds: map extent plus mower trail.
+	var minX, minY, maxX, maxY float64
+	haveBounds := false
+	if m.mowerMap != nil {
+		minX, minY, maxX, maxY, haveBounds = m.mowerMap.Bounds()
+	}
+	if m.posValid {
+		if !haveBounds {
+			minX, minY, maxX, maxY = m.posX-10, m.posY-10, m.posX+10, m.posY+10
+			haveBounds = true
+		} else {
+			minX = math.Min(minX, m.posX)
+			maxX = math.Max(maxX, m.posX)
+			minY = math.Min(minY, m.posY)
+			maxY = math.Max(maxY, m.posY)
+		}
+	}
+	m.otherMowers(func(_ int, o pilotModel) {
+		if !o.posValid {
+			return
+		}
+		if !haveBounds {
+			minX, minY, maxX, maxY = o.posX-10, o.posY-10, o.posX+10, o.posY+10
+			haveBounds = true
+		}
+		minX, maxX = math.Min(minX, o.posX), math.Max(maxX, o.posX)
+		minY, maxY = math.Min(minY, o.posY), math.Max(maxY, o.posY)
+	})
+	if !haveBounds {
+		return canvas, nil
+	}
+	vp := NewViewport(minX, minY, maxX, maxY, canvas.PixelW(), canvas.PixelH(), 0.05)
+	if m.zoom != 1 {
+		vp.Zoom(m.zoom)
+	}
+	if m.panX != 0 || m.panY != 0 {
+		vp.Pan(m.panX, m.panY)
+	}
+	return canvas, vp
+}
+
 func rtkLabel(posType int32) string {
 	switch posType {
 	case 4:
@@ -376,6 +1129,7 @@ var (
 	pilotHeaderStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("48"))
 	pilotWarnStyle   = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("196"))
 	pilotHelpStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+	pilotPanelStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("252"))
 )
 
 func (m pilotModel) View() string {
@@ -389,40 +1143,10 @@ func (m pilotModel) View() string {
 		return "starting..."
 	}
 
-	mapHeight := m.height - 3
-	if mapHeight < 5 {
-		mapHeight = 5
-	}
-	canvas := NewCanvas(m.width, mapHeight)
-
-	// Determine world bounds: map extent plus mower trail.
-	var minX, minY, maxX, maxY float64
-	haveBounds := false
-	if m.mowerMap != nil {
-		minX, minY, maxX, maxY, haveBounds = m.mowerMap.Bounds()
-	}
-	if m.posValid {
-		if !haveBounds {
-			minX, minY, maxX, maxY = m.posX-10, m.posY-10, m.posX+10, m.posY+10
-			haveBounds = true
-		} else {
-			minX = math.Min(minX, m.posX)
-			maxX = math.Max(maxX, m.posX)
-			minY = math.Min(minY, m.posY)
-			maxY = math.Max(maxY, m.posY)
-		}
-	}
-
+	canvas, vp := m.mapView()
 	offScreen := false
 	offDist := 0.0
-	if haveBounds {
-		vp := NewViewport(minX, minY, maxX, maxY, canvas.PixelW(), canvas.PixelH(), 0.05)
-		if m.zoom != 1 {
-			vp.Zoom(m.zoom)
-		}
-		if m.panX != 0 || m.panY != 0 {
-			vp.Pan(m.panX, m.panY)
-		}
+	if vp != nil {
 		if m.mowerMap != nil {
 			DrawMap(canvas, vp, m.mowerMap, nil)
 		}
@@ -431,13 +1155,39 @@ func (m pilotModel) View() string {
 		if m.showPlanned && len(m.plannedPath) > 1 {
 			DrawPolyline(canvas, vp, m.plannedPath, colPlanned)
 		}
-		DrawTrail(canvas, vp, m.trail)
+		if m.showPerception {
+			DrawCostmap(canvas, vp, m.costmap)
+			DrawObstacles(canvas, vp, m.obstacles)
+		}
+		m.drawOtherMowers(canvas, vp)
+		col := mowerColor(m.active)
+		DrawTrailColor(canvas, vp, m.trail, col.trailOld, col.trailNew)
+		if m.breakPoint != nil {
+			DrawBreakPoint(canvas, vp, m.breakPoint.X, m.breakPoint.Y)
+		}
+		if z := m.selectedZone(); z != nil {
+			DrawPolyline(canvas, vp, append(append([]MapPoint(nil), z.points...), z.points[0]), colZoneSelected)
+		}
+		if len(m.route) > 1 {
+			DrawPolyline(canvas, vp, m.route, colRoute)
+		}
+		if m.measure.on {
+			m.measure.draw(canvas, vp)
+		}
+		switch {
+		case m.nav != nil:
+			px, py := vp.ToPixel(m.nav.target.X, m.nav.target.Y)
+			canvas.SetOverlay(px/2, py/4, '◎', colRoute)
+		case m.picking:
+			px, py := vp.ToPixel(m.cursorX, m.cursorY)
+			canvas.SetOverlay(px/2, py/4, '+', colRoute)
+		}
 		if m.posValid {
 			px, py := vp.ToPixel(m.posX, m.posY)
 			if px >= 0 && px < canvas.PixelW() && py >= 0 && py < canvas.PixelH() {
-				DrawMower(canvas, vp, m.posX, m.posY, m.heading)
+				DrawMowerColor(canvas, vp, m.posX, m.posY, m.heading, col.mower)
 			} else {
-				offScreen = DrawOffScreenMarker(canvas, vp, m.posX, m.posY, colMower)
+				offScreen = DrawOffScreenMarker(canvas, vp, m.posX, m.posY, col.mower)
 				cx := (vp.MinX + vp.MaxX) / 2
 				cy := (vp.MinY + vp.MaxY) / 2
 				offDist = math.Hypot(m.posX-cx, m.posY-cy)
@@ -449,8 +1199,19 @@ func (m pilotModel) View() string {
 	}
 
 	mapLines := canvas.Render()
+	var panels []panelLine
+	if m.panelWidth() > 0 {
+		panels = m.panels(len(mapLines))
+	}
 	body := ""
-	for _, l := range mapLines {
+	for i, l := range mapLines {
+		if i < len(panels) {
+			style := pilotPanelStyle
+			if panels[i].warn {
+				style = pilotWarnStyle
+			}
+			l += pilotHelpStyle.Render("│") + style.Render(panels[i].text)
+		}
 		body += l + "\n"
 	}
 
@@ -459,6 +1220,9 @@ func (m pilotModel) View() string {
 	if m.viewOnly {
 		mode = "VIEW"
 	}
+	if mowers := m.mowersLabel(); mowers != "" {
+		mode += " │ " + mowers
+	}
 	bat := fmt.Sprintf("bat %d%%", m.battery)
 	if m.charging {
 		bat += "⚡"
@@ -474,30 +1238,87 @@ func (m pilotModel) View() string {
 			frame += " │ plan hidden"
 		}
 	}
+	if m.breakPoint != nil {
+		frame += fmt.Sprintf(" │ ✕ resume at %.1f, %.1f", m.breakPoint.X, m.breakPoint.Y)
+	}
+	if m.showPerception && (m.costmap != nil || len(m.obstacles) > 0) {
+		frame += fmt.Sprintf(" │ seen %d obs", len(m.obstacles))
+	}
+	switch {
+	case m.measure.on:
+		var from *MapPoint
+		if m.posValid {
+			from = &MapPoint{X: m.posX, Y: m.posY}
+		}
+		frame += " │ " + m.measure.readout(from, m.mowerMap)
+	case m.cruise:
+		frame += fmt.Sprintf(" │ CRUISE %+d mm/s", m.cruiseSpeed)
+	case m.nav != nil:
+		frame += fmt.Sprintf(" │ → %.1f, %.1f (%.1fm)", m.nav.target.X, m.nav.target.Y,
+			math.Hypot(m.nav.target.X-m.posX, m.nav.target.Y-m.posY))
+	case m.picking && m.routeErr != "":
+		frame += fmt.Sprintf(" │ target %.1f, %.1f: %s", m.cursorX, m.cursorY, m.routeErr)
+	case m.picking:
+		frame += fmt.Sprintf(" │ target %.1f, %.1f (%.1fm, %d legs)", m.cursorX, m.cursorY, routeLength(m.route), len(m.route)-1)
+	}
+	if !m.viewOnly {
+		frame += " │ " + linkLabel(m.motion.Health())
+	}
 	if offScreen {
 		frame += fmt.Sprintf(" │ mower off-screen %.0fm (arrow; press 0 to fit)", offDist)
 	}
 	header := fmt.Sprintf(" %s │ %s │ %s │ pos %.2f, %.2f │ hdg %.0f° │ spd %d │ zoom %.1fx%s │ %s",
 		mode, bat, rtkLabel(m.posType), m.posX, m.posY, m.heading, m.speed, m.zoom, frame, m.status)
 	headerLine := pilotHeaderStyle.Render(header)
+	if h := m.motion.Health(); !m.viewOnly && h.Tripped != "" {
+		headerLine = pilotWarnStyle.Render(" ⚠ WATCHDOG: "+h.Tripped+" — driving disabled ") + headerLine
+	}
+	if !m.viewOnly && m.fence != nil && m.fenceOff {
+		headerLine = pilotWarnStyle.Render(" ⚠ GEOFENCE OFF ") + headerLine
+	}
+	if !m.viewOnly && m.fenceWarn != "" {
+		headerLine = pilotWarnStyle.Render(" ⚠ "+m.fenceWarn+" ") + headerLine
+	}
 	if m.batteryLow() && !m.viewOnly {
 		headerLine = pilotWarnStyle.Render(fmt.Sprintf(" ⚠ BATTERY %d%% — driving disabled (below --min-battery %d%%) ",
 			m.battery, m.minBattery)) + headerLine
 	}
 
-	help := " wasd/arrows drive · space STOP · p pause · r dock · t plan · [ ] speed · +- zoom · hjkl pan · 0 fit · q quit"
-	if m.viewOnly {
-		help = " t plan · + - zoom · hjkl pan · 0 fit · q quit"
+	help := " wasd/arrows drive · c cruise · space STOP · g/click go-to · F fence override · p pause · r dock · t plan · o seen · z zones · m measure · n mower · tab/1-5 panels · [ ] speed · +- zoom · hjkl pan · 0 fit · q quit"
+	switch {
+	case m.viewOnly:
+		help = " t plan · o seen · z zones · m measure · n mower · tab/1-5 panels · + - zoom · hjkl pan · 0 fit · q quit"
+	case m.nav != nil:
+		help = " any key or click aborts go-to"
+	case m.measure.on:
+		help = m.measure.help()
+	case m.zoneBrowse:
+		help = " ↑↓/ws select zone · enter centre · m mow · e edge mow · v show route · esc close · space STOP"
+	case m.picking:
+		help = " wasd/arrows or click move target · enter go · esc cancel · +- zoom · hjkl pan"
 	}
 
 	return headerLine + "\n" + body + pilotHelpStyle.Render(help)
 }
 
 var (
-	pilotMapFile    string
-	pilotSaveMap    string
-	pilotViewOnly   bool
-	pilotMinBattery int
+	pilotMapFile     string
+	pilotSaveMap     string
+	pilotViewOnly    bool
+	pilotMinBattery  int
+	pilotTrailDir    string
+	pilotFenceMargin float64
+	pilotStaleAfter  time.Duration
+	pilotMaxLatency  time.Duration
+	pilotGamepad     string
+	pilotAccel       float64
+	pilotDecel       float64
+	pilotTurnAccel   float64
+	pilotNoRamp      bool
+	pilotPanels      []string
+	pilotDevices     []string
+	pilotSessionDir  string
+	pilotResume      string
 )
 
 var pilotCmd = &cobra.Command{
@@ -513,17 +1334,92 @@ the Mammotion app.
 
 The planned coverage route for the current task (the mower's zigzag
 path) is overlaid in cyan as the device streams it; toggle it with t.
+What the mower itself sees — its local costmap (brown/red cells) and
+obstacles detected by perception (orange outlines) — toggles with o.
+If a job was interrupted, a yellow ✕ marks where it will resume (see the
+resume command).
+
+Go-to (g, or click the map) picks a target with a cursor and previews a
+route to it in pink that keeps 0.4m clear of no-go zones. Enter drives it
+closed-loop from the position reports; any key or click aborts, and it
+stops by itself if the RTK fix is lost or position reports stop.
 
 Controls:
   wasd / arrows  drive          space  emergency stop
+  c              cruise (toggle; s or space also cancels)
+  g / click      go to a point on the map (enter confirms, any key aborts)
+  F              override the geofence (toggle)
   p              pause / resume  r      return to charger
   t              toggle planned coverage path
+  o              toggle perception layer (costmap + detected obstacles)
+  z              zone browser (mow a zone, its edge, or show its route)
+  m              measure distances, bearings and areas; drop annotations
+  n / N          drive the next / previous mower (with --devices)
+  tab            show / hide the side panels
+  1-5            toggle the RTK, battery, work, events and link panels
+  < >            narrow / widen the panels
   [ ]            drive speed     + -    zoom      hjkl  pan
   0              fit view        q      quit
 
+Hold w then d (or a) to arc: a turn key keeps the forward or reverse motion
+going while it steers. Speed and turn rate ramp up and down rather than
+jumping, at rates set per mower model; --accel, --decel and --turn-accel
+override them and --no-ramp turns ramping off. c cruises at the drive speed
+without holding a key: steer with a/d, change speed with [ ], and cancel
+with c, s or space. Cruise also stops at the geofence or a watchdog trip.
+
+With a map, driving is geofenced: each command's path over the next 1.5s
+is predicted from the heading and speed, and a command that would leave the
+mowing areas and channels, or come within --fence-margin of their edge or a
+no-go zone, is slowed or refused with a red warning. Turning on the spot is
+always allowed. F overrides the fence until pressed again.
+
+A watchdog guards driving: if position reports are older than --stale-after
+(default 3s), three motion sends in a row fail, or one takes longer than
+--max-latency (default 1s), the mower is stopped — the stop is sent three
+times — and driving stays disabled until position reports resume. The session
+refresh before each send is not counted in the latency. The header shows the
+send latency and the age of the last report.
+
+--gamepad drives with a gamepad on Linux: the left stick's vertical axis
+sets speed and the right stick's horizontal axis steers, in proportion, up to
+the current drive speed and turn rate; B stops, start pauses and Y docks.
+--gamepad-map changes the axis and button numbers (the gamepad command prints
+them), --gamepad-deadzone and --gamepad-expo shape the sticks.
+
+Side panels beside the map show RTK detail (satellites, L2, correction age,
+position standard deviations), a battery sparkline with its rate of change,
+the job's progress and the zone the mower is in, a log of status changes and
+events (stuck, errors, lifts, RTK loss, cloud warnings), and link statistics.
+--panels picks which start open, e.g. --panels rtk,work or --panels all.
+
+--devices shows several mowers on the account at once, e.g. --devices
+Luba-VSABC123,Yuka-XYZ or --devices all. Each mower's arrow and trail has
+its own colour (the first keeps white and blue) and every mower fetches its
+own map; the map under the view is the driven mower's, or the --map file for
+all of them. n and N hand the driving keys to the next or previous mower,
+stopping the one left behind. The header names the driven mower, then each
+other's battery and distance from it. The panels show the driven mower.
+
 Driving is disabled below --min-battery (default 15%) so a low battery
 can't be run flat away from the dock. Pause and return-to-charger remain
-available at any battery level.`,
+available at any battery level.
+
+Every position is appended to a trail file under --trail-dir (default
+~/.mammo/trails) for the coverage command; pass --trail-dir "" to disable.
+
+On exit the session — its map, the trail with times, headings and fix types,
+the planned route and the event log — is saved under --session-dir (default
+~/.mammo/sessions; "" disables). --resume-session picks up the latest saved
+session of each mower, or --resume-session=<file> a given one: the trail, route
+and events come back and the saved map is shown instead of fetching it.
+trail-export writes a session's or trail file's points as CSV or GPX.
+
+m enters measure mode: a cursor to read distances and bearings from the mower,
+points whose path length and polygon area are shown, and named annotations
+(n to add, x to remove) saved beside the map file, or per device for a fetched
+map, and drawn on every render of the map. map-show --measure does the same
+on a saved map.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		// The mammotion package logs diagnostics to stderr, which corrupts a
 		// full-screen TUI. Divert them to a file for the duration.
@@ -534,105 +1430,334 @@ available at any battery level.`,
 				logFile.Close()
 			}()
 		}
-		withSession(func(s *cloudSession) error {
+		withSessions(pilotDevices, func(sessions []*cloudSession) error {
+			return runPilot(sessions, nil)
+		})
+	},
+}
+
+// runPilot runs the pilot TUI on sessions, the first active. A live session
+// polls the device and fetches the map from it. feed, when non-nil, supplies
+// the traffic instead (a replay): it is started once every callback is
+// registered and given a function that posts a status line.
+func runPilot(sessions []*cloudSession, feed func(status func(string))) error {
+	live := feed == nil
+	started := time.Now()
+	if live {
+		for _, s := range sessions {
 			stopPolling := startPolling(s)
 			defer stopPolling()
+		}
+	}
 
-			motion := &motionController{session: s, notify: func(string) {}}
-
-			model := pilotModel{
-				session:     s,
-				motion:      motion,
-				viewOnly:    pilotViewOnly,
-				speed:       400,
-				turnRate:    450,
-				minBattery:  pilotMinBattery,
-				zoom:        1,
-				showPlanned: true,
-				status:      "connected",
-				mapStatus:   "fetching map from mower...",
-			}
-
-			p := tea.NewProgram(model, tea.WithAltScreen())
-			motion.notify = func(msg string) { p.Send(pilotStatusMsg(msg)) }
-
-			s.stateManager.OnPositionUpdate = func(x, y float32, angle int32, posType int32) {
-				// RealPos is in 0.1mm units (÷10000 → metres) and real_toward
-				// is in 0.0001° units (÷10000 → degrees), both in the same
-				// frame as the stored map. Heading is already a compass bearing
-				// (0=north, clockwise, ±180), verified against movement
-				// direction; the model normalises it to 0-360.
-				p.Send(pilotPosMsg{
-					x:       float64(x) / 10000.0,
-					y:       float64(y) / 10000.0,
-					heading: float64(angle) / 10000.0,
-					posType: posType,
-				})
+	dash, err := newDashboard(pilotPanels)
+	if err != nil {
+		return err
+	}
+	models := make([]pilotModel, len(sessions))
+	for i, s := range sessions {
+		profile := pilotDriveProfile(s.device.DeviceName)
+		models[i] = pilotModel{
+			session: s,
+			motion: &motionController{
+				session:    s,
+				notify:     func(string) {},
+				staleAfter: pilotStaleAfter,
+				maxLatency: pilotMaxLatency,
+				accel:      profile.Accel,
+				decel:      profile.Decel,
+				turnAccel:  profile.TurnAccel,
+			},
+			viewOnly:       pilotViewOnly,
+			speed:          400,
+			turnRate:       450,
+			minBattery:     pilotMinBattery,
+			dash:           dash,
+			fenceMargin:    pilotFenceMargin,
+			zoom:           1,
+			showPlanned:    true,
+			showPerception: true,
+			active:         i,
+			status:         "connected (" + profile.Name + " drive profile)",
+			mapStatus:      "fetching map from mower...",
+		}
+		if !live {
+			models[i].status = "replaying"
+			models[i].mapStatus = "loading map..."
+		}
+	}
+	// Each mower's map file: --map, or the map of the session it resumes.
+	mapFiles := make([]string, len(models))
+	for i := range models {
+		mapFiles[i] = pilotMapFile
+		if pilotResume == "" {
+			continue
+		}
+		path := pilotResume
+		if path == "latest" {
+			if path, err = latestSession(pilotSessionDir, models[i].mowerName()); err != nil {
+				models[i].status = err.Error()
+				continue
 			}
-			s.stateManager.OnPropertiesReceived = func() {
-				p.Send(pilotBatteryMsg(s.mowingDevice.BatteryPercentage))
+		}
+		saved, err := LoadSession(path)
+		if err != nil {
+			return fmt.Errorf("--resume-session: %w", err)
+		}
+		if saved.Device != models[i].mowerName() && len(models) > 1 {
+			continue // a named session file resumes only its own mower
+		}
+		models[i].resumeSession(saved)
+		if mapFiles[i] == "" {
+			mapFiles[i] = saved.MapPath(path)
+		}
+	}
+	model := models[0]
+	if len(models) > 1 {
+		model.mowers = models
+		model.status = fmt.Sprintf("connected to %d mowers, driving %s — n switches", len(models), model.mowerName())
+	}
+
+	p := tea.NewProgram(model, tea.WithAltScreen(), tea.WithMouseCellMotion())
+	for i, s := range sessions {
+		// Each mower's messages are tagged with its index, so the model
+		// can update the ones in the background.
+		send := func(msg tea.Msg) { p.Send(pilotMowerMsg{mower: i, msg: msg}) }
+		defer watchPilotMower(s, models[i].motion, send)()
+	}
+
+	// Map: load from file (--map is shared by every mower) or fetch each
+	// live in the background.
+	var shared *MowerMap
+	var sharedErr error
+	if pilotMapFile != "" {
+		shared, sharedErr = LoadMap(pilotMapFile)
+	}
+	for i, s := range sessions {
+		send := func(msg tea.Msg) { p.Send(pilotMowerMsg{mower: i, msg: msg}) }
+		go func() {
+			switch {
+			case sharedErr != nil:
+				send(pilotProgressMsg(fmt.Sprintf("map load failed: %v", sharedErr)))
+			case shared != nil:
+				send(pilotMapMsg(shared))
+			case mapFiles[i] != "":
+				if m, err := LoadMap(mapFiles[i]); err != nil {
+					send(pilotProgressMsg(fmt.Sprintf("map load failed: %v", err)))
+				} else {
+					send(pilotMapMsg(m))
+				}
+			case !live:
+				send(pilotProgressMsg("no map: pass --map to replay over a saved map"))
+			default:
+				fetchPilotMap(s, send, len(sessions) > 1)
 			}
-			s.stateManager.OnDeviceStatus = func(sysStatus, chargeState int32) {
-				p.Send(pilotDevStatusMsg{sysStatus: sysStatus, chargeState: chargeState})
+		}()
+	}
+
+	motionStop := make(chan struct{})
+	if !pilotViewOnly {
+		for _, m := range models {
+			go m.motion.run(motionStop)
+		}
+	}
+
+	if pilotGamepad != "" && !pilotViewOnly {
+		cfg, err := newGamepadConfig(gamepadMap, isEvdevPath(pilotGamepad), gamepadDead, gamepadExpo)
+		if err != nil {
+			return err
+		}
+		pad, err := openGamepad(pilotGamepad)
+		if err != nil {
+			return fmt.Errorf("gamepad: %w", err)
+		}
+		defer pad.Close()
+		go func() {
+			err := runGamepad(pad, newGamepadDriver(cfg),
+				func(linear, angular float64) { p.Send(pilotPadMsg{linear: linear, angular: angular}) },
+				func(action string) { p.Send(pilotPadButtonMsg(action)) })
+			p.Send(pilotStatusMsg(fmt.Sprintf("gamepad: %v", err)))
+		}()
+	}
+
+	if !live {
+		go feed(func(msg string) { p.Send(pilotStatusMsg(msg)) })
+	}
+
+	final, err := p.Run()
+	close(motionStop)
+	if !pilotViewOnly {
+		for _, m := range models {
+			m.motion.Stop() // belt and braces: never leave a mower driving
+		}
+	}
+	if fm, ok := final.(pilotModel); ok && pilotSessionDir != "" {
+		mowers := []pilotModel{fm}
+		if len(fm.mowers) > 1 {
+			mowers = fm.mowers
+			mowers[fm.active] = fm
+		}
+		for i, m := range mowers {
+			path, err := m.saveSession(sessionBase(pilotSessionDir, m.mowerName(), started), mapFiles[i], started)
+			if err != nil {
+				fmt.Printf("%s: session not saved: %v\n", m.mowerName(), err)
+			} else {
+				fmt.Printf("%s: session saved to %s (resume with --resume-session)\n", m.mowerName(), path)
 			}
-			s.stateManager.OnZigZagReceived = func(zz *mammotion.ZigZagData) {
-				// Page to the next frame so we collect the whole route.
-				if zz.CurrentFrame < zz.TotalFrame {
-					if ack, err := buildZigZagAck(zz.CurrentZone, zz.CurrentHash, zz.TotalFrame, zz.CurrentFrame); err == nil {
-						s.gateway.SendCloudCommand(s.device.IotId, ack)
-					}
-				}
-				pts := make([]MapPoint, 0, len(zz.DataCouple)/2)
-				for i := 0; i+1 < len(zz.DataCouple); i += 2 {
-					pts = append(pts, MapPoint{X: float64(zz.DataCouple[i]), Y: float64(zz.DataCouple[i+1])})
-				}
-				p.Send(pilotZigZagMsg{jobID: zz.JobId, zone: zz.CurrentZone, frame: zz.CurrentFrame, points: pts})
-			}
-
-			// Map: load from file or fetch live in the background.
-			go func() {
-				if pilotMapFile != "" {
-					m, err := LoadMap(pilotMapFile)
-					if err != nil {
-						p.Send(pilotProgressMsg(fmt.Sprintf("map load failed: %v", err)))
-						return
-					}
-					p.Send(pilotMapMsg(m))
-					return
-				}
-				m, err := FetchMap(s, func(msg string) { p.Send(pilotProgressMsg(msg)) })
-				if err != nil {
-					p.Send(pilotProgressMsg(fmt.Sprintf("map fetch failed: %v", err)))
-					return
-				}
-				// FetchMap clears its callbacks on exit; re-register the dock
-				// listener so a late toapp_chgpileto still reaches us.
-				s.stateManager.OnChargePilePosition = func(toward int32, x, y float32) {
-					p.Send(pilotDockMsg(DockPosition{X: float64(x), Y: float64(y), Toward: toward}))
-				}
-				p.Send(pilotMapMsg(m))
-				if pilotSaveMap != "" {
-					if err := SaveMap(m, pilotSaveMap); err != nil {
-						p.Send(pilotStatusMsg(fmt.Sprintf("map save failed: %v", err)))
-					} else {
-						p.Send(pilotStatusMsg("map saved to " + pilotSaveMap))
-					}
-				}
-			}()
+		}
+	}
+	return err
+}
+
+// pilotDriveProfile is the device's drive profile with the ramp flags
+// applied.
+func pilotDriveProfile(deviceName string) driveProfile {
+	profile := driveProfileFor(deviceName)
+	if pilotAccel > 0 {
+		profile.Accel = pilotAccel
+	}
+	if pilotDecel > 0 {
+		profile.Decel = pilotDecel
+	}
+	if pilotTurnAccel > 0 {
+		profile.TurnAccel = pilotTurnAccel
+	}
+	if pilotNoRamp {
+		profile = driveProfile{Name: "no ramp"}
+	}
+	return profile
+}
 
-			motionStop := make(chan struct{})
-			if !pilotViewOnly {
-				go motion.run(motionStop)
+// watchPilotMower registers s's callbacks, which post the mower's telemetry
+// through send and record its trail. The returned func closes the trail.
+func watchPilotMower(s *cloudSession, motion *motionController, send func(tea.Msg)) func() {
+	motion.notify = func(msg string) { send(pilotStatusMsg(msg)) }
+
+	// The events panel logs what notify would: stuck, errors, lifts, RTK
+	// loss and cloud events.
+	events := newEventDetector(s.device.DeviceName, defaultNotifyConfig(), func(ev notifyEvent) {
+		send(pilotEventMsg(ev))
+	})
+
+	var trail *trailRecorder
+	closeTrail := func() {}
+	if pilotTrailDir != "" {
+		var err error
+		if trail, err = newTrailRecorder(pilotTrailDir, s.device.DeviceName, time.Now()); err != nil {
+			log.Printf("trail recording disabled: %v", err)
+		} else {
+			closeTrail = func() { trail.Close() }
+		}
+	}
+
+	s.stateManager.OnPositionUpdate = func(x, y float32, angle int32, posType int32) {
+		// RealPos is in 0.1mm units (÷10000 → metres) and real_toward
+		// is in 0.0001° units (÷10000 → degrees), both in the same
+		// frame as the stored map. Heading is already a compass bearing
+		// (0=north, clockwise, ±180), verified against movement
+		// direction; the model normalises it to 0-360.
+		pos := pilotPosMsg{
+			x:       float64(x) / 10000.0,
+			y:       float64(y) / 10000.0,
+			heading: float64(angle) / 10000.0,
+			posType: posType,
+			t:       time.Now(),
+		}
+		if trail != nil {
+			if err := trail.Record(TrailPoint{T: pos.t, X: pos.x, Y: pos.y, Heading: pos.heading, PosType: posType}); err != nil {
+				log.Printf("trail write: %v", err)
 			}
+		}
+		motion.Telemetry()
+		events.Position(pos.x, pos.y, posType)
+		send(pos)
+	}
+	s.stateManager.OnPropertiesReceived = func() {
+		send(pilotBatteryMsg(s.mowingDevice.BatteryPercentage))
+	}
+	s.stateManager.OnDeviceStatus = func(sysStatus, chargeState int32) {
+		events.Status(sysStatus, chargeState)
+		send(pilotDevStatusMsg{sysStatus: sysStatus, chargeState: chargeState})
+	}
+	s.stateManager.OnRtkReport = func(r *mammotion.RtkData) {
+		send(pilotRtkMsg(r))
+	}
+	s.stateManager.OnErrorCode = events.ErrorCode
+	s.stateManager.OnLockState = events.LockState
+	s.stateManager.OnDeviceEvent = events.DeviceEvent
+	s.stateManager.OnZigZagReceived = func(zz *mammotion.ZigZagData) {
+		// Page to the next frame so we collect the whole route.
+		if zz.CurrentFrame < zz.TotalFrame {
+			if ack, err := buildZigZagAck(zz.CurrentZone, zz.CurrentHash, zz.TotalFrame, zz.CurrentFrame); err == nil {
+				s.send(ack)
+			}
+		}
+		pts := make([]MapPoint, 0, len(zz.DataCouple)/2)
+		for i := 0; i+1 < len(zz.DataCouple); i += 2 {
+			pts = append(pts, MapPoint{X: float64(zz.DataCouple[i]), Y: float64(zz.DataCouple[i+1])})
+		}
+		send(pilotZigZagMsg{jobID: zz.JobId, zone: zz.CurrentZone, hash: zz.CurrentHash, frame: zz.CurrentFrame, points: pts})
+	}
+
+	s.stateManager.OnBreakPointReceived = func(bp *mammotion.BreakPointData) {
+		send(pilotBreakPointMsg(bp))
+	}
+	s.stateManager.OnWorkReport = func(w *mammotion.WorkData) {
+		events.Work(w)
+		send(pilotWorkMsg(w))
+	}
 
-			_, err := p.Run()
-			close(motionStop)
-			if !pilotViewOnly {
-				motion.Stop() // belt and braces: never leave the mower driving
+	s.stateManager.OnCostmapReceived = func(cm *mammotion.CostmapData) {
+		send(pilotCostmapMsg(cm))
+	}
+	s.stateManager.OnPerceptionReceived = func(pd *mammotion.PerceptionData) {
+		if pd.HeartBeat && len(pd.Obstacles) == 0 {
+			return // keep the last obstacles until a real frame replaces them
+		}
+		obs := make([][]MapPoint, 0, len(pd.Obstacles))
+		for _, ob := range pd.Obstacles {
+			pts := make([]MapPoint, 0, len(ob.Points)/2)
+			for i := 0; i+1 < len(ob.Points); i += 2 {
+				pts = append(pts, MapPoint{X: float64(ob.Points[i]), Y: float64(ob.Points[i+1])})
 			}
-			return err
-		})
-	},
+			obs = append(obs, pts)
+		}
+		send(pilotPerceptionMsg(obs))
+	}
+	return closeTrail
+}
+
+// fetchPilotMap fetches s's map for pilot, saving it to --save-map; with
+// several mowers each is saved under its device name.
+func fetchPilotMap(s *cloudSession, send func(tea.Msg), several bool) {
+	m, err := FetchMap(s, func(msg string) { send(pilotProgressMsg(msg)) })
+	if err != nil {
+		send(pilotProgressMsg(fmt.Sprintf("map fetch failed: %v", err)))
+		return
+	}
+	// FetchMap clears its callbacks on exit; re-register the dock
+	// listener so a late toapp_chgpileto still reaches us.
+	s.stateManager.OnChargePilePosition = func(toward int32, x, y float32) {
+		send(pilotDockMsg(DockPosition{X: float64(x), Y: float64(y), Toward: toward}))
+	}
+	// A fetched map has no file: its annotations live in the device's notes
+	// file.
+	if err := loadMapNotes(m, ""); err != nil {
+		send(pilotStatusMsg(fmt.Sprintf("annotations not loaded: %v", err)))
+	}
+	send(pilotMapMsg(m))
+	if pilotSaveMap != "" {
+		path := pilotSaveMap
+		if several {
+			ext := filepath.Ext(path)
+			path = strings.TrimSuffix(path, ext) + "-" + s.device.DeviceName + ext
+		}
+		if err := SaveMap(m, path); err != nil {
+			send(pilotStatusMsg(fmt.Sprintf("map save failed: %v", err)))
+		} else {
+			send(pilotStatusMsg("map saved to " + path))
+		}
+	}
 }
 
 func init() {
@@ -640,5 +1765,20 @@ func init() {
 	pilotCmd.Flags().StringVar(&pilotSaveMap, "save-map", "", "save the fetched map to a JSON file")
 	pilotCmd.Flags().BoolVar(&pilotViewOnly, "view-only", false, "disable driving controls")
 	pilotCmd.Flags().IntVar(&pilotMinBattery, "min-battery", 15, "disable driving below this battery percentage")
+	pilotCmd.Flags().Float64Var(&pilotFenceMargin, "fence-margin", 0.3, "geofence margin in metres inside area edges and around no-go zones")
+	pilotCmd.Flags().DurationVar(&pilotStaleAfter, "stale-after", 3*time.Second, "stop driving when position reports are older than this (0 disables)")
+	pilotCmd.Flags().DurationVar(&pilotMaxLatency, "max-latency", time.Second, "stop driving when a motion send takes longer than this (0 disables)")
+	pilotCmd.Flags().Float64Var(&pilotAccel, "accel", 0, "speed-up ramp in mm/s² (0 uses the mower model's profile)")
+	pilotCmd.Flags().Float64Var(&pilotDecel, "decel", 0, "slow-down ramp in mm/s² (0 uses the mower model's profile)")
+	pilotCmd.Flags().Float64Var(&pilotTurnAccel, "turn-accel", 0, "turn-rate ramp in mrad/s² (0 uses the mower model's profile)")
+	pilotCmd.Flags().BoolVar(&pilotNoRamp, "no-ramp", false, "apply motion commands instantly, without ramps")
+	pilotCmd.Flags().StringSliceVar(&pilotPanels, "panels", nil, "side panels to open at start: rtk, battery, work, events, link or all")
+	pilotCmd.Flags().StringSliceVar(&pilotDevices, "devices", nil, "mowers to show, by device name or nickname, or all (default the first)")
+	pilotCmd.Flags().StringVar(&pilotGamepad, "gamepad", "", "drive with a joystick (/dev/input/js*) or evdev (/dev/input/event*) gamepad")
+	addGamepadFlags(pilotCmd)
+	pilotCmd.Flags().StringVar(&pilotTrailDir, "trail-dir", defaultTrailDir(), "record the mower's trail here for coverage (empty disables)")
+	pilotCmd.Flags().StringVar(&pilotSessionDir, "session-dir", defaultSessionDir(), "save the session here on exit (empty disables)")
+	pilotCmd.Flags().StringVar(&pilotResume, "resume-session", "", "resume the latest saved session, or the given session file")
+	pilotCmd.Flags().Lookup("resume-session").NoOptDefVal = "latest"
 	rootCmd.AddCommand(pilotCmd)
 }
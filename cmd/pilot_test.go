@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"mammo/mammotion"
+)
+
+func TestMotionControllerDrivesAndStops(t *testing.T) {
+	s, sim := newSimSession(simLawn())
+	sim.SetPose(0, 0, 0)
+	mc := &motionController{session: s, notify: func(string) {}}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		mc.run(stop)
+		close(done)
+	}()
+	defer func() {
+		close(stop)
+		<-done
+	}()
+
+	mc.Drive(500, 0, 400*time.Millisecond)
+	time.Sleep(250 * time.Millisecond)
+	if lin, ang := sim.Motion(); lin != 500 || ang != 0 {
+		t.Fatalf("mower should be driving forward, got linear %d angular %d", lin, ang)
+	}
+	sim.Step(time.Second)
+	if x, y, _ := sim.Pose(); math.Abs(x) > 1e-9 || math.Abs(y-0.5) > 1e-9 {
+		t.Errorf("1s at 500mm/s heading north should reach 0, 0.5; got %.3f, %.3f", x, y)
+	}
+
+	// Releasing the key lets the deadline lapse; the controller must send
+	// an explicit stop rather than rely on the mower's own timeout.
+	time.Sleep(600 * time.Millisecond)
+	if lin, ang := sim.Motion(); lin != 0 || ang != 0 {
+		t.Errorf("expected a stop after the hold expired, got linear %d angular %d", lin, ang)
+	}
+}
+
+func TestMotionWatchdog(t *testing.T) {
+	s, sim := newSimSession(simLawn())
+	var mu sync.Mutex
+	var notes []string
+	mc := &motionController{session: s, staleAfter: 400 * time.Millisecond, maxLatency: time.Second, notify: func(n string) {
+		mu.Lock()
+		notes = append(notes, n)
+		mu.Unlock()
+	}}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		mc.run(stop)
+		close(done)
+	}()
+	defer func() {
+		close(stop)
+		<-done
+	}()
+
+	mc.Telemetry()
+	mc.Drive(500, 0, 2*time.Second)
+	time.Sleep(250 * time.Millisecond)
+	if lin, _ := sim.Motion(); lin != 500 {
+		t.Fatalf("should drive while reports are fresh, linear %d", lin)
+	}
+
+	// Reports stop: the watchdog must stop the mower before the hold ends
+	// and refuse to drive again.
+	time.Sleep(700 * time.Millisecond)
+	h := mc.Health()
+	if lin, _ := sim.Motion(); lin != 0 || !strings.Contains(h.Tripped, "no position report") {
+		t.Fatalf("after reports went stale: linear %d, health %+v", lin, h)
+	}
+	if mc.Drive(500, 0, time.Second) {
+		t.Error("Drive accepted while tripped")
+	}
+
+	mc.Telemetry()
+	mc.Telemetry()
+	if h := mc.Health(); h.Tripped != "" || !mc.Drive(500, 0, 300*time.Millisecond) {
+		t.Errorf("driving not re-enabled after reports resumed: %+v", h)
+	}
+	mu.Lock()
+	if len(notes) < 2 || !strings.HasPrefix(notes[0], "WATCHDOG:") || notes[len(notes)-1] != "link recovered — driving re-enabled" {
+		t.Errorf("notes = %q", notes)
+	}
+	mu.Unlock()
+
+	// Sends failing in a row trip it too.
+	for i := 0; i < watchdogMaxFailures; i++ {
+		mc.recordSend(time.Millisecond, mammotion.ErrTransportClosed)
+	}
+	if reason := mc.recordSend(time.Millisecond, mammotion.ErrTransportClosed); !strings.Contains(reason, "sends failed") {
+		t.Errorf("repeated failures: %q", reason)
+	}
+	if reason := mc.recordSend(2*time.Second, nil); !strings.Contains(reason, "took 2s") {
+		t.Errorf("slow send: %q", reason)
+	}
+	if label := linkLabel(linkHealth{Latency: 120 * time.Millisecond, Age: 800 * time.Millisecond, Failures: 1}); label != "link 120ms rpt 0.8s 1✕" {
+		t.Errorf("linkLabel = %q", label)
+	}
+}
+
+// slowRefreshTransport takes its time renewing the session, as the cloud
+// does when the token is near expiry, and can be made to fail.
+type slowRefreshTransport struct {
+	mammotion.Transport
+	delay time.Duration
+	fail  atomic.Bool
+}
+
+func (t *slowRefreshTransport) Refresh() error {
+	time.Sleep(t.delay)
+	if t.fail.Load() {
+		return errors.New("token renewal refused")
+	}
+	return nil
+}
+
+func TestMotionWatchdogIgnoresSessionRefresh(t *testing.T) {
+	s, sim := newSimSession(simLawn())
+	tr := &slowRefreshTransport{Transport: s.transport, delay: 200 * time.Millisecond}
+	s.transport = tr
+	var mu sync.Mutex
+	var notes []string
+	mc := &motionController{session: s, maxLatency: 100 * time.Millisecond, notify: func(n string) {
+		mu.Lock()
+		notes = append(notes, n)
+		mu.Unlock()
+	}}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		mc.run(stop)
+		close(done)
+	}()
+	defer func() {
+		close(stop)
+		<-done
+	}()
+
+	tr.fail.Store(true)
+	mc.Drive(500, 0, 3*time.Second)
+	time.Sleep(time.Second)
+	h := mc.Health()
+	if lin, _ := sim.Motion(); lin != 500 || h.Tripped != "" {
+		t.Fatalf("slow refresh tripped the watchdog: linear %d, health %+v", lin, h)
+	}
+	if !h.RefreshFailing || !strings.HasSuffix(linkLabel(h), " refresh✕") {
+		t.Errorf("refresh failure not shown: %+v, label %q", h, linkLabel(h))
+	}
+	tr.fail.Store(false)
+	time.Sleep(500 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"session refresh: token renewal refused", "session refresh recovered"}
+	if !slices.Equal(notes, want) {
+		t.Errorf("notes = %q, want %q", notes, want)
+	}
+}
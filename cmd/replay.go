@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"mammo/aliyuniot"
+	"mammo/mammotion"
+	pb "mammo/proto"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// lubaMsgPath names a LubaMsg by its chain of set oneof fields, e.g.
+// "nav.toapp_zigzag" or "net.todev_ble_sync".
+func lubaMsgPath(data []byte) string {
+	var msg pb.LubaMsg
+	if err := proto.Unmarshal(data, &msg); err != nil {
+		return fmt.Sprintf("undecodable (%d bytes)", len(data))
+	}
+	var names []string
+	m := msg.ProtoReflect()
+	for depth := 0; depth < 2; depth++ {
+		var set protoreflect.FieldDescriptor
+		oneofs := m.Descriptor().Oneofs()
+		for i := 0; i < oneofs.Len() && set == nil; i++ {
+			if od := oneofs.Get(i); !od.IsSynthetic() {
+				set = m.WhichOneof(od)
+			}
+		}
+		if set == nil {
+			break
+		}
+		names = append(names, string(set.Name()))
+		if set.Kind() != protoreflect.MessageKind {
+			break
+		}
+		m = m.Get(set).Message()
+	}
+	if len(names) == 0 {
+		return "empty"
+	}
+	return strings.Join(names, ".")
+}
+
+// newReplaySession returns a session whose device→app traffic is injected
+// with the returned transport's Deliver. Commands sent on it are recorded by
+// the loopback and go nowhere.
+func newReplaySession(deviceName string) (*cloudSession, *mammotion.LoopbackTransport) {
+	mowing := &mammotion.MowingDevice{}
+	sm := mammotion.NewStateManager(mowing)
+	loop := mammotion.NewLoopbackTransport(nil)
+	sm.Attach(loop)
+	return &cloudSession{
+		transport:    loop,
+		device:       &aliyuniot.Device{DeviceName: deviceName},
+		mowingDevice: mowing,
+		stateManager: sm,
+	}, loop
+}
+
+var (
+	replaySpeed   float64
+	replayPilot   bool
+	replayMapFile string
+)
+
+var replayCmd = &cobra.Command{
+	Use:   "replay <session.mrec>",
+	Short: "Feed a recorded session back through the decoder, or through pilot",
+	Long: `Replays a recording made with --record. Every device→app message is fed
+through the same decoding as a live session, keeping the recorded timing
+divided by --speed (0 replays as fast as possible).
+
+Without --pilot each message is listed with its time, direction (→ app to
+mower, ← mower to app) and type, alongside the decoder's log. With --pilot
+the recording drives the pilot view (view-only; pass --map for the lawn).
+Recorded app→mower commands are listed but never re-sent.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		rec, err := mammotion.LoadRecording(args[0])
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		s, loop := newReplaySession(rec.Device)
+		defer s.Close()
+
+		if replayPilot {
+			if logFile, err := os.OpenFile("/tmp/mammo-pilot.log", os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644); err == nil {
+				log.SetOutput(logFile)
+				defer func() {
+					log.SetOutput(os.Stderr)
+					logFile.Close()
+				}()
+			}
+			pilotMapFile = replayMapFile
+			pilotViewOnly = true
+			pilotTrailDir = ""
+			pilotSessionDir, pilotResume = "", ""
+			err := runPilot([]*cloudSession{s}, func(status func(string)) {
+				status(fmt.Sprintf("replaying %s at %gx", rec.Device, replaySpeed))
+				rec.Replay(replaySpeed, nil, func(m mammotion.RecordedMsg) {
+					if !m.Outbound {
+						loop.Deliver(m.Data)
+					}
+				})
+				status("replay finished")
+			})
+			if err != nil {
+				fmt.Println("Error:", err)
+			}
+			return
+		}
+
+		fmt.Printf("Recording of %s, started %s, %d messages over %s\n",
+			rec.Device, rec.Start.Format(time.RFC3339), len(rec.Messages), rec.Duration().Round(time.Millisecond))
+		var in, out int
+		rec.Replay(replaySpeed, nil, func(m mammotion.RecordedMsg) {
+			arrow := "←"
+			if m.Outbound {
+				arrow = "→"
+				out++
+			} else {
+				in++
+			}
+			fmt.Printf("%10.3fs %s %s\n", m.At.Seconds(), arrow, lubaMsgPath(m.Data))
+			if !m.Outbound {
+				loop.Deliver(m.Data)
+			}
+		})
+		fmt.Printf("Replayed %d device messages; %d recorded commands not re-sent\n", in, out)
+	},
+}
+
+func init() {
+	replayCmd.Flags().Float64Var(&replaySpeed, "speed", 1, "playback speed multiple (0 = as fast as possible)")
+	replayCmd.Flags().BoolVar(&replayPilot, "pilot", false, "replay into the pilot view")
+	replayCmd.Flags().StringVar(&replayMapFile, "map", "", "saved map file to draw under a --pilot replay")
+	rootCmd.AddCommand(replayCmd)
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"mammo/mammotion"
+)
+
+func TestRecordAndReplaySession(t *testing.T) {
+	s, sim := newSimSession(simLawn())
+	var buf bytes.Buffer
+	rec, err := mammotion.NewSessionRecorder(&buf, "Luba-SIM", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.transport = mammotion.NewRecordingTransport(s.transport, rec)
+	sim.SetPose(3.25, -1.5, 90)
+	if err := primeSession(s); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FetchMap(s, nil); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	data := buf.Bytes()
+	got, err := mammotion.ReadRecording(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var in, out int
+	for i, m := range got.Messages {
+		if i > 0 && m.At < got.Messages[i-1].At {
+			t.Fatalf("message %d timestamp went backwards", i)
+		}
+		if m.Outbound {
+			out++
+		} else {
+			in++
+		}
+	}
+	if got.Device != "Luba-SIM" || out != len(sim.Received()) || in == 0 {
+		t.Fatalf("recording of %q has %d commands (simulator got %d) and %d replies",
+			got.Device, out, len(sim.Received()), in)
+	}
+	if p := lubaMsgPath(got.Messages[0].Data); p != "net.todev_ble_sync" {
+		t.Errorf("first message is %s, want the ble sync", p)
+	}
+
+	// A recording cut off mid-record keeps every complete record.
+	cut, err := mammotion.ReadRecording(bytes.NewReader(data[:len(data)-3]))
+	if err != nil || len(cut.Messages) != len(got.Messages)-1 {
+		t.Errorf("truncated recording: err %v, %d messages", err, len(cut.Messages))
+	}
+
+	replay, loop := newReplaySession(got.Device)
+	var x, y float32
+	replay.stateManager.OnPositionUpdate = func(px, py float32, _ int32, _ int32) { x, y = px, py }
+	got.Replay(0, nil, func(m mammotion.RecordedMsg) {
+		if !m.Outbound {
+			loop.Deliver(m.Data)
+		}
+	})
+	if x != 32500 || y != -15000 {
+		t.Errorf("replayed position %.0f, %.0f, want 32500, -15000", x, y)
+	}
+	if len(loop.Sent()) != 0 {
+		t.Errorf("replay re-sent %d commands", len(loop.Sent()))
+	}
+}
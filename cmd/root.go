@@ -6,7 +6,6 @@ import (
 	"sync"
 	"time"
 
-	"mammo/aliyuniot"
 	"mammo/auth"
 	"mammo/mammotion"
 
@@ -33,7 +32,7 @@ func Login() {
 
 	country_code := client.LoginInfo.UserInformation.DomainAbbreviation
 
-	cg := aliyuniot.NewCloudIOTGateway()
+	cg := newGateway()
 	_, err = cg.GetRegion(country_code, client.LoginInfo.AuthorizationCode)
 
 	if err != nil {
@@ -208,7 +207,7 @@ var batteryCmd = &cobra.Command{
 			return
 		}
 
-		cg := aliyuniot.NewCloudIOTGateway()
+		cg := newGateway()
 		_, err = cg.GetRegion(client.LoginInfo.UserInformation.DomainAbbreviation, client.LoginInfo.AuthorizationCode)
 		if err != nil {
 			fmt.Println("Error getting region:", err)
@@ -360,5 +359,12 @@ func init() {
 	// when this action is called directly.
 	rootCmd.PersistentFlags().StringVarP(&username, "username", "u", "", "Username for login")
 	rootCmd.PersistentFlags().StringVarP(&password, "password", "p", "", "Password for login")
+	rootCmd.PersistentFlags().StringVar(&cloudURL, "cloud-url", "", "base URL of a stand-in account/IoT API (e.g. from mammo fakecloud)")
+	rootCmd.PersistentFlags().StringVar(&brokerURL, "broker", "", "MQTT broker URL overriding the regional Aliyun broker (tcp:// or tls://)")
+	rootCmd.PersistentFlags().StringVar(&caFile, "ca-file", "", "PEM roots to verify the broker certificate against")
+	rootCmd.PersistentFlags().StringVar(&recordFile, "record", "", "record every message to and from the mower to this file (see replay)")
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		return applyEndpoints()
+	}
 }
 
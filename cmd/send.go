@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	pb "mammo/proto"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// subMsgEnvelope is the msgtype and receiver the app uses for each LubaMsg
+// sub-message, as in the builders in mammotion/command.go.
+var subMsgEnvelope = map[string]struct {
+	msgtype pb.MsgCmdType
+	rcver   pb.MsgDevice
+}{
+	"net":    {pb.MsgCmdType_MSG_CMD_TYPE_ESP, pb.MsgDevice_DEV_COMM_ESP},
+	"sys":    {pb.MsgCmdType_MSG_CMD_TYPE_EMBED_SYS, pb.MsgDevice_DEV_MAINCTL},
+	"nav":    {pb.MsgCmdType_MSG_CMD_TYPE_NAV, pb.MsgDevice_DEV_MAINCTL},
+	"driver": {pb.MsgCmdType_MSG_CMD_TYPE_EMBED_DRIVER, pb.MsgDevice_DEV_MAINCTL},
+	"ota":    {pb.MsgCmdType_MSG_CMD_TYPE_EMBED_OTA, pb.MsgDevice_DEV_MAINCTL},
+	"mul":    {pb.MsgCmdType_MSG_CMD_TYPE_MUL, pb.MsgDevice_SOC_MODULE_MULTIMEDIA},
+	"pept":   {pb.MsgCmdType_MSG_CMD_TYPE_PEPT, pb.MsgDevice_DEV_PERCEPTION},
+}
+
+// motionPaths are the messages that move the mower or drive its motors and
+// blades directly; send refuses them without --allow-motion.
+var motionPaths = []string{"driver", "nav.todev_one_touch_leave_pile"}
+
+func isMotionPath(path string) bool {
+	for _, p := range motionPaths {
+		if pathMatches(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildRawMsg parses a protojson LubaMsg and fills the envelope fields it
+// leaves unset the way buildNav does: app sender, request attribute,
+// msgtype and receiver from the sub-message, version, subtype, seqs and the
+// current timestamp. Non-zero fields given in the JSON are kept; the receiver
+// is only defaulted along with the msgtype.
+func buildRawMsg(js string) (*pb.LubaMsg, []byte, error) {
+	msg := &pb.LubaMsg{}
+	if err := protojson.Unmarshal([]byte(js), msg); err != nil {
+		return nil, nil, fmt.Errorf("parsing --json: %w", err)
+	}
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, nil, err
+	}
+	path := lubaMsgPath(data)
+	sub, _, _ := strings.Cut(path, ".")
+	env, known := subMsgEnvelope[sub]
+	if msg.Msgtype == pb.MsgCmdType_MSG_CMD_TYPE_START {
+		if !known {
+			return nil, nil, fmt.Errorf("no default envelope for %q: set msgtype and rcver in the JSON", path)
+		}
+		msg.Msgtype = env.msgtype
+		if msg.Rcver == pb.MsgDevice_DEV_COMM_ESP {
+			msg.Rcver = env.rcver
+		}
+	}
+	if msg.Sender == pb.MsgDevice_DEV_COMM_ESP {
+		msg.Sender = pb.MsgDevice_DEV_MOBILEAPP
+	}
+	if msg.Msgattr == pb.MsgAttr_MSG_ATTR_NONE {
+		msg.Msgattr = pb.MsgAttr_MSG_ATTR_REQ
+	}
+	if msg.Seqs == 0 {
+		msg.Seqs = 1
+	}
+	if msg.Version == 0 {
+		msg.Version = 1
+	}
+	if msg.Subtype == 0 {
+		msg.Subtype = 1
+	}
+	if msg.Timestamp == 0 {
+		msg.Timestamp = uint64(time.Now().UnixMilli())
+	}
+	data, err = proto.Marshal(msg)
+	return msg, data, err
+}
+
+var (
+	sendJSON        string
+	sendDryRun      bool
+	sendAllowMotion bool
+	sendWait        int
+	sendTypes       []string
+	sendExclude     []string
+	sendCompact     bool
+)
+
+var sendCmd = &cobra.Command{
+	Use:   "send --json '<LubaMsg>'",
+	Short: "Send any LubaMsg given as protojson and print the replies",
+	Long: `Parses --json as a LubaMsg (protojson; field names in either
+camelCase or snake_case), fills in the envelope — sender, receiver and
+msgtype from the sub-message, timestamp, seqs — unless the JSON sets it, sends
+it and prints the mower's replies for --wait seconds as inspect does.
+
+  mammo send --json '{"nav":{"todevTaskctrl":{"type":1,"action":1}}}'
+
+--dry-run prints the completed message and its bytes (hex and base64)
+without connecting. Driver messages (motion, blades, cutting height) and
+one-touch leave-pile move the mower and are refused unless --allow-motion is
+given.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if sendJSON == "" {
+			fmt.Println("Error: --json is required")
+			return
+		}
+		_, data, err := buildRawMsg(sendJSON)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		path := lubaMsgPath(data)
+		if isMotionPath(path) && !sendAllowMotion {
+			fmt.Printf("Error: %s moves the mower; pass --allow-motion to send it\n", path)
+			return
+		}
+
+		out, err := formatLubaMsg(data, sendCompact)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		if sendDryRun {
+			fmt.Println(out)
+			fmt.Println("hex:   ", hex.EncodeToString(data))
+			fmt.Println("base64:", base64.StdEncoding.EncodeToString(data))
+			return
+		}
+
+		filter := msgFilter{include: sendTypes, exclude: sendExclude}
+		withSession(func(s *cloudSession) error {
+			var mu sync.Mutex
+			unsubscribe := s.transport.Subscribe(func(reply []byte) {
+				if !filter.match(lubaMsgPath(reply)) {
+					return
+				}
+				text, err := formatLubaMsg(reply, sendCompact)
+				if err != nil {
+					text = fmt.Sprintf("undecodable (%d bytes): %v", len(reply), err)
+				}
+				mu.Lock()
+				fmt.Printf("%s ← %s\n", time.Now().Format("15:04:05.000"), text)
+				mu.Unlock()
+			})
+			defer unsubscribe()
+
+			fmt.Printf("%s → %s\n", time.Now().Format("15:04:05.000"), out)
+			if err := s.send(data); err != nil {
+				return err
+			}
+			time.Sleep(time.Duration(sendWait) * time.Second)
+			return nil
+		})
+	},
+}
+
+func init() {
+	sendCmd.Flags().StringVar(&sendJSON, "json", "", "the LubaMsg as protojson")
+	sendCmd.Flags().BoolVar(&sendDryRun, "dry-run", false, "print the encoded message without connecting")
+	sendCmd.Flags().BoolVar(&sendAllowMotion, "allow-motion", false, "allow messages that move the mower or drive its motors")
+	sendCmd.Flags().IntVar(&sendWait, "wait", 5, "seconds to print replies for")
+	sendCmd.Flags().StringSliceVar(&sendTypes, "type", nil, "only print replies of these type paths (as inspect)")
+	sendCmd.Flags().StringSliceVar(&sendExclude, "exclude", []string{"sys.toapp_report_data"}, "hide replies of these type paths")
+	sendCmd.Flags().BoolVar(&sendCompact, "compact", false, "one line per message")
+	rootCmd.AddCommand(sendCmd)
+}
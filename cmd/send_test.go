@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"testing"
+
+	pb "mammo/proto"
+)
+
+func TestSendBuildsEnvelope(t *testing.T) {
+	msg, data, err := buildRawMsg(`{"nav":{"todevTaskctrl":{"type":1,"action":1}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Msgtype != pb.MsgCmdType_MSG_CMD_TYPE_NAV || msg.Sender != pb.MsgDevice_DEV_MOBILEAPP ||
+		msg.Rcver != pb.MsgDevice_DEV_MAINCTL || msg.Msgattr != pb.MsgAttr_MSG_ATTR_REQ || msg.Timestamp == 0 {
+		t.Errorf("envelope not filled like buildNav: %v", msg)
+	}
+	if tc := msg.GetNav().GetTodevTaskctrl(); tc.GetType() != 1 || tc.GetAction() != 1 {
+		t.Errorf("sub-message = %v", msg.GetNav())
+	}
+	if isMotionPath(lubaMsgPath(data)) {
+		t.Error("task control treated as motion")
+	}
+
+	// The simulator decodes what send builds exactly as a typed command.
+	s, sim := newSimSession(simLawn())
+	if err := sim.StartJob(101); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.send(data); err != nil {
+		t.Fatal(err)
+	}
+	if sim.Status() != sysStatusPaused {
+		t.Errorf("sys_status after raw pause = %d, want paused", sim.Status())
+	}
+
+	msg, _, err = buildRawMsg(`{"net":{"todev_ble_sync":3}}`)
+	if err != nil || msg.Msgtype != pb.MsgCmdType_MSG_CMD_TYPE_ESP || msg.Rcver != pb.MsgDevice_DEV_COMM_ESP {
+		t.Errorf("net envelope: %v, err %v", msg, err)
+	}
+	msg, _, err = buildRawMsg(`{"msgtype":"MSG_CMD_TYPE_NAV","rcver":"DEV_NAVIGATION","seqs":9,"nav":{"todevSustask":1}}`)
+	if err != nil || msg.Rcver != pb.MsgDevice_DEV_NAVIGATION || msg.Seqs != 9 {
+		t.Errorf("explicit envelope fields not kept: %v, err %v", msg, err)
+	}
+	_, data, err = buildRawMsg(`{"driver":{"todevDevmotionCtrl":{"setLinearSpeed":500}}}`)
+	if err != nil || !isMotionPath(lubaMsgPath(data)) {
+		t.Errorf("motion control must be recognised as motion (err %v)", err)
+	}
+	if _, _, err := buildRawMsg(`{"nav":{"noSuchField":1}}`); err == nil {
+		t.Error("unknown field accepted")
+	}
+}
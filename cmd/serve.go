@@ -0,0 +1,463 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"mammo/mammotion"
+	pb "mammo/proto"
+
+	"github.com/gorilla/websocket"
+	"github.com/spf13/cobra"
+)
+
+// apiPosition is the mower's pose in map metres and compass degrees.
+type apiPosition struct {
+	X       float64 `json:"x"`
+	Y       float64 `json:"y"`
+	Heading float64 `json:"heading"`
+	PosType int32   `json:"posType"`
+	Fix     string  `json:"fix"`
+}
+
+// apiJob is the current job summary from rpt_work.
+type apiJob struct {
+	ProgressPercent int32 `json:"progressPercent"`
+	AreaM2          int32 `json:"areaM2"`
+	ElapsedMin      int32 `json:"elapsedMin"`
+	TotalMin        int32 `json:"totalMin"`
+	KnifeHeight     int32 `json:"knifeHeight"`
+	PathHash        int64 `json:"pathHash"`
+}
+
+// apiState is the GET /state document.
+type apiState struct {
+	Device      string       `json:"device"`
+	Battery     int          `json:"battery"`
+	SysStatus   int32        `json:"sysStatus"`
+	ChargeState int32        `json:"chargeState"`
+	Position    *apiPosition `json:"position,omitempty"`
+	Job         *apiJob      `json:"job,omitempty"`
+	BreakPoint  *MapPoint    `json:"breakPoint,omitempty"`
+	ViewOnly    bool         `json:"viewOnly"`
+	UpdatedAt   time.Time    `json:"updatedAt"`
+}
+
+// apiEvent is one WebSocket message: type is "state" (sent on connect),
+// "position", "status", "work" or "zigzag".
+type apiEvent struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data"`
+}
+
+// apiZigZag is one frame of the planned coverage path.
+type apiZigZag struct {
+	JobID       uint64     `json:"jobId"`
+	Zone        int32      `json:"zone"`
+	Frame       int32      `json:"frame"`
+	TotalFrames int32      `json:"totalFrames"`
+	Points      []MapPoint `json:"points"`
+}
+
+// apiServer serves one session's state, map and commands over HTTP and
+// streams its events to WebSocket clients.
+type apiServer struct {
+	s          *cloudSession
+	token      string
+	viewOnly   bool
+	minBattery int
+
+	mu      sync.Mutex
+	state   apiState
+	mowMap  *MowerMap
+	mapErr  string
+	clients map[chan []byte]struct{}
+}
+
+var apiUpgrader = websocket.Upgrader{
+	// Access is controlled by the bearer token, so dashboards served from
+	// any origin may connect.
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+// newAPIServer takes over s's state callbacks. Every request must carry
+// token.
+func newAPIServer(s *cloudSession, token string, viewOnly bool, minBattery int) *apiServer {
+	a := &apiServer{
+		s:          s,
+		token:      token,
+		viewOnly:   viewOnly,
+		minBattery: minBattery,
+		clients:    make(map[chan []byte]struct{}),
+		mapErr:     "map not loaded yet",
+	}
+	a.state.Device = s.device.DeviceName
+	a.state.ViewOnly = viewOnly
+
+	sm := s.stateManager
+	sm.OnPropertiesReceived = func() {
+		a.update("status", func(st *apiState) any {
+			st.Battery = s.mowingDevice.BatteryPercentage
+			return apiStatus(st)
+		})
+	}
+	sm.OnDeviceStatus = func(sysStatus, chargeState int32) {
+		a.update("status", func(st *apiState) any {
+			st.SysStatus, st.ChargeState = sysStatus, chargeState
+			return apiStatus(st)
+		})
+	}
+	sm.OnPositionUpdate = func(x, y float32, angle int32, posType int32) {
+		// RealPos and real_toward are in 0.1mm and 0.0001° units.
+		a.update("position", func(st *apiState) any {
+			st.Position = &apiPosition{
+				X:       float64(x) / 10000,
+				Y:       float64(y) / 10000,
+				Heading: float64(angle) / 10000,
+				PosType: posType,
+				Fix:     rtkLabel(posType),
+			}
+			return st.Position
+		})
+	}
+	sm.OnWorkReport = func(w *mammotion.WorkData) {
+		a.update("work", func(st *apiState) any {
+			st.Job = &apiJob{
+				ProgressPercent: w.Area >> 16,
+				AreaM2:          w.Area & 0xffff,
+				ElapsedMin:      w.Progress >> 16,
+				TotalMin:        w.Progress & 0xffff,
+				KnifeHeight:     w.KnifeHeight,
+				PathHash:        w.PathHash,
+			}
+			st.BreakPoint = nil
+			if bp := w.BreakPoint; bp != nil {
+				st.BreakPoint = &MapPoint{X: bp.X, Y: bp.Y}
+			}
+			return st.Job
+		})
+	}
+	sm.OnZigZagReceived = func(zz *mammotion.ZigZagData) {
+		// Page to the next frame so clients get the whole route.
+		if zz.CurrentFrame < zz.TotalFrame {
+			if ack, err := buildZigZagAck(zz.CurrentZone, zz.CurrentHash, zz.TotalFrame, zz.CurrentFrame); err == nil {
+				s.send(ack)
+			}
+		}
+		ev := apiZigZag{JobID: zz.JobId, Zone: zz.CurrentZone, Frame: zz.CurrentFrame, TotalFrames: zz.TotalFrame}
+		for i := 0; i+1 < len(zz.DataCouple); i += 2 {
+			ev.Points = append(ev.Points, MapPoint{X: float64(zz.DataCouple[i]), Y: float64(zz.DataCouple[i+1])})
+		}
+		a.broadcast("zigzag", ev)
+	}
+	return a
+}
+
+// apiStatus is the data of a "status" event.
+func apiStatus(st *apiState) any {
+	return map[string]any{"battery": st.Battery, "sysStatus": st.SysStatus, "chargeState": st.ChargeState}
+}
+
+// update applies fn to the state under the lock and broadcasts what it
+// returns as an event of the given type.
+func (a *apiServer) update(kind string, fn func(*apiState) any) {
+	a.mu.Lock()
+	data := fn(&a.state)
+	a.state.UpdatedAt = time.Now()
+	a.mu.Unlock()
+	a.broadcast(kind, data)
+}
+
+// broadcast sends an event to every WebSocket client. A client too slow to
+// keep up misses events rather than holding up the others.
+func (a *apiServer) broadcast(kind string, data any) {
+	msg, err := json.Marshal(apiEvent{Type: kind, Time: time.Now(), Data: data})
+	if err != nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for c := range a.clients {
+		select {
+		case c <- msg:
+		default:
+		}
+	}
+}
+
+// setMap makes m (or the reason there is none) available at GET /map.
+func (a *apiServer) setMap(m *MowerMap, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.mowMap = m
+	a.mapErr = ""
+	if err != nil {
+		a.mapErr = err.Error()
+	}
+}
+
+// authorized accepts "Authorization: Bearer <token>", or an access_token
+// query parameter for browser WebSocket clients, which cannot set headers.
+func (a *apiServer) authorized(r *http.Request) bool {
+	got := r.URL.Query().Get("access_token")
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		got = strings.TrimPrefix(h, "Bearer ")
+	}
+	return got != "" && subtle.ConstantTimeCompare([]byte(got), []byte(a.token)) == 1
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// Handler returns the API's routes behind CORS and bearer-token checks.
+func (a *apiServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /devices", a.handleDevices)
+	mux.HandleFunc("GET /state", a.handleState)
+	mux.HandleFunc("GET /map", a.handleMap)
+	mux.HandleFunc("GET /events", a.handleEvents)
+	mux.HandleFunc("POST /recharge", a.commandHandler("recharge", false, func() *pb.MctlNav {
+		return &pb.MctlNav{SubNavMsg: &pb.MctlNav_TodevRechgcmd{TodevRechgcmd: 1}}
+	}))
+	mux.HandleFunc("POST /cancel", a.commandHandler("cancel", false, func() *pb.MctlNav {
+		return &pb.MctlNav{SubNavMsg: &pb.MctlNav_TodevCancelSuscmd{TodevCancelSuscmd: 1}}
+	}))
+	mux.HandleFunc("POST /pause", a.commandHandler("pause", false, func() *pb.MctlNav {
+		return &pb.MctlNav{SubNavMsg: &pb.MctlNav_TodevTaskctrl{TodevTaskctrl: &pb.NavTaskCtrl{Type: 1, Action: 1}}}
+	}))
+	mux.HandleFunc("POST /start", a.commandHandler("start", true, func() *pb.MctlNav {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		var knife int32
+		if a.state.Job != nil {
+			knife = a.state.Job.KnifeHeight
+		}
+		return startMowingNav(a.state.SysStatus == sysStatusPaused, a.state.BreakPoint != nil, knife)
+	}))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if !a.authorized(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "missing or wrong bearer token")
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func (a *apiServer) handleDevices(w http.ResponseWriter, r *http.Request) {
+	d := a.s.device
+	writeJSON(w, http.StatusOK, []map[string]any{{
+		"name":     d.DeviceName,
+		"iotId":    d.IotId,
+		"nickName": d.NickName,
+		"product":  d.ProductName,
+	}})
+}
+
+func (a *apiServer) handleState(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	st := a.state
+	a.mu.Unlock()
+	writeJSON(w, http.StatusOK, st)
+}
+
+func (a *apiServer) handleMap(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	m, mapErr := a.mowMap, a.mapErr
+	a.mu.Unlock()
+	if m == nil {
+		writeError(w, http.StatusServiceUnavailable, mapErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, m)
+}
+
+// commandHandler sends the nav message nav builds. Commands are refused in
+// view-only mode and, when they set the mower moving (needsBattery), below
+// the minimum battery, as in pilot.
+func (a *apiServer) commandHandler(label string, needsBattery bool, nav func() *pb.MctlNav) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if a.viewOnly {
+			writeError(w, http.StatusForbidden, "server is view-only")
+			return
+		}
+		if needsBattery {
+			a.mu.Lock()
+			battery := a.state.Battery
+			a.mu.Unlock()
+			if battery > 0 && battery < a.minBattery {
+				writeError(w, http.StatusConflict, fmt.Sprintf("battery %d%% is below the %d%% minimum", battery, a.minBattery))
+				return
+			}
+		}
+		if err := a.s.refresh(); err != nil {
+			writeError(w, http.StatusBadGateway, "refresh: "+err.Error())
+			return
+		}
+		if err := sendNav(a.s, nav()); err != nil {
+			writeError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		log.Printf("serve: %s sent", label)
+		writeJSON(w, http.StatusOK, map[string]string{"sent": label})
+	}
+}
+
+// handleEvents upgrades to a WebSocket, sends the current state, then streams
+// events until the client goes away.
+func (a *apiServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	conn, err := apiUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // the upgrader has already replied
+	}
+	defer conn.Close()
+
+	c := make(chan []byte, 64)
+	a.mu.Lock()
+	first, _ := json.Marshal(apiEvent{Type: "state", Time: time.Now(), Data: a.state})
+	a.clients[c] = struct{}{}
+	a.mu.Unlock()
+	defer func() {
+		a.mu.Lock()
+		delete(a.clients, c)
+		a.mu.Unlock()
+	}()
+
+	// Drain client messages so close frames are seen.
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	if err := conn.WriteMessage(websocket.TextMessage, first); err != nil {
+		return
+	}
+	for {
+		select {
+		case msg := <-c:
+			if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				return
+			}
+		case <-gone:
+			return
+		}
+	}
+}
+
+// newAPIToken returns a random token for when none is configured.
+func newAPIToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+var (
+	serveListen     string
+	serveToken      string
+	serveMapFile    string
+	serveViewOnly   bool
+	serveMinBattery int
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve a local REST and WebSocket API for the mower",
+	Long: `Keeps a session open and serves the mower over HTTP for dashboards:
+
+  GET  /devices   the connected device
+  GET  /state     battery, status, position, job and breakpoint
+  GET  /map       the map (from --map, or fetched from the mower at start)
+  GET  /events    WebSocket stream of position, status, work and zigzag events
+  POST /start     start mowing (resumes a paused or interrupted job)
+  POST /pause, /cancel, /recharge
+
+Every request needs "Authorization: Bearer <token>" (or ?access_token= for
+WebSockets). The token comes from --token or MAMMO_API_TOKEN; without one a
+random token is generated and printed. As in pilot, --view-only refuses all
+commands and /start is refused below --min-battery. Runs until Ctrl-C.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		token := serveToken
+		if token == "" {
+			token = os.Getenv("MAMMO_API_TOKEN")
+		}
+		if token == "" {
+			var err error
+			if token, err = newAPIToken(); err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			fmt.Println("API token:", token)
+		}
+
+		withSession(func(s *cloudSession) error {
+			a := newAPIServer(s, token, serveViewOnly, serveMinBattery)
+			stopPolling := startPolling(s)
+			defer stopPolling()
+
+			go func() {
+				if serveMapFile != "" {
+					a.setMap(LoadMap(serveMapFile))
+					return
+				}
+				a.setMap(FetchMap(s, nil))
+			}()
+
+			srv := &http.Server{Addr: serveListen, Handler: a.Handler()}
+			errc := make(chan error, 1)
+			go func() { errc <- srv.ListenAndServe() }()
+			defer srv.Close()
+			fmt.Printf("Serving %s on http://%s. Ctrl-C to stop.\n", s.device.DeviceName, serveListen)
+
+			sig := make(chan os.Signal, 1)
+			signal.Notify(sig, os.Interrupt)
+			select {
+			case err := <-errc:
+				return fmt.Errorf("api server: %w", err)
+			case <-sig:
+				return nil
+			}
+		})
+	},
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveListen, "listen", "127.0.0.1:8080", "address to serve the API on")
+	serveCmd.Flags().StringVar(&serveToken, "token", "", "bearer token clients must send (default $MAMMO_API_TOKEN, else random)")
+	serveCmd.Flags().StringVar(&serveMapFile, "map", "", "serve a saved map file instead of fetching from the mower")
+	serveCmd.Flags().BoolVar(&serveViewOnly, "view-only", false, "refuse every command")
+	serveCmd.Flags().IntVar(&serveMinBattery, "min-battery", 15, "refuse /start below this battery percentage")
+	rootCmd.AddCommand(serveCmd)
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestServeAPI(t *testing.T) {
+	s, sim := newSimSession(simLawn())
+	a := newAPIServer(s, "secret", false, 20)
+	a.setMap(simLawn(), nil)
+	srv := httptest.NewServer(a.Handler())
+	defer srv.Close()
+
+	call := func(method, path, token string) (int, map[string]any) {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var body map[string]any
+		json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body
+	}
+
+	if code, _ := call("GET", "/state", ""); code != http.StatusUnauthorized {
+		t.Errorf("no token: %d", code)
+	}
+	if code, _ := call("GET", "/state", "wrong"); code != http.StatusUnauthorized {
+		t.Errorf("wrong token: %d", code)
+	}
+	if code, _ := call("GET", "/recharge", "secret"); code != http.StatusMethodNotAllowed {
+		t.Errorf("GET on a command: %d", code)
+	}
+
+	ws, _, err := websocket.DefaultDialer.Dial(strings.Replace(srv.URL, "http", "ws", 1)+"/events?access_token=secret", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	nextEvent := func(kind string) apiEvent {
+		t.Helper()
+		for {
+			var ev apiEvent
+			if err := ws.ReadJSON(&ev); err != nil {
+				t.Fatalf("waiting for %s event: %v", kind, err)
+			}
+			if ev.Type == kind {
+				return ev
+			}
+		}
+	}
+	nextEvent("state")
+
+	sim.SetPose(3.25, -1.5, 90)
+	if err := primeSession(s); err != nil {
+		t.Fatal(err)
+	}
+	if pos := nextEvent("position").Data.(map[string]any); pos["x"] != 3.25 || pos["fix"] != "RTK-Fix" {
+		t.Errorf("position event = %v", pos)
+	}
+	code, st := call("GET", "/state", "secret")
+	if code != http.StatusOK || st["battery"] != 100.0 || st["sysStatus"] != float64(sysStatusCharging) {
+		t.Errorf("GET /state = %d %v", code, st)
+	}
+	if code, m := call("GET", "/map", "secret"); code != http.StatusOK || len(m["elements"].([]any)) != len(simLawn().Elements) {
+		t.Errorf("GET /map = %d", code)
+	}
+
+	if code, body := call("POST", "/start", "secret"); code != http.StatusOK {
+		t.Fatalf("POST /start = %d %v", code, body)
+	}
+	if sim.Status() != sysStatusWorking {
+		t.Errorf("sys_status after /start = %d", sim.Status())
+	}
+	nextEvent("zigzag")
+	call("POST", "/pause", "secret")
+	for {
+		if ev := nextEvent("status").Data.(map[string]any); ev["sysStatus"] == float64(sysStatusPaused) {
+			break
+		}
+	}
+
+	// Below the minimum battery /start is refused but recharge still goes.
+	sim.SetBattery(10)
+	if err := primeSession(s); err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := call("POST", "/start", "secret"); code != http.StatusConflict {
+		t.Errorf("/start on low battery = %d", code)
+	}
+	if code, _ := call("POST", "/recharge", "secret"); code != http.StatusOK || sim.Status() != sysStatusReturning {
+		t.Errorf("/recharge on low battery = %d, sys_status %d", code, sim.Status())
+	}
+
+	viewOnly := httptest.NewServer(newAPIServer(s, "secret", true, 20).Handler())
+	defer viewOnly.Close()
+	req, _ := http.NewRequest("POST", viewOnly.URL+"/pause", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("view-only /pause: %v %v", resp.StatusCode, err)
+	}
+}
@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// pilotSession is what pilot keeps of a session when it exits: enough to
+// pick up where it left off with --resume-session, or to export the trail.
+type pilotSession struct {
+	FormatVersion int       `json:"formatVersion"`
+	Device        string    `json:"device"`
+	Started       time.Time `json:"started"`
+	Saved         time.Time `json:"saved"`
+	// Map is the map file the session was flown over, relative to the
+	// session file unless absolute; "" when there was no map.
+	Map     string         `json:"map,omitempty"`
+	Trail   []TrailPoint   `json:"trail"`
+	Planned *sessionPlan   `json:"planned,omitempty"`
+	Events  []sessionEvent `json:"events,omitempty"`
+}
+
+// sessionPlan is the zigzag route the device had streamed: its job, the zone
+// it covers and the frames received, so a resumed session doesn't add them
+// twice.
+type sessionPlan struct {
+	JobID  uint64     `json:"jobId"`
+	Zone   int64      `json:"zone"`
+	Frames []int64    `json:"frames"`
+	Path   []MapPoint `json:"path"`
+}
+
+type sessionEvent struct {
+	T    time.Time `json:"t"`
+	Text string    `json:"text"`
+	Warn bool      `json:"warn,omitempty"`
+}
+
+const sessionFormatVersion = 1
+
+// defaultSessionDir is where pilot saves sessions unless --session-dir says
+// otherwise.
+func defaultSessionDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "sessions"
+	}
+	return filepath.Join(home, ".mammo", "sessions")
+}
+
+// sessionBase is the path, without extension, of device's session started at
+// start: <dir>/<device>-<start time>.
+func sessionBase(dir, device string, start time.Time) string {
+	return filepath.Join(dir, sanitizeFileName(device)+"-"+start.Format("20060102-150405"))
+}
+
+// sessionState captures the mower's part of the pilot session. The map
+// reference is left to the caller.
+func (m pilotModel) sessionState(started time.Time) *pilotSession {
+	s := &pilotSession{
+		FormatVersion: sessionFormatVersion,
+		Device:        m.mowerName(),
+		Started:       started,
+		Saved:         time.Now(),
+		Trail:         m.track,
+	}
+	if len(m.plannedPath) > 0 {
+		plan := &sessionPlan{JobID: m.zzJobID, Zone: m.plannedZone, Path: m.plannedPath}
+		for key := range m.zzSeen {
+			plan.Frames = append(plan.Frames, key)
+		}
+		sort.Slice(plan.Frames, func(i, j int) bool { return plan.Frames[i] < plan.Frames[j] })
+		s.Planned = plan
+	}
+	for _, ev := range m.dash.events {
+		s.Events = append(s.Events, sessionEvent{T: ev.t, Text: ev.text, Warn: ev.warn})
+	}
+	return s
+}
+
+// saveSession writes the mower's session to <base>.session.json. A map
+// fetched from the mower is saved beside it as <base>.map.json; a map loaded
+// from mapFile is only referred to.
+func (m pilotModel) saveSession(base, mapFile string, started time.Time) (string, error) {
+	s := m.sessionState(started)
+	if err := os.MkdirAll(filepath.Dir(base), 0755); err != nil {
+		return "", err
+	}
+	switch {
+	case mapFile != "":
+		abs, err := filepath.Abs(mapFile)
+		if err != nil {
+			return "", err
+		}
+		s.Map = abs
+	case m.mowerMap != nil:
+		if err := SaveMap(m.mowerMap, base+".map.json"); err != nil {
+			return "", fmt.Errorf("map: %w", err)
+		}
+		s.Map = filepath.Base(base) + ".map.json"
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := base + ".session.json"
+	return path, os.WriteFile(path, data, 0644)
+}
+
+// LoadSession reads a session file.
+func LoadSession(path string) (*pilotSession, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s pilotSession
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if s.FormatVersion > sessionFormatVersion {
+		return nil, fmt.Errorf("%s: session format %d is newer than this mammo understands (%d)", path, s.FormatVersion, sessionFormatVersion)
+	}
+	return &s, nil
+}
+
+// MapPath resolves the session's map reference against the session file at
+// path; "" when it has none.
+func (s *pilotSession) MapPath(path string) string {
+	if s.Map == "" || filepath.IsAbs(s.Map) {
+		return s.Map
+	}
+	return filepath.Join(filepath.Dir(path), s.Map)
+}
+
+// latestSession is the path of device's most recent session in dir.
+func latestSession(dir, device string) (string, error) {
+	prefix := sanitizeFileName(device) + "-"
+	paths, err := filepath.Glob(filepath.Join(dir, prefix+"*.session.json"))
+	if err != nil {
+		return "", err
+	}
+	var mine []string
+	for _, p := range paths {
+		// Only <device>-YYYYMMDD-HHMMSS: a device whose name extends this
+		// one's is not a match.
+		stamp := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(p), prefix), ".session.json")
+		if _, err := time.Parse("20060102-150405", stamp); err == nil {
+			mine = append(mine, p)
+		}
+	}
+	if len(mine) == 0 {
+		return "", fmt.Errorf("no saved session for %s in %s", device, dir)
+	}
+	sort.Strings(mine)
+	return mine[len(mine)-1], nil
+}
+
+// resumeSession puts a saved session's trail, route and events back. Live
+// positions carry on from the end of the trail.
+func (m *pilotModel) resumeSession(s *pilotSession) {
+	m.track = append([]TrailPoint(nil), s.Trail...)
+	drawn := s.Trail[max(0, len(s.Trail)-pilotTrailLen):]
+	m.trail = make([]MapPoint, 0, len(drawn))
+	for _, p := range drawn {
+		m.trail = append(m.trail, MapPoint{X: p.X, Y: p.Y})
+	}
+	if p := s.Planned; p != nil {
+		m.zzJobID, m.plannedZone = p.JobID, p.Zone
+		m.plannedPath = append([]MapPoint(nil), p.Path...)
+		m.zzSeen = map[int64]bool{}
+		for _, key := range p.Frames {
+			m.zzSeen[key] = true
+		}
+	}
+	for _, ev := range s.Events {
+		m.dash.Log(ev.T, ev.Text, ev.Warn)
+	}
+	m.dash.Log(time.Now(), "resumed the session saved "+s.Saved.Format("Jan 2 15:04"), false)
+	m.status = fmt.Sprintf("resumed the session saved %s (%d trail points, %d events)",
+		s.Saved.Format("Jan 2 15:04"), len(s.Trail), len(s.Events))
+}
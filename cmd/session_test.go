@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestPilotSessionSaveAndResume(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := newSimSession(simLawn())
+	mc := &motionController{session: s, notify: func(string) {}}
+	var model tea.Model = pilotModel{session: s, motion: mc, speed: 400, turnRate: 450, minBattery: 15, zoom: 1}
+	start := time.Date(2026, 10, 18, 9, 30, 0, 0, time.Local)
+	for i, msg := range []tea.Msg{
+		pilotMapMsg(simLawn()),
+		pilotPosMsg{x: 10, y: 5, heading: 90, posType: 4, t: start},
+		pilotPosMsg{x: 10.01, y: 5, heading: 90, posType: 4, t: start.Add(time.Second)}, // inside the jitter gate
+		pilotPosMsg{x: 11, y: 5, heading: 90, posType: 5, t: start.Add(2 * time.Second)},
+		pilotZigZagMsg{jobID: 7, zone: 1, hash: 101, frame: 1, points: []MapPoint{{X: 4, Y: 4}, {X: 16, Y: 4}}},
+		pilotEventMsg(notifyEvent{Kind: eventStuck, Device: "Luba-SIM", Message: "Luba-SIM is stuck", Time: start}),
+	} {
+		if model, _ = model.Update(msg); model.(pilotModel).err != nil {
+			t.Fatalf("msg %d: %v", i, model.(pilotModel).err)
+		}
+	}
+	path, err := model.(pilotModel).saveSession(sessionBase(dir, "Luba-SIM", start), "", start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "Luba-SIM-20261018-093000.session.json"); path != want {
+		t.Errorf("saved to %s, want %s", path, want)
+	}
+
+	// A newer session of a device whose name extends this one's is not
+	// this one's latest.
+	os.WriteFile(filepath.Join(dir, "Luba-SIMX-20261019-000000.session.json"), []byte("{}"), 0644)
+	latest, err := latestSession(dir, "Luba-SIM")
+	if err != nil || latest != path {
+		t.Fatalf("latest = %s, %v", latest, err)
+	}
+	saved, err := LoadSession(latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.Trail) != 2 || saved.Trail[1].PosType != 5 || !saved.Trail[1].T.Equal(start.Add(2*time.Second)) {
+		t.Errorf("trail %+v", saved.Trail)
+	}
+	if m, err := LoadMap(saved.MapPath(latest)); err != nil || len(m.Elements) != len(simLawn().Elements) {
+		t.Errorf("saved map: %v", err)
+	}
+
+	s2, _ := newSimSession(simLawn())
+	resumed := pilotModel{session: s2, motion: &motionController{session: s2, notify: func(string) {}}, zoom: 1}
+	resumed.resumeSession(saved)
+	if len(resumed.trail) != 2 || resumed.plannedZone != 101 || len(resumed.plannedPath) != 2 || !strings.Contains(resumed.status, "2 trail points, 1 events") {
+		t.Fatalf("resumed: trail %d, zone %d, status %q", len(resumed.trail), resumed.plannedZone, resumed.status)
+	}
+	if ev := resumed.dash.events[0]; ev.text != "is stuck" || !ev.warn {
+		t.Errorf("resumed event %+v", ev)
+	}
+	// A frame already seen is not added twice, and live positions carry on.
+	model, _ = resumed.Update(pilotZigZagMsg{jobID: 7, zone: 1, hash: 101, frame: 1, points: []MapPoint{{X: 4, Y: 4}, {X: 16, Y: 4}}})
+	model, _ = model.Update(pilotPosMsg{x: 12, y: 5, heading: 90, posType: 4})
+	if m := model.(pilotModel); len(m.plannedPath) != 2 || len(m.track) != 3 {
+		t.Errorf("after resuming: plan %d points, track %d", len(m.plannedPath), len(m.track))
+	}
+
+	// A long session keeps every point, though only the latest are drawn.
+	for i := 0; i < pilotTrailLen+10; i++ {
+		model, _ = model.Update(pilotPosMsg{x: 12.1 + float64(i)*0.1, y: 5, heading: 90, posType: 4})
+	}
+	if m := model.(pilotModel); len(m.trail) != pilotTrailLen || len(m.track) != pilotTrailLen+13 ||
+		len(m.sessionState(start).Trail) != len(m.track) {
+		t.Errorf("long session: drawn %d, track %d", len(m.trail), len(m.track))
+	}
+
+	// Exports.
+	var csvOut bytes.Buffer
+	if err := writeTrailCSV(&csvOut, saved.Trail, &geoOrigin{Lat: 51.5, Lon: -0.14}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(csvOut.String()), "\n")
+	if len(lines) != 3 || lines[0] != "time,x,y,heading,posType,fix,lat,lon" || !strings.Contains(lines[2], ",11.000,5.000,90.0,5,RTK-Float,51.50004492,-0.13984") {
+		t.Errorf("csv:\n%s", csvOut.String())
+	}
+	var gpx bytes.Buffer
+	if err := writeTrailGPX(&gpx, "Luba-SIM", saved.Trail, &geoOrigin{Lat: 51.5, Lon: -0.14}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`<gpx version="1.1"`, `<trkpt lat="51.50004492" lon="-0.13984`, `<mammo:posType>5</mammo:posType>`, `<mammo:heading>90</mammo:heading>`, `<time>2026-10-18T`} {
+		if !strings.Contains(gpx.String(), want) {
+			t.Errorf("gpx is missing %s:\n%s", want, gpx.String())
+		}
+	}
+	if _, err := parseGeoOrigin("91,0"); err == nil {
+		t.Error("latitude 91 accepted")
+	}
+}
@@ -0,0 +1,649 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"mammo/aliyuniot"
+	"mammo/mammotion"
+	pb "mammo/proto"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// simMotionTimeout is how long the simulated mower keeps driving without
+	// a fresh motion command, mirroring the real mower's dead-man stop.
+	simMotionTimeout = time.Second
+	// simSvgChunk is the SVG file data carried per toapp_svg_msg frame.
+	simSvgChunk = 512
+	// simRtkStars is the satellite count the simulated receiver reports.
+	simRtkStars = 30
+)
+
+// SimMower is an in-process stand-in for a mower. It consumes the app→device
+// LubaMsg commands this client sends (report config, motion, map hash list,
+// commondata and SVG paging, zone names, zigzag paging, task control) and
+// answers with device→app LubaMsg bytes generated from a MowerMap, so the
+// client stack can run deterministically without the cloud.
+//
+// Replies are passed to emit synchronously, after the simulator's lock is
+// released, so a callback may send the next command from inside emit.
+type SimMower struct {
+	mu   sync.Mutex
+	m    *MowerMap
+	emit func([]byte)
+
+	// FramePoints is the number of vertices per commondata / zigzag frame.
+	FramePoints int
+	// LaneWidth is the spacing of the generated zigzag coverage lanes.
+	LaneWidth float64
+
+	x, y        float64 // meters
+	heading     float64 // compass degrees
+	posType     int32
+	battery     int32
+	sysStatus   int32
+	chargeState int32
+	knifeHeight int32 // mm
+
+	linear, angular int32
+	sinceMotion     time.Duration
+
+	job        *simJob
+	planned    int64 // zone of the last route request, started by the next todev_mow_task
+	breakPoint *pb.NavTaskBreakPoint
+
+	received []*pb.LubaMsg
+}
+
+type simJob struct {
+	id     uint64
+	zone   int64
+	frames [][]MapPoint
+}
+
+// NewSimMower creates a simulated mower parked on the map's dock, charged and
+// with an RTK fix. emit receives every device→app message.
+func NewSimMower(m *MowerMap, emit func([]byte)) *SimMower {
+	s := &SimMower{
+		m:           m,
+		emit:        emit,
+		FramePoints: 20,
+		LaneWidth:   0.5,
+		posType:     4,
+		battery:     100,
+		sysStatus:   sysStatusCharging,
+		chargeState: 1,
+		knifeHeight: 60,
+	}
+	if m != nil {
+		dock, _ := m.DockEstimate()
+		s.x, s.y = dock.X, dock.Y
+	}
+	return s
+}
+
+// SetPose places the mower (meters, compass degrees).
+func (s *SimMower) SetPose(x, y, heading float64) {
+	s.mu.Lock()
+	s.x, s.y, s.heading = x, y, heading
+	s.mu.Unlock()
+}
+
+// Pose returns the mower's position and heading.
+func (s *SimMower) Pose() (x, y, heading float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.x, s.y, s.heading
+}
+
+// Motion returns the last commanded linear and angular speed still in effect.
+func (s *SimMower) Motion() (linear, angular int32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.linear, s.angular
+}
+
+// SetBattery sets the reported battery percentage.
+func (s *SimMower) SetBattery(pct int32) {
+	s.mu.Lock()
+	s.battery = pct
+	s.mu.Unlock()
+}
+
+// Status returns the reported sys_status.
+func (s *SimMower) Status() int32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sysStatus
+}
+
+// Received returns every command the simulator has decoded, in order.
+func (s *SimMower) Received() []*pb.LubaMsg {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*pb.LubaMsg(nil), s.received...)
+}
+
+// Handle consumes one app→device LubaMsg.
+func (s *SimMower) Handle(data []byte) error {
+	var msg pb.LubaMsg
+	if err := proto.Unmarshal(data, &msg); err != nil {
+		return fmt.Errorf("sim: decode command: %w", err)
+	}
+	s.mu.Lock()
+	s.received = append(s.received, &msg)
+	out := s.handleLocked(&msg)
+	s.mu.Unlock()
+	for _, reply := range out {
+		s.emit(reply)
+	}
+	return nil
+}
+
+func (s *SimMower) handleLocked(msg *pb.LubaMsg) [][]byte {
+	switch {
+	case msg.GetNet().GetNetSubType() != nil:
+		if _, ok := msg.GetNet().GetNetSubType().(*pb.DevNet_TodevBleSync); ok {
+			return s.replies(s.reportLocked())
+		}
+	case msg.GetSys().GetTodevReportCfg() != nil:
+		return s.replies(s.reportLocked())
+	case msg.GetDriver().GetTodevDevmotionCtrl() != nil:
+		mc := msg.GetDriver().GetTodevDevmotionCtrl()
+		s.linear, s.angular = mc.GetSetLinearSpeed(), mc.GetSetAngularSpeed()
+		s.sinceMotion = 0
+		if s.linear != 0 || s.angular != 0 {
+			s.chargeState = 0
+		}
+	case msg.GetNav() != nil:
+		return s.handleNavLocked(msg.GetNav())
+	}
+	return nil
+}
+
+func (s *SimMower) handleNavLocked(nav *pb.MctlNav) [][]byte {
+	switch sub := nav.GetSubNavMsg().(type) {
+	case *pb.MctlNav_TodevGethash:
+		var hashes []int64
+		if s.m != nil {
+			for _, el := range s.m.Elements {
+				hashes = append(hashes, el.Hash)
+			}
+		}
+		out := s.replies(simNav(&pb.MctlNav{SubNavMsg: &pb.MctlNav_ToappGethashAck{ToappGethashAck: &pb.NavGetHashListAck{
+			Pver:         1,
+			SubCmd:       sub.TodevGethash.GetSubCmd(),
+			TotalFrame:   1,
+			CurrentFrame: 1,
+			HashLen:      int32(len(hashes)),
+			DataCouple:   hashes,
+		}}}))
+		if s.m != nil && s.m.Dock != nil {
+			out = append(out, s.replies(simNav(&pb.MctlNav{SubNavMsg: &pb.MctlNav_ToappChgpileto{ToappChgpileto: &pb.ChargePileType{
+				Toward: s.m.Dock.Toward,
+				X:      float32(s.m.Dock.X),
+				Y:      float32(s.m.Dock.Y),
+			}}}))...)
+		}
+		return out
+
+	case *pb.MctlNav_TodevGetCommondata:
+		req := sub.TodevGetCommondata
+		frame := int32(1)
+		if req.GetSubCmd() == 2 {
+			frame = req.GetCurrentFrame() + 1 // ack for the previous frame
+		}
+		if req.GetType() == MapTypeSvg && req.GetSubCmd() == 1 {
+			return s.replies(s.svgFrameLocked(req.GetHash(), 1))
+		}
+		return s.replies(s.elementFrameLocked(req.GetHash(), frame))
+
+	case *pb.MctlNav_TodevSvgMsg:
+		ack := sub.TodevSvgMsg
+		return s.replies(s.svgFrameLocked(int64(ack.GetDataHash()), ack.GetCurrentFrame()+1))
+
+	case *pb.MctlNav_ToappAllHashName:
+		names := &pb.AppGetAllAreaHashName{DeviceId: sub.ToappAllHashName.GetDeviceId()}
+		if s.m != nil {
+			hashes := make([]int64, 0, len(s.m.AreaNames))
+			for h := range s.m.AreaNames {
+				hashes = append(hashes, h)
+			}
+			sort.Slice(hashes, func(i, j int) bool { return hashes[i] < hashes[j] })
+			for _, h := range hashes {
+				names.Hashnames = append(names.Hashnames, &pb.AreaHashName{Hash: h, Name: s.m.AreaNames[h]})
+			}
+		}
+		return s.replies(simNav(&pb.MctlNav{SubNavMsg: &pb.MctlNav_ToappAllHashName{ToappAllHashName: names}}))
+
+	case *pb.MctlNav_TodevZigzagAck:
+		ack := sub.TodevZigzagAck
+		return s.replies(s.zigzagFrameLocked(ack.GetCurrentFrame() + 1))
+
+	case *pb.MctlNav_TodevTaskctrl:
+		ctrl := sub.TodevTaskctrl
+		if ctrl.GetType() != 1 {
+			return nil
+		}
+		switch ctrl.GetAction() {
+		case 0: // resume
+			if s.sysStatus == sysStatusPaused {
+				s.sysStatus = sysStatusWorking
+			}
+		case 1: // pause
+			if s.sysStatus == sysStatusWorking {
+				s.sysStatus = sysStatusPaused
+				s.linear, s.angular = 0, 0
+			}
+		case taskCtrlBreakPointContinue:
+			if s.breakPoint != nil {
+				s.x, s.y = float64(s.breakPoint.GetX()), float64(s.breakPoint.GetY())
+				s.breakPoint = nil
+				s.sysStatus = sysStatusWorking
+			}
+		}
+		return s.replies(s.reportLocked())
+
+	case *pb.MctlNav_TodevRechgcmd:
+		s.sysStatus = sysStatusReturning
+		return s.replies(s.reportLocked())
+
+	case *pb.MctlNav_TodevMowTask:
+		if h := sub.TodevMowTask.GetKnifeHeight(); h > 0 {
+			s.knifeHeight = h
+		}
+		out, _ := s.startJobLocked(s.firstAreaLocked())
+		return out
+
+	case *pb.MctlNav_BidireReqconverPath:
+		// Plan the zone's route and send it without starting.
+		// Like the device, refuse a request missing its planning settings.
+		req := sub.BidireReqconverPath
+		if len(req.GetZoneHashs()) == 0 || req.GetKnifeHeight() <= 0 || req.GetChannelWidth() <= 0 || req.GetSpeed() <= 0 {
+			return nil
+		}
+		zone := int64(req.GetZoneHashs()[0])
+		job, err := s.planJobLocked(zone, false)
+		if err != nil {
+			return nil
+		}
+		s.job, s.planned = job, zone
+		return s.replies(s.zigzagFrameLocked(1))
+
+	case *pb.MctlNav_TodevEdgecmd:
+		job, err := s.planJobLocked(s.firstAreaLocked(), true)
+		if err != nil {
+			return nil
+		}
+		s.job, s.planned, s.breakPoint = job, 0, nil
+		s.sysStatus, s.chargeState = sysStatusWorking, 0
+		return s.replies(s.zigzagFrameLocked(1), s.reportLocked())
+	}
+	return nil
+}
+
+// replies marshals device→app messages, dropping any that fail to encode.
+func (s *SimMower) replies(msgs ...*pb.LubaMsg) [][]byte {
+	var out [][]byte
+	for _, msg := range msgs {
+		if msg == nil {
+			continue
+		}
+		if data, err := proto.Marshal(msg); err == nil {
+			out = append(out, data)
+		}
+	}
+	return out
+}
+
+// simEnvelope wraps a device→app payload in the main controller's envelope.
+func simEnvelope(msgType pb.MsgCmdType, attr pb.MsgAttr) *pb.LubaMsg {
+	return &pb.LubaMsg{
+		Msgtype:   msgType,
+		Sender:    pb.MsgDevice_DEV_MAINCTL,
+		Rcver:     pb.MsgDevice_DEV_MOBILEAPP,
+		Msgattr:   attr,
+		Seqs:      1,
+		Version:   1,
+		Subtype:   1,
+		Timestamp: uint64(time.Now().UnixMilli()),
+	}
+}
+
+func simNav(nav *pb.MctlNav) *pb.LubaMsg {
+	msg := simEnvelope(pb.MsgCmdType_MSG_CMD_TYPE_NAV, pb.MsgAttr_MSG_ATTR_RESP)
+	msg.LubaSubMsg = &pb.LubaMsg_Nav{Nav: nav}
+	return msg
+}
+
+func (s *SimMower) element(hash int64) *MapElement {
+	if s.m == nil {
+		return nil
+	}
+	for i := range s.m.Elements {
+		if s.m.Elements[i].Hash == hash {
+			return &s.m.Elements[i]
+		}
+	}
+	return nil
+}
+
+// frameCount splits n items into frames of per items (at least one frame).
+func frameCount(n, per int) int32 {
+	if per <= 0 || n <= per {
+		return 1
+	}
+	return int32((n + per - 1) / per)
+}
+
+func (s *SimMower) elementFrameLocked(hash int64, frame int32) *pb.LubaMsg {
+	el := s.element(hash)
+	if el == nil {
+		return nil
+	}
+	total := frameCount(len(el.Points), s.FramePoints)
+	if frame < 1 || frame > total {
+		return nil
+	}
+	pts := el.Points
+	if total > 1 {
+		lo := int(frame-1) * s.FramePoints
+		pts = pts[lo:min(lo+s.FramePoints, len(pts))]
+	}
+	couples := make([]*pb.CommDataCouple, len(pts))
+	for i, p := range pts {
+		couples[i] = &pb.CommDataCouple{X: float32(p.X), Y: float32(p.Y)}
+	}
+	ack := &pb.NavGetCommDataAck{
+		Pver:         1,
+		SubCmd:       1,
+		Action:       8,
+		Type:         el.Type,
+		Hash:         uint64(el.Hash),
+		TotalFrame:   total,
+		CurrentFrame: frame,
+		DataLen:      int32(len(couples)),
+		DataCouple:   couples,
+	}
+	if el.Label != "" {
+		ack.AreaLabel = &pb.AreaLabel{Label: el.Label}
+	}
+	return simNav(&pb.MctlNav{SubNavMsg: &pb.MctlNav_ToappGetCommondataAck{ToappGetCommondataAck: ack}})
+}
+
+func (s *SimMower) svgFrameLocked(hash int64, frame int32) *pb.LubaMsg {
+	if s.m == nil {
+		return nil
+	}
+	var svg *MapSvg
+	for i := range s.m.Svgs {
+		if s.m.Svgs[i].Hash == hash {
+			svg = &s.m.Svgs[i]
+		}
+	}
+	if svg == nil {
+		return nil
+	}
+	total := frameCount(len(svg.Data), simSvgChunk)
+	if frame < 1 || frame > total {
+		return nil
+	}
+	lo := int(frame-1) * simSvgChunk
+	chunk := svg.Data[lo:min(lo+simSvgChunk, len(svg.Data))]
+	return simNav(&pb.MctlNav{SubNavMsg: &pb.MctlNav_ToappSvgMsg{ToappSvgMsg: &pb.SvgMessageAckT{
+		Pver:         1,
+		SubCmd:       1,
+		TotalFrame:   total,
+		CurrentFrame: frame,
+		DataHash:     uint64(hash),
+		Type:         MapTypeSvg,
+		SvgMessage: &pb.SvgMessageT{
+			XMove:         svg.XMove,
+			YMove:         svg.YMove,
+			Scale:         svg.Scale,
+			Rotate:        svg.Rotate,
+			BaseWidthM:    svg.BaseWidthM,
+			BaseHeightM:   svg.BaseHeightM,
+			BaseWidthPix:  svg.BaseWidthPix,
+			BaseHeightPix: svg.BaseHeightPix,
+			HideSvg:       svg.Hidden,
+			SvgFileName:   svg.FileName,
+			SvgFileData:   chunk,
+		},
+	}}})
+}
+
+func (s *SimMower) zigzagFrameLocked(frame int32) *pb.LubaMsg {
+	if s.job == nil || frame < 1 || int(frame) > len(s.job.frames) {
+		return nil
+	}
+	pts := s.job.frames[frame-1]
+	couples := make([]*pb.CommDataCouple, len(pts))
+	for i, p := range pts {
+		couples[i] = &pb.CommDataCouple{X: float32(p.X), Y: float32(p.Y)}
+	}
+	return simNav(&pb.MctlNav{SubNavMsg: &pb.MctlNav_ToappZigzag{ToappZigzag: &pb.NavUploadZigZagResult{
+		Pver:         1,
+		JobId:        s.job.id,
+		TotalZoneNum: 1,
+		CurrentZone:  1,
+		CurrentHash:  uint64(s.job.zone),
+		TotalFrame:   int32(len(s.job.frames)),
+		CurrentFrame: frame,
+		DataLen:      int32(len(couples)),
+		DataCouple:   couples,
+		SubCmd:       1,
+	}}})
+}
+
+// StartJob begins mowing the area with the given hash: the mower leaves the
+// dock and pushes the first frame of the zone's coverage path. Later frames
+// follow as the client acks them, as on the real device.
+func (s *SimMower) StartJob(zone int64) error {
+	s.mu.Lock()
+	out, err := s.startJobLocked(zone)
+	s.mu.Unlock()
+	for _, reply := range out {
+		s.emit(reply)
+	}
+	return err
+}
+
+func (s *SimMower) startJobLocked(zone int64) ([][]byte, error) {
+	job, err := s.planJobLocked(zone, false)
+	if err != nil {
+		return nil, err
+	}
+	s.job, s.planned = job, 0
+	s.breakPoint = nil
+	s.sysStatus = sysStatusWorking
+	s.chargeState = 0
+	return s.replies(s.zigzagFrameLocked(1), s.reportLocked()), nil
+}
+
+// planJobLocked builds a job's route over an area, split into frames: the
+// zigzag coverage path, or for an edge job the boundary once round.
+func (s *SimMower) planJobLocked(zone int64, edge bool) (*simJob, error) {
+	el := s.element(zone)
+	if el == nil || el.Type != MapTypeArea {
+		return nil, fmt.Errorf("sim: no area with hash %d", zone)
+	}
+	path := zigzagPath(el.Points, s.LaneWidth)
+	if edge {
+		path = append(append([]MapPoint(nil), el.Points...), el.Points[0])
+	}
+	job := &simJob{id: uint64(time.Now().UnixNano()), zone: zone}
+	for lo := 0; lo < len(path); lo += s.FramePoints {
+		job.frames = append(job.frames, path[lo:min(lo+s.FramePoints, len(path))])
+	}
+	return job, nil
+}
+
+// firstAreaLocked is the zone a todev_mow_task or todev_edgecmd starts: the
+// interrupted job's zone if there is one, then the zone of the last route
+// request, else the map's first area.
+func (s *SimMower) firstAreaLocked() int64 {
+	if s.breakPoint != nil {
+		return int64(s.breakPoint.GetZoneHash())
+	}
+	if s.planned != 0 {
+		return s.planned
+	}
+	if s.m != nil {
+		for _, el := range s.m.Elements {
+			if el.Type == MapTypeArea {
+				return el.Hash
+			}
+		}
+	}
+	return 0
+}
+
+// Interrupt stops the running job where the mower stands, leaving a
+// breakpoint (toapp_bp and rpt_work) to resume from.
+func (s *SimMower) Interrupt() {
+	s.mu.Lock()
+	if s.job == nil {
+		s.mu.Unlock()
+		return
+	}
+	s.breakPoint = &pb.NavTaskBreakPoint{
+		X:        float32(s.x),
+		Y:        float32(s.y),
+		Toward:   int32(s.heading * 10000),
+		Flag:     1,
+		Action:   1,
+		ZoneHash: uint64(s.job.zone),
+	}
+	s.sysStatus = sysStatusReady
+	s.linear, s.angular = 0, 0
+	out := s.replies(simNav(&pb.MctlNav{SubNavMsg: &pb.MctlNav_ToappBp{ToappBp: s.breakPoint}}), s.reportLocked())
+	s.mu.Unlock()
+	for _, reply := range out {
+		s.emit(reply)
+	}
+}
+
+// Step advances the simulation by dt: integrates the commanded motion and
+// emits a report_info_data. Linear speed is mm/s (positive forward); angular
+// speed is mrad/s (positive turns right, i.e. clockwise on the compass).
+func (s *SimMower) Step(dt time.Duration) {
+	s.mu.Lock()
+	s.sinceMotion += dt
+	if s.sinceMotion > simMotionTimeout {
+		s.linear, s.angular = 0, 0
+	}
+	secs := dt.Seconds()
+	s.heading = math.Mod(s.heading+float64(s.angular)/1000*secs*180/math.Pi, 360)
+	if s.heading < 0 {
+		s.heading += 360
+	}
+	h := s.heading * math.Pi / 180
+	v := float64(s.linear) / 1000
+	s.x += v * math.Sin(h) * secs
+	s.y += v * math.Cos(h) * secs
+	out := s.replies(s.reportLocked())
+	s.mu.Unlock()
+	for _, reply := range out {
+		s.emit(reply)
+	}
+}
+
+// reportLocked builds the periodic report_info_data: device status, the
+// current position (0.1mm / 0.0001° units) and the work state.
+func (s *SimMower) reportLocked() *pb.LubaMsg {
+	work := &pb.RptWork{KnifeHeight: s.knifeHeight}
+	if s.job != nil {
+		work.PathHash = s.job.zone
+	}
+	if s.breakPoint != nil {
+		work.BpInfo = 1
+		work.BpHash = int64(s.breakPoint.GetZoneHash())
+		work.BpPosX = int32(s.breakPoint.GetX() * 10000)
+		work.BpPosY = int32(s.breakPoint.GetY() * 10000)
+	}
+	msg := simEnvelope(pb.MsgCmdType_MSG_CMD_TYPE_EMBED_SYS, pb.MsgAttr_MSG_ATTR_REPORT)
+	msg.LubaSubMsg = &pb.LubaMsg_Sys{Sys: &pb.MctlSys{SubSysMsg: &pb.MctlSys_ToappReportData{ToappReportData: &pb.ReportInfoData{
+		Dev: &pb.RptDevStatus{
+			SysStatus:   s.sysStatus,
+			ChargeState: s.chargeState,
+			BatteryVal:  s.battery,
+		},
+		Locations: []*pb.RptDevLocation{{
+			RealPosX:   int32(math.Round(s.x * 10000)),
+			RealPosY:   int32(math.Round(s.y * 10000)),
+			RealToward: int32(math.Round(s.heading * 10000)),
+			PosType:    s.posType,
+		}},
+		Rtk: &pb.RptRtk{
+			Status:      s.posType,
+			GpsStars:    simRtkStars,
+			L2Stars:     simRtkStars - 6,
+			CoViewStars: simRtkStars - 8,
+			Age:         1,
+			LatStd:      12,
+			LonStd:      15,
+		},
+		Maintain: &pb.RptMaintain{Mileage: 52000, WorkTime: 86400, BatCycles: 40},
+		Connect:  &pb.RptConnectStatus{ConnectType: 2, WifiRssi: -55},
+		Work:     work,
+	}}}}
+	return msg
+}
+
+// zigzagPath generates back-and-forth lanes across a polygon, lane apart,
+// alternating direction (a plausible stand-in for the device's planner).
+func zigzagPath(poly []MapPoint, lane float64) []MapPoint {
+	if len(poly) < 3 || lane <= 0 {
+		return nil
+	}
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	for _, p := range poly {
+		minY, maxY = math.Min(minY, p.Y), math.Max(maxY, p.Y)
+	}
+	var path []MapPoint
+	reverse := false
+	for y := minY + lane/2; y < maxY; y += lane {
+		var xs []float64
+		for i := range poly {
+			a, b := poly[i], poly[(i+1)%len(poly)]
+			if (a.Y > y) != (b.Y > y) {
+				xs = append(xs, a.X+(y-a.Y)*(b.X-a.X)/(b.Y-a.Y))
+			}
+		}
+		sort.Float64s(xs)
+		if reverse {
+			sort.Sort(sort.Reverse(sort.Float64Slice(xs)))
+		}
+		for i := 0; i+1 < len(xs); i += 2 {
+			path = append(path, MapPoint{X: xs[i], Y: y}, MapPoint{X: xs[i+1], Y: y})
+		}
+		reverse = !reverse
+	}
+	return path
+}
+
+// newSimSession returns a cloudSession wired to a simulated mower over a
+// loopback transport: commands go to the simulator and its replies are
+// decoded by a real StateManager, so everything above the transport
+// (FetchMap, the motion controller, the callbacks) runs unchanged.
+func newSimSession(m *MowerMap) (*cloudSession, *SimMower) {
+	device := &aliyuniot.Device{DeviceName: "Luba-SIM", IotId: "sim-iot-id"}
+	mowing := &mammotion.MowingDevice{}
+	sm := mammotion.NewStateManager(mowing)
+	var sim *SimMower
+	loop := mammotion.NewLoopbackTransport(func(data []byte) error { return sim.Handle(data) })
+	sim = NewSimMower(m, loop.Deliver)
+	sm.Attach(loop)
+	return &cloudSession{
+		transport:    loop,
+		device:       device,
+		mowingDevice: mowing,
+		stateManager: sm,
+	}, sim
+}
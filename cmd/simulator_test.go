@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"math"
+	"testing"
+
+	"mammo/mammotion"
+)
+
+// simLawn is a small map exercising every element kind FetchMap handles: a
+// zone large enough to need several commondata frames, an obstacle, a path,
+// a zone name, the dock and a multi-frame SVG overlay.
+func simLawn() *MowerMap {
+	var zone []MapPoint
+	for i := 0; i < 50; i++ {
+		a := 2 * math.Pi * float64(i) / 50
+		zone = append(zone, MapPoint{X: 10 + 8*math.Cos(a), Y: 10 + 8*math.Sin(a)})
+	}
+	svgData := `<svg><path d="M 0 0 L 10 0 L 10 10 Z"/></svg>`
+	for len(svgData) < 3*simSvgChunk {
+		svgData += `<!-- padding -->`
+	}
+	return &MowerMap{
+		FormatVersion: mapFormatVersion,
+		Elements: []MapElement{
+			{Hash: 101, Type: MapTypeArea, TypeName: "area", Label: "1", Points: zone},
+			{Hash: 102, Type: MapTypeObstacle, TypeName: "obstacle", Points: []MapPoint{{X: 9, Y: 9}, {X: 11, Y: 9}, {X: 11, Y: 11}}},
+			{Hash: 103, Type: MapTypePath, TypeName: "path", Points: []MapPoint{{X: 0, Y: 0}, {X: 2, Y: 10}}},
+			{Hash: 104, Type: MapTypeSvg, TypeName: "svg", Points: []MapPoint{{X: 5, Y: 5}}},
+		},
+		AreaNames: map[int64]string{101: "Front"},
+		Dock:      &DockPosition{X: 0.5, Y: -0.5, Toward: 90},
+		Svgs:      []MapSvg{{Hash: 104, FileName: "star.svg", Scale: 1, BaseWidthM: 1, BaseHeightM: 1, BaseWidthPix: 10, BaseHeightPix: 10, Data: svgData}},
+	}
+}
+
+func TestFetchMapFromSimulator(t *testing.T) {
+	want := simLawn()
+	s, _ := newSimSession(want)
+
+	got, err := FetchMap(s, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Elements) != len(want.Elements) {
+		t.Fatalf("fetched %d elements, want %d", len(got.Elements), len(want.Elements))
+	}
+	for i, el := range got.Elements {
+		w := want.Elements[i]
+		if el.Hash != w.Hash || el.Type != w.Type || el.Label != w.Label || len(el.Points) != len(w.Points) {
+			t.Fatalf("element %d: got hash %d type %d label %q with %d points, want %+v",
+				i, el.Hash, el.Type, el.Label, len(el.Points), w)
+		}
+		for j, p := range el.Points {
+			if math.Abs(p.X-w.Points[j].X) > 1e-5 || math.Abs(p.Y-w.Points[j].Y) > 1e-5 {
+				t.Fatalf("element %d point %d: got %v, want %v", i, j, p, w.Points[j])
+			}
+		}
+	}
+	if got.AreaNames[101] != "Front" {
+		t.Errorf("zone names not fetched: %v", got.AreaNames)
+	}
+	if got.Dock == nil || got.Dock.Toward != 90 || math.Abs(got.Dock.X-0.5) > 1e-6 {
+		t.Errorf("dock not fetched: %+v", got.Dock)
+	}
+	if len(got.Svgs) != 1 || got.Svgs[0].Data != want.Svgs[0].Data || got.Svgs[0].FileName != "star.svg" {
+		t.Errorf("svg not reassembled across frames: %+v", got.Svgs)
+	}
+}
+
+func TestSimulatorReportsPosition(t *testing.T) {
+	s, sim := newSimSession(simLawn())
+	var x, y float32
+	var angle, posType int32
+	s.stateManager.OnPositionUpdate = func(px, py float32, a int32, pt int32) {
+		x, y, angle, posType = px, py, a, pt
+	}
+	sim.SetPose(3.25, -1.5, 90)
+	if err := primeSession(s); err != nil {
+		t.Fatal(err)
+	}
+	if x != 32500 || y != -15000 || angle != 900000 || posType != 4 {
+		t.Errorf("report position = %.0f, %.0f angle %d type %d", x, y, angle, posType)
+	}
+	if s.mowingDevice.BatteryPercentage != 100 {
+		t.Errorf("battery = %d, want 100", s.mowingDevice.BatteryPercentage)
+	}
+}
+
+func TestSimulatorZigZagPaging(t *testing.T) {
+	m := simLawn()
+	s, sim := newSimSession(m)
+	frames := map[int32]int{}
+	var total int32
+	s.stateManager.OnZigZagReceived = func(zz *mammotion.ZigZagData) {
+		frames[zz.CurrentFrame] = len(zz.DataCouple) / 2
+		total = zz.TotalFrame
+		// Ack as pilot does so the next frame follows.
+		if zz.CurrentFrame < zz.TotalFrame {
+			if ack, err := buildZigZagAck(zz.CurrentZone, zz.CurrentHash, zz.TotalFrame, zz.CurrentFrame); err == nil {
+				s.send(ack)
+			}
+		}
+	}
+	if err := sim.StartJob(101); err != nil {
+		t.Fatal(err)
+	}
+	if total < 2 || int32(len(frames)) != total {
+		t.Fatalf("expected every zigzag frame after acking, got %d of %d", len(frames), total)
+	}
+	points := 0
+	for _, n := range frames {
+		points += n
+	}
+	if want := len(zigzagPath(m.Elements[0].Points, sim.LaneWidth)); points != want {
+		t.Errorf("received %d path points, want %d", points, want)
+	}
+}
+
+func TestSimulatorBreakPointResume(t *testing.T) {
+	s, sim := newSimSession(simLawn())
+	snap := watchSnapshot(s)
+	if err := sim.StartJob(101); err != nil {
+		t.Fatal(err)
+	}
+	sim.SetPose(12, 10, 45)
+	sim.Interrupt()
+
+	bp := snap.currentBreakPoint()
+	if bp == nil || bp.ZoneHash != 101 || math.Abs(bp.X-12) > 1e-4 || math.Abs(bp.Y-10) > 1e-4 {
+		t.Fatalf("breakpoint not reported: %+v", bp)
+	}
+	if err := sendNav(s, breakPointContinueNav()); err != nil {
+		t.Fatal(err)
+	}
+	if sim.Status() != sysStatusWorking {
+		t.Errorf("sys_status after resume = %d, want working", sim.Status())
+	}
+	if bp := snap.currentBreakPoint(); bp != nil {
+		t.Errorf("breakpoint should clear once the job resumes, got %+v", bp)
+	}
+}
@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"mammo/mammotion"
+	pb "mammo/proto"
+
+	"github.com/spf13/cobra"
+)
+
+// rpt_dev_status sys_status values (the app's WorkMode).
+const (
+	sysStatusReady     = 11
+	sysStatusWorking   = 13
+	sysStatusReturning = 14
+	sysStatusCharging  = 15
+	sysStatusPaused    = 19
+)
+
+// taskCtrlBreakPointContinue is the NavTaskCtrl (type 1) action the app sends
+// to continue an interrupted job from its breakpoint rather than restarting
+// the zone. Plain resume (action 0, see pilot's p key) only lifts a pause.
+const taskCtrlBreakPointContinue = 7
+
+// breakPointContinueNav builds the task command that resumes an interrupted
+// job from its breakpoint.
+func breakPointContinueNav() *pb.MctlNav {
+	return &pb.MctlNav{
+		SubNavMsg: &pb.MctlNav_TodevTaskctrl{TodevTaskctrl: &pb.NavTaskCtrl{
+			Type:   1,
+			Action: taskCtrlBreakPointContinue,
+		}},
+	}
+}
+
+// deviceSnapshot collects the reports the device streams after priming.
+type deviceSnapshot struct {
+	mu          sync.Mutex
+	battery     int
+	haveStatus  bool
+	sysStatus   int32
+	chargeState int32
+	work        *mammotion.WorkData
+	breakPoint  *mammotion.BreakPointData
+}
+
+// watchSnapshot registers callbacks that fill a deviceSnapshot.
+func watchSnapshot(s *cloudSession) *deviceSnapshot {
+	snap := &deviceSnapshot{}
+	s.stateManager.OnPropertiesReceived = func() {
+		snap.mu.Lock()
+		snap.battery = s.mowingDevice.BatteryPercentage
+		snap.mu.Unlock()
+	}
+	s.stateManager.OnDeviceStatus = func(sysStatus, chargeState int32) {
+		snap.mu.Lock()
+		snap.haveStatus = true
+		snap.sysStatus, snap.chargeState = sysStatus, chargeState
+		snap.mu.Unlock()
+	}
+	s.stateManager.OnWorkReport = func(w *mammotion.WorkData) {
+		snap.mu.Lock()
+		snap.work = w
+		snap.mu.Unlock()
+	}
+	s.stateManager.OnBreakPointReceived = func(bp *mammotion.BreakPointData) {
+		snap.mu.Lock()
+		snap.breakPoint = bp
+		snap.mu.Unlock()
+	}
+	return snap
+}
+
+// currentBreakPoint prefers a toapp_bp push (it carries the resume heading)
+// over the rpt_work breakpoint, and reports none once rpt_work says the job
+// has no breakpoint.
+func (d *deviceSnapshot) currentBreakPoint() *mammotion.BreakPointData {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.work != nil && d.work.BreakPoint == nil {
+		return nil
+	}
+	if d.breakPoint != nil {
+		return d.breakPoint
+	}
+	if d.work != nil {
+		return d.work.BreakPoint
+	}
+	return nil
+}
+
+// formatBreakPoint describes a breakpoint, naming the zone when a saved map
+// with zone names is available.
+func formatBreakPoint(bp *mammotion.BreakPointData, m *MowerMap) string {
+	out := fmt.Sprintf("%.2f, %.2f", bp.X, bp.Y)
+	if !bp.FromWork {
+		out += fmt.Sprintf(" heading %d", bp.Toward)
+	}
+	if bp.ZoneHash != 0 {
+		zone := fmt.Sprintf("zone %d", int64(bp.ZoneHash))
+		if m != nil {
+			if name := m.AreaNames[int64(bp.ZoneHash)]; name != "" {
+				zone = name
+			}
+		}
+		out += " in " + zone
+	}
+	return out
+}
+
+var (
+	statusWait    int
+	statusMapFile string
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Print battery, device state, job progress and any interrupted-job breakpoint",
+	Run: func(cmd *cobra.Command, args []string) {
+		var m *MowerMap
+		if statusMapFile != "" {
+			var err error
+			if m, err = LoadMap(statusMapFile); err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+		}
+		withSession(func(s *cloudSession) error {
+			snap := watchSnapshot(s)
+			stopPolling := startPolling(s)
+			defer stopPolling()
+			time.Sleep(time.Duration(statusWait) * time.Second)
+
+			snap.mu.Lock()
+			fmt.Println("Device:    ", s.device.DeviceName)
+			fmt.Printf("Battery:    %d%%\n", snap.battery)
+			if snap.haveStatus {
+				fmt.Printf("State:      sys_status=%d charge_state=%d\n", snap.sysStatus, snap.chargeState)
+			}
+			if w := snap.work; w != nil {
+				// area and progress pack two 16-bit values each, as the app
+				// decodes them: percent done / total m², elapsed / total minutes.
+				fmt.Printf("Job:        %d%% of %dm², %d/%d min (path hash %d)\n",
+					w.Area>>16, w.Area&0xffff, w.Progress>>16, w.Progress&0xffff, w.PathHash)
+			}
+			snap.mu.Unlock()
+
+			if bp := snap.currentBreakPoint(); bp != nil {
+				fmt.Println("Breakpoint:", formatBreakPoint(bp, m))
+				fmt.Println("            run `mammo resume` to continue from here")
+			} else {
+				fmt.Println("Breakpoint: none (no interrupted job)")
+			}
+			return nil
+		})
+	},
+}
+
+var (
+	resumeWait    int
+	resumeMapFile string
+)
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "Resume an interrupted job from its breakpoint instead of restarting the zone",
+	Long: `Waits for the device to report the interrupted job's breakpoint, then
+sends the breakpoint-continue task command so the mower returns to where it
+stopped (the yellow ✕ in pilot) and carries on from there. Refuses if the
+device reports no interrupted job.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var m *MowerMap
+		if resumeMapFile != "" {
+			var err error
+			if m, err = LoadMap(resumeMapFile); err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+		}
+		withSession(func(s *cloudSession) error {
+			snap := watchSnapshot(s)
+			stopPolling := startPolling(s)
+			defer stopPolling()
+
+			var bp *mammotion.BreakPointData
+			deadline := time.Now().Add(time.Duration(resumeWait) * time.Second)
+			for bp == nil && time.Now().Before(deadline) {
+				time.Sleep(250 * time.Millisecond)
+				bp = snap.currentBreakPoint()
+			}
+			if bp == nil {
+				return fmt.Errorf("no interrupted job reported within %ds; nothing to resume", resumeWait)
+			}
+			fmt.Println("Breakpoint:", formatBreakPoint(bp, m))
+
+			if err := sendNav(s, breakPointContinueNav()); err != nil {
+				return err
+			}
+			fmt.Println("Sent breakpoint continue. Watching state for 6s...")
+			time.Sleep(6 * time.Second)
+			return nil
+		})
+	},
+}
+
+func init() {
+	statusCmd.Flags().IntVar(&statusWait, "wait", 5, "seconds to collect reports before printing")
+	statusCmd.Flags().StringVar(&statusMapFile, "map", "", "saved map file, used to name the breakpoint's zone")
+	resumeCmd.Flags().IntVar(&resumeWait, "wait", 10, "seconds to wait for the breakpoint report")
+	resumeCmd.Flags().StringVar(&resumeMapFile, "map", "", "saved map file, used to name the breakpoint's zone")
+	rootCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(resumeCmd)
+}
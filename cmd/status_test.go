@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"testing"
+
+	"mammo/mammotion"
+)
+
+func TestCurrentBreakPointPrecedence(t *testing.T) {
+	snap := &deviceSnapshot{}
+	if snap.currentBreakPoint() != nil {
+		t.Fatal("no reports should mean no breakpoint")
+	}
+	fromWork := &mammotion.BreakPointData{X: 1, Y: 2, ZoneHash: 7, FromWork: true}
+	snap.work = &mammotion.WorkData{BreakPoint: fromWork}
+	if snap.currentBreakPoint() != fromWork {
+		t.Error("rpt_work breakpoint should be used when there is no toapp_bp")
+	}
+	pushed := &mammotion.BreakPointData{X: 1, Y: 2, Toward: 90, ZoneHash: 7}
+	snap.breakPoint = pushed
+	if snap.currentBreakPoint() != pushed {
+		t.Error("toapp_bp should win over rpt_work")
+	}
+	snap.work = &mammotion.WorkData{}
+	if snap.currentBreakPoint() != nil {
+		t.Error("a work report without breakpoint means the job is no longer interrupted")
+	}
+	m := &MowerMap{AreaNames: map[int64]string{7: "Front"}}
+	if got := formatBreakPoint(pushed, m); got != "1.00, 2.00 heading 90 in Front" {
+		t.Errorf("formatBreakPoint = %q", got)
+	}
+}
@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// geoOrigin places the map frame on the globe: the latitude and longitude
+// of map point 0,0, with x east and y north in metres.
+type geoOrigin struct {
+	Lat, Lon float64
+}
+
+const earthRadius = 6378137.0 // WGS84 equatorial, metres
+
+// parseGeoOrigin reads "lat,lon" in degrees.
+func parseGeoOrigin(s string) (*geoOrigin, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("--origin %q: want lat,lon", s)
+	}
+	lat, err1 := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	lon, err2 := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err1 != nil || err2 != nil || math.Abs(lat) > 90 || math.Abs(lon) > 180 {
+		return nil, fmt.Errorf("--origin %q: want lat,lon in degrees", s)
+	}
+	return &geoOrigin{Lat: lat, Lon: lon}, nil
+}
+
+// LatLon converts a map point to degrees. A lawn is small enough for the
+// flat-earth approximation to be well under RTK accuracy.
+func (o *geoOrigin) LatLon(x, y float64) (lat, lon float64) {
+	lat = o.Lat + y/earthRadius*180/math.Pi
+	lon = o.Lon + x/(earthRadius*math.Cos(o.Lat*math.Pi/180))*180/math.Pi
+	return lat, lon
+}
+
+// loadTrailPoints reads the points of a trail file (.trail.jsonl) or a pilot
+// session file (.session.json), and the device they came from.
+func loadTrailPoints(path string) (string, []TrailPoint, error) {
+	if strings.HasSuffix(path, ".session.json") {
+		s, err := LoadSession(path)
+		if err != nil {
+			return "", nil, err
+		}
+		return s.Device, s.Trail, nil
+	}
+	t, err := LoadTrail(path)
+	if err != nil {
+		return "", nil, err
+	}
+	return t.Device, t.Points, nil
+}
+
+// writeTrailCSV writes one row per point: time, map position, heading and
+// fix, and latitude and longitude when origin is set.
+func writeTrailCSV(w io.Writer, points []TrailPoint, origin *geoOrigin) error {
+	cw := csv.NewWriter(w)
+	header := []string{"time", "x", "y", "heading", "posType", "fix"}
+	if origin != nil {
+		header = append(header, "lat", "lon")
+	}
+	cw.Write(header)
+	for _, p := range points {
+		row := []string{
+			p.T.Format(time.RFC3339Nano),
+			strconv.FormatFloat(p.X, 'f', 3, 64),
+			strconv.FormatFloat(p.Y, 'f', 3, 64),
+			strconv.FormatFloat(p.Heading, 'f', 1, 64),
+			strconv.Itoa(int(p.PosType)),
+			rtkLabel(p.PosType),
+		}
+		if origin != nil {
+			lat, lon := origin.LatLon(p.X, p.Y)
+			row = append(row, strconv.FormatFloat(lat, 'f', 8, 64), strconv.FormatFloat(lon, 'f', 8, 64))
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// GPX 1.1, with heading and fix type in a mammo extension on each point.
+type gpxFile struct {
+	XMLName xml.Name `xml:"gpx"`
+	Version string   `xml:"version,attr"`
+	Creator string   `xml:"creator,attr"`
+	NS      string   `xml:"xmlns,attr"`
+	NSMammo string   `xml:"xmlns:mammo,attr"`
+	Track   gpxTrack `xml:"trk"`
+}
+
+type gpxTrack struct {
+	Name    string     `xml:"name"`
+	Segment []gpxPoint `xml:"trkseg>trkpt"`
+}
+
+type gpxPoint struct {
+	Lat  string    `xml:"lat,attr"` // GPX decimals have no exponent
+	Lon  string    `xml:"lon,attr"`
+	Time time.Time `xml:"time"`
+	Ext  struct {
+		Heading float64 `xml:"mammo:heading"`
+		PosType int32   `xml:"mammo:posType"`
+		X       float64 `xml:"mammo:x"`
+		Y       float64 `xml:"mammo:y"`
+	} `xml:"extensions"`
+}
+
+// writeTrailGPX writes the points as one GPX track placed by origin.
+func writeTrailGPX(w io.Writer, name string, points []TrailPoint, origin *geoOrigin) error {
+	g := gpxFile{
+		Version: "1.1",
+		Creator: "mammo",
+		NS:      "http://www.topografix.com/GPX/1/1",
+		NSMammo: "urn:mammo:trail:1",
+		Track:   gpxTrack{Name: name},
+	}
+	for _, p := range points {
+		var pt gpxPoint
+		lat, lon := origin.LatLon(p.X, p.Y)
+		pt.Lat, pt.Lon = strconv.FormatFloat(lat, 'f', 8, 64), strconv.FormatFloat(lon, 'f', 8, 64)
+		pt.Time = p.T.UTC()
+		pt.Ext.Heading, pt.Ext.PosType = math.Round(p.Heading*10)/10, p.PosType
+		pt.Ext.X, pt.Ext.Y = math.Round(p.X*1000)/1000, math.Round(p.Y*1000)/1000
+		g.Track.Segment = append(g.Track.Segment, pt)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(g); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+var (
+	trailExportFormat string
+	trailExportOrigin string
+	trailExportOutput string
+)
+
+var trailExportCmd = &cobra.Command{
+	Use:   "trail-export <trail.jsonl|session.json>",
+	Short: "Export a recorded trail as CSV or GPX",
+	Long: `Writes the points of a pilot trail (under --trail-dir) or saved pilot
+session (under --session-dir) as CSV or GPX for analysis elsewhere. Each
+point keeps its time, heading and RTK fix type (posType).
+
+CSV has the map position in metres; with --origin it also has latitude and
+longitude. GPX needs --origin, the latitude and longitude of map point 0,0
+(the RTK base the map was made with), and carries heading, posType and the map
+position in a mammo extension on each point.
+
+  mammo trail-export ~/.mammo/sessions/Luba-VS1234-20261018-101500.session.json -o mow.csv
+  mammo trail-export today.trail.jsonl --origin 51.50127,-0.14189 -o mow.gpx`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		device, points, err := loadTrailPoints(args[0])
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		format := trailExportFormat
+		if format == "" {
+			format = "csv"
+			if strings.EqualFold(filepath.Ext(trailExportOutput), ".gpx") {
+				format = "gpx"
+			}
+		}
+		var origin *geoOrigin
+		if trailExportOrigin != "" {
+			if origin, err = parseGeoOrigin(trailExportOrigin); err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(1)
+			}
+		}
+		switch {
+		case format != "csv" && format != "gpx":
+			fmt.Printf("Error: unknown --format %q (want csv or gpx)\n", format)
+			os.Exit(1)
+		case format == "gpx" && origin == nil:
+			fmt.Println("Error: GPX needs --origin lat,lon for map point 0,0")
+			os.Exit(1)
+		}
+
+		var w io.Writer = os.Stdout
+		if trailExportOutput != "" {
+			f, err := os.Create(trailExportOutput)
+			if err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			w = f
+		}
+		if format == "gpx" {
+			err = writeTrailGPX(w, device, points, origin)
+		} else {
+			err = writeTrailCSV(w, points, origin)
+		}
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		if trailExportOutput != "" {
+			fmt.Printf("%d points written to %s\n", len(points), trailExportOutput)
+		}
+	},
+}
+
+func init() {
+	trailExportCmd.Flags().StringVar(&trailExportFormat, "format", "", "csv or gpx (default from the -o extension, else csv)")
+	trailExportCmd.Flags().StringVar(&trailExportOrigin, "origin", "", "latitude,longitude of map point 0,0 (required for GPX)")
+	trailExportCmd.Flags().StringVarP(&trailExportOutput, "output", "o", "", "output file (default stdout)")
+	rootCmd.AddCommand(trailExportCmd)
+}
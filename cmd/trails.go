@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TrailPoint is one recorded mower position.
+type TrailPoint struct {
+	T       time.Time `json:"t"`
+	X       float64   `json:"x"`
+	Y       float64   `json:"y"`
+	Heading float64   `json:"heading"`
+	PosType int32     `json:"posType"`
+}
+
+// Trail is one recorded session's positions, in time order.
+type Trail struct {
+	Path   string
+	Device string
+	Points []TrailPoint
+}
+
+// trailRecorder appends positions to a per-session trail file as JSON lines,
+// so a crash or a killed terminal loses at most the last point.
+type trailRecorder struct {
+	f    *os.File
+	w    *bufio.Writer
+	enc  *json.Encoder
+	path string
+}
+
+// defaultTrailDir is where pilot records trails unless --trail-dir says
+// otherwise.
+func defaultTrailDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "trails"
+	}
+	return filepath.Join(home, ".mammo", "trails")
+}
+
+// newTrailRecorder creates <dir>/<device>-<start time>.trail.jsonl.
+func newTrailRecorder(dir, device string, start time.Time) (*trailRecorder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("%s-%s.trail.jsonl", sanitizeFileName(device), start.Format("20060102-150405"))
+	path := filepath.Join(dir, name)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(f)
+	return &trailRecorder{f: f, w: w, enc: json.NewEncoder(w), path: path}, nil
+}
+
+func (r *trailRecorder) Record(p TrailPoint) error {
+	if err := r.enc.Encode(p); err != nil {
+		return err
+	}
+	return r.w.Flush()
+}
+
+func (r *trailRecorder) Close() error {
+	r.w.Flush()
+	return r.f.Close()
+}
+
+func sanitizeFileName(s string) string {
+	if s == "" {
+		return "mower"
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// LoadTrail reads a trail file. Malformed lines (e.g. a torn final write)
+// are skipped.
+func LoadTrail(path string) (*Trail, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	t := &Trail{Path: path, Device: trailDevice(filepath.Base(path))}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var p TrailPoint
+		if err := json.Unmarshal(sc.Bytes(), &p); err != nil {
+			continue
+		}
+		t.Points = append(t.Points, p)
+	}
+	return t, sc.Err()
+}
+
+// trailDevice recovers the device name from a trail file name.
+func trailDevice(name string) string {
+	name = strings.TrimSuffix(name, ".trail.jsonl")
+	// strip the -YYYYMMDD-HHMMSS suffix
+	if len(name) > 16 && name[len(name)-16] == '-' {
+		return name[:len(name)-16]
+	}
+	return name
+}
+
+// LoadTrails reads every trail of device in dir with at least one point in
+// [from, to); an empty device reads every mower's. Zero times leave that end
+// of the range open. Points outside the range are dropped.
+func LoadTrails(dir, device string, from, to time.Time) ([]*Trail, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.trail.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	var trails []*Trail
+	for _, path := range paths {
+		if device != "" && trailDevice(filepath.Base(path)) != sanitizeFileName(device) {
+			continue
+		}
+		t, err := LoadTrail(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		kept := t.Points[:0]
+		for _, p := range t.Points {
+			if (!from.IsZero() && p.T.Before(from)) || (!to.IsZero() && !p.T.Before(to)) {
+				continue
+			}
+			kept = append(kept, p)
+		}
+		t.Points = kept
+		if len(t.Points) > 0 {
+			trails = append(trails, t)
+		}
+	}
+	return trails, nil
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadTrailsFiltersRange(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	rec, err := newTrailRecorder(dir, "Luba-VS1", start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.Record(TrailPoint{T: start, X: 1})
+	rec.Record(TrailPoint{T: start.Add(48 * time.Hour), X: 2})
+	rec.Close()
+	other, err := newTrailRecorder(dir, "Yuka-XY2", start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.Record(TrailPoint{T: start, X: 3})
+	other.Close()
+
+	trails, err := LoadTrails(dir, "Luba-VS1", start.Add(-time.Hour), start.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trails) != 1 || len(trails[0].Points) != 1 || trails[0].Device != "Luba-VS1" {
+		t.Fatalf("unexpected trails: %+v", trails)
+	}
+	if all, _ := LoadTrails(dir, "", time.Time{}, time.Time{}); len(all) != 2 {
+		t.Errorf("every device: %d trails, want 2", len(all))
+	}
+}
@@ -0,0 +1,296 @@
+package cmd
+
+import (
+	"container/heap"
+	"errors"
+	"math"
+)
+
+const (
+	// routeClearance is how far (metres) a planned route keeps from no-go
+	// zones: about half the mower's width plus some margin.
+	routeClearance = 0.4
+	// routeCell is the planning grid resolution (metres) when a straight
+	// line is blocked.
+	routeCell = 0.25
+	// routeMaxCells bounds the planning grid; larger maps use coarser cells.
+	routeMaxCells = 250000
+
+	// waypointArrive is how close (metres) counts as reaching a waypoint.
+	waypointArrive = 0.3
+	// waypointAlign is the heading error (degrees) above which the mower
+	// turns on the spot before driving on.
+	waypointAlign = 25.0
+)
+
+// mapObstacles returns the map's no-go polygons.
+func mapObstacles(m *MowerMap) [][]MapPoint {
+	if m == nil {
+		return nil
+	}
+	var obs [][]MapPoint
+	for _, el := range m.Elements {
+		if el.Type == MapTypeObstacle && len(el.Points) >= 3 {
+			obs = append(obs, el.Points)
+		}
+	}
+	return obs
+}
+
+// nearObstacle reports whether p is inside a no-go polygon or within
+// clearance of its edge.
+func nearObstacle(obs [][]MapPoint, x, y, clearance float64) bool {
+	for _, poly := range obs {
+		if pointInPolygon(x, y, poly) {
+			return true
+		}
+		for i, j := 0, len(poly)-1; i < len(poly); j, i = i, i+1 {
+			if distToSegment(x, y, poly[j].X, poly[j].Y, poly[i].X, poly[i].Y) < clearance {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// segmentClear reports whether the straight line a-b keeps clear of every
+// no-go zone, sampled every 5cm.
+func segmentClear(obs [][]MapPoint, a, b MapPoint, clearance float64) bool {
+	n := int(math.Ceil(math.Hypot(b.X-a.X, b.Y-a.Y)/0.05)) + 1
+	for i := 0; i <= n; i++ {
+		t := float64(i) / float64(n)
+		if nearObstacle(obs, a.X+(b.X-a.X)*t, a.Y+(b.Y-a.Y)*t, clearance) {
+			return false
+		}
+	}
+	return true
+}
+
+// planRoute finds a route from one point to another that keeps clearance from
+// the map's no-go zones: the straight line when it is clear, otherwise an A*
+// search over a grid, shortened to the fewest straight legs. The route starts
+// at from and ends at to. A start inside the clearance band is allowed so a
+// mower parked near an obstacle can still drive away.
+func planRoute(m *MowerMap, from, to MapPoint, clearance float64) ([]MapPoint, error) {
+	obs := mapObstacles(m)
+	if nearObstacle(obs, to.X, to.Y, clearance) {
+		return nil, errors.New("target is in or too close to a no-go zone")
+	}
+	if segmentClear(obs, from, to, clearance) {
+		return []MapPoint{from, to}, nil
+	}
+
+	minX, minY := math.Min(from.X, to.X), math.Min(from.Y, to.Y)
+	maxX, maxY := math.Max(from.X, to.X), math.Max(from.Y, to.Y)
+	if m != nil {
+		if x0, y0, x1, y1, ok := m.Bounds(); ok {
+			minX, minY = math.Min(minX, x0), math.Min(minY, y0)
+			maxX, maxY = math.Max(maxX, x1), math.Max(maxY, y1)
+		}
+	}
+	minX, minY, maxX, maxY = minX-2, minY-2, maxX+2, maxY+2
+	cell := routeCell
+	for (maxX-minX)/cell*(maxY-minY)/cell > routeMaxCells {
+		cell *= 1.5
+	}
+	g := routeGrid{minX: minX, minY: minY, cell: cell,
+		nx: int(math.Ceil((maxX-minX)/cell)) + 1, ny: int(math.Ceil((maxY-minY)/cell)) + 1}
+
+	start, goal := g.index(from), g.index(to)
+	blocked := func(i int) bool {
+		if i == start || i == goal {
+			return false
+		}
+		p := g.center(i)
+		return nearObstacle(obs, p.X, p.Y, clearance)
+	}
+	cells := g.search(start, goal, blocked)
+	if cells == nil {
+		return nil, errors.New("no route around the no-go zones")
+	}
+
+	raw := make([]MapPoint, len(cells))
+	for i, c := range cells {
+		raw[i] = g.center(c)
+	}
+	raw[0], raw[len(raw)-1] = from, to
+
+	// Keep only the turns: from each point, jump to the farthest one still
+	// in straight-line sight.
+	route := []MapPoint{from}
+	for i := 0; i < len(raw)-1; {
+		j := len(raw) - 1
+		for j > i+1 && !segmentClear(obs, raw[i], raw[j], clearance) {
+			j--
+		}
+		route = append(route, raw[j])
+		i = j
+	}
+	return route, nil
+}
+
+// routeGrid is the A* search space: nx×ny cells of size cell from minX,minY.
+type routeGrid struct {
+	minX, minY, cell float64
+	nx, ny           int
+}
+
+func (g routeGrid) index(p MapPoint) int {
+	i := int(math.Round((p.X - g.minX) / g.cell))
+	j := int(math.Round((p.Y - g.minY) / g.cell))
+	return j*g.nx + i
+}
+
+func (g routeGrid) center(idx int) MapPoint {
+	return MapPoint{X: g.minX + float64(idx%g.nx)*g.cell, Y: g.minY + float64(idx/g.nx)*g.cell}
+}
+
+// search runs 8-connected A* and returns the cells from start to goal, or nil.
+func (g routeGrid) search(start, goal int, blocked func(int) bool) []int {
+	gp := g.center(goal)
+	h := func(i int) float64 {
+		p := g.center(i)
+		return math.Hypot(p.X-gp.X, p.Y-gp.Y)
+	}
+	cost := map[int]float64{start: 0}
+	came := map[int]int{}
+	closed := map[int]bool{}
+	open := &routeQueue{{idx: start, f: h(start)}}
+	for open.Len() > 0 {
+		cur := heap.Pop(open).(routeNode).idx
+		if cur == goal {
+			path := []int{goal}
+			for cur != start {
+				cur = came[cur]
+				path = append(path, cur)
+			}
+			for l, r := 0, len(path)-1; l < r; l, r = l+1, r-1 {
+				path[l], path[r] = path[r], path[l]
+			}
+			return path
+		}
+		if closed[cur] {
+			continue
+		}
+		closed[cur] = true
+		ci, cj := cur%g.nx, cur/g.nx
+		for dj := -1; dj <= 1; dj++ {
+			for di := -1; di <= 1; di++ {
+				ni, nj := ci+di, cj+dj
+				if (di == 0 && dj == 0) || ni < 0 || nj < 0 || ni >= g.nx || nj >= g.ny {
+					continue
+				}
+				next := nj*g.nx + ni
+				if closed[next] || blocked(next) {
+					continue
+				}
+				c := cost[cur] + math.Hypot(float64(di), float64(dj))*g.cell
+				if old, seen := cost[next]; seen && old <= c {
+					continue
+				}
+				cost[next] = c
+				came[next] = cur
+				heap.Push(open, routeNode{idx: next, f: c + h(next)})
+			}
+		}
+	}
+	return nil
+}
+
+type routeNode struct {
+	idx int
+	f   float64
+}
+
+// routeQueue is a min-heap of routeNodes by f.
+type routeQueue []routeNode
+
+func (q routeQueue) Len() int            { return len(q) }
+func (q routeQueue) Less(i, j int) bool  { return q[i].f < q[j].f }
+func (q routeQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *routeQueue) Push(x interface{}) { *q = append(*q, x.(routeNode)) }
+func (q *routeQueue) Pop() interface{} {
+	old := *q
+	n := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return n
+}
+
+// bearingTo is the compass bearing (0 = north, clockwise) from one point to
+// another, in degrees.
+func bearingTo(fromX, fromY, toX, toY float64) float64 {
+	b := math.Atan2(toX-fromX, toY-fromY) * 180 / math.Pi
+	if b < 0 {
+		b += 360
+	}
+	return b
+}
+
+// headingError is target minus heading, wrapped to (-180, 180]. Positive
+// means the target is to the right.
+func headingError(target, heading float64) float64 {
+	e := math.Mod(target-heading, 360)
+	if e > 180 {
+		e -= 360
+	} else if e <= -180 {
+		e += 360
+	}
+	return e
+}
+
+// waypointNav steers the mower along a route from position feedback. Each
+// Step gives the motion command for the latest pose; the pilot holds it until
+// the next position report.
+type waypointNav struct {
+	route    []MapPoint // remaining waypoints; route[0] is the current target
+	target   MapPoint   // the final waypoint
+	speed    int32      // mm/s cruising speed
+	turnRate int32      // mrad/s turning speed
+}
+
+// newWaypointNav follows route, whose first point is the start position.
+func newWaypointNav(route []MapPoint, speed, turnRate int32) *waypointNav {
+	return &waypointNav{
+		route:    append([]MapPoint(nil), route[1:]...),
+		target:   route[len(route)-1],
+		speed:    speed,
+		turnRate: turnRate,
+	}
+}
+
+// Step returns the motion command toward the current waypoint, advancing
+// past waypoints within waypointArrive. done is true once the last one is
+// reached. Far off the bearing the mower turns on the spot; otherwise it
+// drives while steering in proportion to the error and slows for the end.
+func (w *waypointNav) Step(x, y, heading float64) (linear, angular int32, done bool) {
+	for len(w.route) > 0 && math.Hypot(w.route[0].X-x, w.route[0].Y-y) < waypointArrive {
+		w.route = w.route[1:]
+	}
+	if len(w.route) == 0 {
+		return 0, 0, true
+	}
+	next := w.route[0]
+	e := headingError(bearingTo(x, y, next.X, next.Y), heading)
+	clamp := func(v, limit float64) int32 {
+		return int32(math.Max(-limit, math.Min(limit, v)))
+	}
+	if math.Abs(e) > waypointAlign {
+		return 0, clamp(math.Copysign(float64(w.turnRate), e), float64(w.turnRate)), false
+	}
+	// Slow down over the last metre, but not below a speed that still moves.
+	remaining := math.Hypot(w.target.X-x, w.target.Y-y)
+	linear = clamp(float64(w.speed)*math.Min(1, remaining), float64(w.speed))
+	if floor := min(150, w.speed); linear < floor {
+		linear = floor
+	}
+	return linear, clamp(e*math.Pi/180*2000, float64(w.turnRate)), false
+}
+
+// routeLength is the length of a polyline in metres.
+func routeLength(route []MapPoint) float64 {
+	d := 0.0
+	for i := 1; i < len(route); i++ {
+		d += math.Hypot(route[i].X-route[i-1].X, route[i].Y-route[i-1].Y)
+	}
+	return d
+}
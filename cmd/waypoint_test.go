@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"mammo/mammotion"
+)
+
+func TestWaypointRouteAndDrive(t *testing.T) {
+	m := simLawn()
+	s, sim := newSimSession(m)
+	obs := mapObstacles(m)
+
+	// The straight line from west to east of the zone crosses the obstacle.
+	from, to := MapPoint{X: 5, Y: 10}, MapPoint{X: 15, Y: 10}
+	route, err := planRoute(m, from, to, routeClearance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(route) < 3 || route[0] != from || route[len(route)-1] != to {
+		t.Fatalf("route = %v", route)
+	}
+	for i := 1; i < len(route); i++ {
+		if !segmentClear(obs, route[i-1], route[i], routeClearance) {
+			t.Errorf("leg %d %v→%v cuts the no-go zone", i, route[i-1], route[i])
+		}
+	}
+	if _, err := planRoute(m, from, MapPoint{X: 10.5, Y: 9.5}, routeClearance); err == nil {
+		t.Error("planned a route into the no-go zone")
+	}
+
+	// Drive it closed-loop against the simulator.
+	sim.SetPose(from.X, from.Y, 200)
+	nav := newWaypointNav(route, 400, 450)
+	for i := 0; ; i++ {
+		if i > 2000 {
+			t.Fatal("never arrived")
+		}
+		x, y, h := sim.Pose()
+		if nearObstacle(obs, x, y, 0) {
+			t.Fatalf("drove into the no-go zone at %.2f, %.2f", x, y)
+		}
+		lin, ang, done := nav.Step(x, y, h)
+		if done {
+			break
+		}
+		data, err := mammotion.SendMotionControl(lin, ang)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.send(data)
+		sim.Step(200 * time.Millisecond)
+	}
+	if x, y, _ := sim.Pose(); math.Hypot(x-to.X, y-to.Y) > waypointArrive {
+		t.Errorf("stopped at %.2f, %.2f, want within %.1fm of %v", x, y, waypointArrive, to)
+	}
+}
+
+func TestPilotGotoStopsOnFixLoss(t *testing.T) {
+	s, _ := newSimSession(simLawn())
+	model := pilotModel{
+		session: s, motion: &motionController{session: s, notify: func(string) {}},
+		mowerMap: simLawn(), speed: 400, turnRate: 450, minBattery: 15, zoom: 1, width: 80, height: 24,
+	}
+	next, _ := model.Update(pilotPosMsg{x: 5, y: 10, heading: 90, posType: 4})
+	model = next.(pilotModel)
+	model.pickTarget(7, 10)
+	model.startGoto()
+	if model.nav == nil {
+		t.Fatalf("go-to did not start: %s", model.status)
+	}
+	next, _ = model.Update(pilotPosMsg{x: 5.1, y: 10, heading: 90, posType: 5})
+	model = next.(pilotModel)
+	if model.nav != nil || !strings.Contains(model.status, "RTK fix lost") {
+		t.Errorf("go-to after losing the fix: nav %v, status %q", model.nav, model.status)
+	}
+
+	// Without a fix it refuses to start at all.
+	model.pickTarget(7, 10)
+	model.startGoto()
+	if model.nav != nil || !strings.Contains(model.status, "needs an RTK fix") {
+		t.Errorf("go-to without a fix: status %q", model.status)
+	}
+}
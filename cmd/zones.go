@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// colZoneSelected draws the zone selected in pilot's zone browser.
+const colZoneSelected = 118 // lime
+
+// zoneInfo is one mowing area as the zone browser lists it.
+type zoneInfo struct {
+	hash      int64
+	name      string
+	area      float64 // m²
+	perimeter float64 // m
+	points    []MapPoint
+}
+
+// mapZones lists the mowing areas of m in map order.
+func mapZones(m *MowerMap) []zoneInfo {
+	if m == nil {
+		return nil
+	}
+	var zones []zoneInfo
+	for i := range m.Elements {
+		el := &m.Elements[i]
+		if el.Type != MapTypeArea || len(el.Points) < 3 {
+			continue
+		}
+		name := m.ElementLabel(el)
+		if name == "" {
+			name = fmt.Sprintf("zone %d", len(zones)+1)
+		}
+		zones = append(zones, zoneInfo{
+			hash:      el.Hash,
+			name:      name,
+			area:      math.Abs(polygonArea(el.Points)),
+			perimeter: polygonEdgeLength(el.Points),
+			points:    el.Points,
+		})
+	}
+	return zones
+}
+
+// polygonEdgeLength is the perimeter of a closed polygon.
+func polygonEdgeLength(pts []MapPoint) float64 {
+	var d float64
+	for i := range pts {
+		j := (i + 1) % len(pts)
+		d += math.Hypot(pts[j].X-pts[i].X, pts[j].Y-pts[i].Y)
+	}
+	return d
+}
+
+// selectedZone is the zone under the browser's cursor, or nil.
+func (m pilotModel) selectedZone() *zoneInfo {
+	if !m.zoneBrowse || m.zoneSel < 0 || m.zoneSel >= len(m.zoneList) {
+		return nil
+	}
+	return &m.zoneList[m.zoneSel]
+}
+
+// openZones opens the zone browser on the zone the mower is in, or the
+// first.
+func (m *pilotModel) openZones() {
+	m.zoneList = mapZones(m.mowerMap)
+	if len(m.zoneList) == 0 {
+		m.status = "no zones: the map has no mowing areas"
+		return
+	}
+	if m.dash.width == 0 {
+		m.dash.width = panelDefaultWidth
+	}
+	m.zoneBrowse, m.zoneSel, m.zoneConfirm = true, 0, ""
+	for i, z := range m.zoneList {
+		if m.posValid && pointInPolygon(m.posX, m.posY, z.points) {
+			m.zoneSel = i
+		}
+	}
+	m.centreOnZone()
+}
+
+// centreOnZone zooms and pans the view to fit the selected zone.
+func (m *pilotModel) centreOnZone() {
+	z := m.selectedZone()
+	if z == nil {
+		return
+	}
+	m.zoom, m.panX, m.panY = 1, 0, 0
+	_, vp := m.mapView()
+	if vp == nil {
+		return
+	}
+	minX, minY, maxX, maxY := z.points[0].X, z.points[0].Y, z.points[0].X, z.points[0].Y
+	for _, p := range z.points {
+		minX, maxX = math.Min(minX, p.X), math.Max(maxX, p.X)
+		minY, maxY = math.Min(minY, p.Y), math.Max(maxY, p.Y)
+	}
+	w, h := vp.MaxX-vp.MinX, vp.MaxY-vp.MinY
+	zoom := 0.8 * math.Min(w/math.Max(maxX-minX, 1), h/math.Max(maxY-minY, 1))
+	m.zoom = math.Max(1, math.Min(64, zoom))
+	// Pan is a fraction of the zoomed view.
+	m.panX = ((minX+maxX)/2 - (vp.MinX+vp.MaxX)/2) * m.zoom / w
+	m.panY = ((minY+maxY)/2 - (vp.MinY+vp.MaxY)/2) * m.zoom / h
+}
+
+// zoneKey handles a key while the zone browser is open, reporting whether
+// it was a browser key. Mowing and edge mowing ask for y to confirm and are
+// refused, like driving, in view-only mode or below --min-battery.
+func (m *pilotModel) zoneKey(key string) (tea.Cmd, bool) {
+	z := m.selectedZone()
+	if m.zoneConfirm != "" {
+		action := m.zoneConfirm
+		m.zoneConfirm = ""
+		if key != "y" {
+			m.status = action + " cancelled"
+			return nil, true
+		}
+		if !m.canDrive() {
+			m.status = action + " refused: driving is disabled"
+			return nil, true
+		}
+		knife := m.knifeHeight()
+		route, err := zoneRouteNav(z.hash, knife)
+		if err != nil {
+			m.status = action + " refused: " + err.Error()
+			return nil, true
+		}
+		m.showPlanned = true
+		if action == "edge mow" {
+			m.status = fmt.Sprintf("edge mowing %s…", z.name)
+			return m.sendNavCmd("edge mow "+z.name, route, edgeMowNav()), true
+		}
+		m.status = fmt.Sprintf("mowing %s…", z.name)
+		return m.sendNavCmd("mow "+z.name, route, startMowingNav(false, false, knife)), true
+	}
+
+	switch key {
+	case "up", "w":
+		m.zoneSel = (m.zoneSel + len(m.zoneList) - 1) % len(m.zoneList)
+		m.centreOnZone()
+	case "down", "s":
+		m.zoneSel = (m.zoneSel + 1) % len(m.zoneList)
+		m.centreOnZone()
+	case "enter":
+		m.centreOnZone()
+	case "m", "e":
+		action := "mow"
+		if key == "e" {
+			action = "edge mow"
+		}
+		switch {
+		case m.viewOnly:
+			m.status = "view-only: " + action + " disabled"
+		case m.batteryLow():
+			m.status = fmt.Sprintf("battery %d%% is below --min-battery %d%%: %s disabled", m.battery, m.minBattery, action)
+		case m.knifeHeight() == 0:
+			m.status = action + " waits for the mower's work report: the cutting height is not known yet"
+		default:
+			m.zoneConfirm = action
+			m.status = fmt.Sprintf("%s %s (%.0f m²)? y confirms, any other key cancels", action, z.name, z.area)
+		}
+	case "v":
+		if m.viewOnly {
+			m.status = "view-only: route request disabled"
+			break
+		}
+		if m.plannedZone == z.hash && len(m.plannedPath) > 1 {
+			m.showPlanned = true
+			m.status = "showing the route for " + z.name
+			break
+		}
+		route, err := zoneRouteNav(z.hash, m.knifeHeight())
+		if err != nil {
+			m.status = "route request refused: " + err.Error()
+			break
+		}
+		m.showPlanned = true
+		m.status = "requesting the route for " + z.name + "…"
+		return m.sendNavCmd("route request for "+z.name, route), true
+	case "esc", "z":
+		m.zoneBrowse, m.zoneConfirm = false, ""
+		m.status = "zone browser closed"
+	default:
+		return nil, false
+	}
+	return nil, true
+}
+
+// knifeHeight is the cutting height from the last work report, or 0 before
+// one has arrived. Starting a job without it lets the device use its own
+// setting, but planning a zone's route needs it.
+func (m pilotModel) knifeHeight() int32 {
+	if m.dash.work != nil {
+		return m.dash.work.KnifeHeight
+	}
+	return 0
+}
+
+// zonePanel renders the zone browser at the top of the side column, listing
+// at most rows zones around the selection.
+func (m pilotModel) zonePanel(p *panelWriter, rows int) {
+	p.title("Zones")
+	lo := 0
+	if n := len(m.zoneList); n > rows {
+		lo = max(0, min(n-rows, m.zoneSel-rows/2))
+	}
+	for i := lo; i < len(m.zoneList) && i < lo+rows; i++ {
+		z := m.zoneList[i]
+		mark := "  "
+		if i == m.zoneSel {
+			mark = "▸ "
+		}
+		route := ""
+		if z.hash == m.plannedZone && len(m.plannedPath) > 1 {
+			route = " ◆"
+		}
+		p.add(false, "%s%s  %.0f m²  %.0f m%s", mark, z.name, z.area, z.perimeter, route)
+	}
+	if m.zoneConfirm != "" {
+		p.add(true, "%s %s? y confirms", m.zoneConfirm, m.zoneList[m.zoneSel].name)
+	} else {
+		p.add(false, "m mow · e edge · v route · esc")
+	}
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"mammo/mammotion"
+	pb "mammo/proto"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestPilotZoneBrowser(t *testing.T) {
+	zones := mapZones(simLawn())
+	if len(zones) != 1 || zones[0].name != "Front" || math.Abs(zones[0].area-math.Pi*64) > 5 {
+		t.Fatalf("zones = %+v", zones)
+	}
+
+	s, sim := newSimSession(simLawn())
+	var routed []uint64
+	s.stateManager.OnZigZagReceived = func(zz *mammotion.ZigZagData) { routed = append(routed, zz.CurrentHash) }
+	mc := &motionController{session: s, notify: func(string) {}}
+	var model tea.Model = pilotModel{session: s, motion: mc, speed: 400, turnRate: 450, minBattery: 15, zoom: 1, battery: 80}
+	key := func(k string) tea.Cmd {
+		var cmd tea.Cmd
+		model, cmd = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)})
+		if cmd != nil {
+			model, _ = model.Update(cmd())
+		}
+		return cmd
+	}
+	for _, msg := range []tea.Msg{tea.WindowSizeMsg{Width: 120, Height: 40}, pilotMapMsg(simLawn()), pilotPosMsg{x: 10, y: 4, posType: 4}} {
+		model, _ = model.Update(msg)
+	}
+
+	key("z")
+	m := model.(pilotModel)
+	if z := m.selectedZone(); z == nil || z.hash != 101 || m.panelWidth() == 0 {
+		t.Fatalf("zone browser: selected %v, panel %d", z != nil, m.panelWidth())
+	}
+	if !strings.Contains(m.View(), "▸ Front") {
+		t.Error("zone list missing from the view")
+	}
+
+	// Until a work report gives the cutting height, nothing can be planned.
+	if key("v") != nil || !strings.Contains(model.(pilotModel).status, "cutting height is not known") {
+		t.Errorf("route requested without a knife height: %q", model.(pilotModel).status)
+	}
+	key("m")
+	if m := model.(pilotModel); m.zoneConfirm != "" {
+		t.Error("mow offered without a knife height")
+	}
+	model, _ = model.Update(pilotWorkMsg(&mammotion.WorkData{KnifeHeight: 55}))
+
+	// Showing the route asks the device to plan it, with the app's settings.
+	key("v")
+	if len(routed) == 0 || routed[0] != 101 {
+		t.Errorf("route request answered with zones %v", routed)
+	}
+	var req *pb.NavReqCoverPath
+	for _, msg := range sim.Received() {
+		if r := msg.GetNav().GetBidireReqconverPath(); r != nil {
+			req = r
+		}
+	}
+	if req.GetKnifeHeight() != 55 || req.GetJobMode() != routeJobMode || req.GetChannelWidth() != routeChannelWidth || req.GetSpeed() != routeSpeed {
+		t.Errorf("route request %v", req)
+	}
+
+	// Mowing asks for confirmation, then routes and starts the zone.
+	if key("m") != nil || sim.Status() == sysStatusWorking {
+		t.Fatal("mow started without confirmation")
+	}
+	key("n")
+	if m := model.(pilotModel); m.zoneConfirm != "" || !strings.Contains(m.status, "cancelled") {
+		t.Errorf("n should cancel: %q", m.status)
+	}
+	key("m")
+	key("y")
+	if sim.Status() != sysStatusWorking {
+		t.Fatalf("zone mow did not start: status %d (%q)", sim.Status(), model.(pilotModel).status)
+	}
+
+	// Below --min-battery the edge mow is refused outright.
+	model, _ = model.Update(pilotBatteryMsg(10))
+	key("e")
+	if m := model.(pilotModel); m.zoneConfirm != "" || !strings.Contains(m.status, "below --min-battery") {
+		t.Errorf("low battery edge mow: confirm %q, status %q", m.zoneConfirm, m.status)
+	}
+	var edges int
+	for _, msg := range sim.Received() {
+		if _, ok := msg.GetNav().GetSubNavMsg().(*pb.MctlNav_TodevEdgecmd); ok {
+			edges++
+		}
+	}
+	if edges != 0 {
+		t.Error("edge command sent despite the battery gate")
+	}
+	key("esc")
+	if m := model.(pilotModel); m.zoneBrowse || m.panelWidth() != 0 {
+		t.Error("esc did not close the zone browser")
+	}
+}
@@ -0,0 +1,422 @@
+package fakecloud
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+)
+
+// MQTT 3.1.1 control packet types.
+const (
+	packetConnect     = 1
+	packetConnAck     = 2
+	packetPublish     = 3
+	packetPubAck      = 4
+	packetSubscribe   = 8
+	packetSubAck      = 9
+	packetUnsubscribe = 10
+	packetUnsubAck    = 11
+	packetPingReq     = 12
+	packetPingResp    = 13
+	packetDisconnect  = 14
+)
+
+// CONNACK return codes.
+const (
+	connAccepted        = 0
+	connRefusedProtocol = 1
+	connRefusedAuth     = 5
+)
+
+// Broker is a minimal in-process MQTT 3.1.1 broker: enough of the protocol
+// for paho to connect, subscribe and exchange QoS 0/1 publishes. Everything is
+// delivered at QoS 0; there are no retained messages, wills or persistent
+// sessions.
+type Broker struct {
+	// Authenticate, when set, decides whether a CONNECT is accepted.
+	Authenticate func(clientID, username, password string) bool
+	// OnPublish, when set, sees every publish a client makes.
+	OnPublish func(clientID, topic string, payload []byte)
+
+	ln     net.Listener
+	scheme string
+
+	mu    sync.Mutex
+	conns map[*brokerConn]struct{}
+}
+
+// NewBroker listens on addr (use 127.0.0.1:0 for a free port). With a non-nil
+// tlsConfig clients must connect over TLS.
+func NewBroker(addr string, tlsConfig *tls.Config) (*Broker, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	scheme := "tcp"
+	if tlsConfig != nil {
+		ln = tls.NewListener(ln, tlsConfig)
+		scheme = "tls"
+	}
+	b := &Broker{ln: ln, scheme: scheme, conns: make(map[*brokerConn]struct{})}
+	go b.serve()
+	return b, nil
+}
+
+// URL is the broker address in the form paho expects.
+func (b *Broker) URL() string {
+	return b.scheme + "://" + b.ln.Addr().String()
+}
+
+// Close stops listening and drops every client.
+func (b *Broker) Close() error {
+	err := b.ln.Close()
+	b.mu.Lock()
+	for c := range b.conns {
+		c.conn.Close()
+	}
+	b.mu.Unlock()
+	return err
+}
+
+// DropClients closes every client connection but keeps listening, as a
+// broker restart would: clients that reconnect start with no subscriptions.
+func (b *Broker) DropClients() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for c := range b.conns {
+		c.conn.Close()
+	}
+}
+
+// Publish delivers payload to every client subscribed to a matching filter.
+func (b *Broker) Publish(topic string, payload []byte) {
+	b.mu.Lock()
+	var targets []*brokerConn
+	for c := range b.conns {
+		if c.subscribed(topic) {
+			targets = append(targets, c)
+		}
+	}
+	b.mu.Unlock()
+	for _, c := range targets {
+		c.publish(topic, payload)
+	}
+}
+
+// Clients returns the ids of the connected clients.
+func (b *Broker) Clients() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var ids []string
+	for c := range b.conns {
+		if c.clientID != "" {
+			ids = append(ids, c.clientID)
+		}
+	}
+	return ids
+}
+
+func (b *Broker) serve() {
+	for {
+		conn, err := b.ln.Accept()
+		if err != nil {
+			return
+		}
+		c := &brokerConn{broker: b, conn: conn}
+		b.mu.Lock()
+		b.conns[c] = struct{}{}
+		b.mu.Unlock()
+		go c.run()
+	}
+}
+
+type brokerConn struct {
+	broker   *Broker
+	conn     net.Conn
+	clientID string
+
+	writeMu sync.Mutex
+
+	subMu   sync.Mutex
+	filters []string
+}
+
+func (c *brokerConn) run() {
+	defer func() {
+		c.conn.Close()
+		c.broker.mu.Lock()
+		delete(c.broker.conns, c)
+		c.broker.mu.Unlock()
+	}()
+	r := bufio.NewReader(c.conn)
+	connected := false
+	for {
+		header, body, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		kind := header >> 4
+		if !connected && kind != packetConnect {
+			return
+		}
+		switch kind {
+		case packetConnect:
+			code := c.handleConnect(body)
+			c.write(packetConnAck<<4, []byte{0, code})
+			if code != connAccepted {
+				return
+			}
+			connected = true
+		case packetPublish:
+			if err := c.handlePublish(header, body); err != nil {
+				return
+			}
+		case packetSubscribe:
+			if err := c.handleSubscribe(body); err != nil {
+				return
+			}
+		case packetUnsubscribe:
+			if err := c.handleUnsubscribe(body); err != nil {
+				return
+			}
+		case packetPingReq:
+			c.write(packetPingResp<<4, nil)
+		case packetDisconnect:
+			return
+		}
+	}
+}
+
+func (c *brokerConn) handleConnect(body []byte) byte {
+	p := packetReader{data: body}
+	proto := p.string()
+	level := p.byte()
+	flags := p.byte()
+	p.uint16() // keep alive
+	clientID := p.string()
+	if flags&0x04 != 0 { // will topic and message
+		p.string()
+		p.string()
+	}
+	var username, password string
+	if flags&0x80 != 0 {
+		username = p.string()
+	}
+	if flags&0x40 != 0 {
+		password = p.string()
+	}
+	if p.err != nil || proto != "MQTT" || level != 4 {
+		return connRefusedProtocol
+	}
+	if c.broker.Authenticate != nil && !c.broker.Authenticate(clientID, username, password) {
+		return connRefusedAuth
+	}
+	c.clientID = clientID
+	return connAccepted
+}
+
+func (c *brokerConn) handlePublish(header byte, body []byte) error {
+	qos := (header >> 1) & 0x03
+	p := packetReader{data: body}
+	topic := p.string()
+	var id uint16
+	if qos > 0 {
+		id = p.uint16()
+	}
+	if p.err != nil {
+		return p.err
+	}
+	payload := append([]byte(nil), p.rest()...)
+	if qos == 1 {
+		c.write(packetPubAck<<4, []byte{byte(id >> 8), byte(id)})
+	}
+	if c.broker.OnPublish != nil {
+		c.broker.OnPublish(c.clientID, topic, payload)
+	}
+	c.broker.Publish(topic, payload)
+	return nil
+}
+
+func (c *brokerConn) handleSubscribe(body []byte) error {
+	p := packetReader{data: body}
+	id := p.uint16()
+	ack := []byte{byte(id >> 8), byte(id)}
+	for p.err == nil && len(p.data) > 0 {
+		filter := p.string()
+		p.byte() // requested QoS; everything goes out at 0
+		if p.err != nil {
+			break
+		}
+		c.subMu.Lock()
+		c.filters = append(c.filters, filter)
+		c.subMu.Unlock()
+		ack = append(ack, 0)
+	}
+	if p.err != nil {
+		return p.err
+	}
+	c.write(packetSubAck<<4, ack)
+	return nil
+}
+
+func (c *brokerConn) handleUnsubscribe(body []byte) error {
+	p := packetReader{data: body}
+	id := p.uint16()
+	for p.err == nil && len(p.data) > 0 {
+		filter := p.string()
+		c.subMu.Lock()
+		for i, f := range c.filters {
+			if f == filter {
+				c.filters = append(c.filters[:i], c.filters[i+1:]...)
+				break
+			}
+		}
+		c.subMu.Unlock()
+	}
+	if p.err != nil {
+		return p.err
+	}
+	c.write(packetUnsubAck<<4, []byte{byte(id >> 8), byte(id)})
+	return nil
+}
+
+func (c *brokerConn) subscribed(topic string) bool {
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+	for _, f := range c.filters {
+		if topicMatches(f, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *brokerConn) publish(topic string, payload []byte) {
+	body := appendString(nil, topic)
+	body = append(body, payload...)
+	c.write(packetPublish<<4, body)
+}
+
+func (c *brokerConn) write(header byte, body []byte) {
+	pkt := []byte{header}
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		pkt = append(pkt, b)
+		if n == 0 {
+			break
+		}
+	}
+	pkt = append(pkt, body...)
+	c.writeMu.Lock()
+	c.conn.Write(pkt)
+	c.writeMu.Unlock()
+}
+
+// topicMatches reports whether topic matches filter, honouring the + (one
+// level) and # (all remaining levels) wildcards.
+func topicMatches(filter, topic string) bool {
+	fs := strings.Split(filter, "/")
+	ts := strings.Split(topic, "/")
+	for i, f := range fs {
+		if f == "#" {
+			return true
+		}
+		if i >= len(ts) {
+			return false
+		}
+		if f != "+" && f != ts[i] {
+			return false
+		}
+	}
+	return len(fs) == len(ts)
+}
+
+// readPacket reads one control packet, returning its fixed-header byte and
+// variable header plus payload.
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, mult := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, nil, errors.New("malformed remaining length")
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(b&0x7f) * mult
+		mult *= 128
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
+
+// packetReader consumes the fields of a packet body, recording the first
+// short read in err.
+type packetReader struct {
+	data []byte
+	err  error
+}
+
+func (p *packetReader) take(n int) []byte {
+	if p.err != nil {
+		return nil
+	}
+	if len(p.data) < n {
+		p.err = fmt.Errorf("packet truncated: need %d bytes, have %d", n, len(p.data))
+		return nil
+	}
+	out := p.data[:n]
+	p.data = p.data[n:]
+	return out
+}
+
+func (p *packetReader) byte() byte {
+	if b := p.take(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (p *packetReader) uint16() uint16 {
+	if b := p.take(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (p *packetReader) string() string {
+	n := p.uint16()
+	return string(p.take(int(n)))
+}
+
+func (p *packetReader) rest() []byte {
+	out := p.data
+	p.data = nil
+	return out
+}
+
+func appendString(b []byte, s string) []byte {
+	b = append(b, byte(len(s)>>8), byte(len(s)))
+	return append(b, s...)
+}
@@ -0,0 +1,123 @@
+package fakecloud
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// connect attaches a paho client to the broker, failing the test if it is
+// refused.
+func connect(t *testing.T, b *Broker, clientID string) mqtt.Client {
+	t.Helper()
+	client := mqtt.NewClient(mqtt.NewClientOptions().
+		AddBroker(b.URL()).
+		SetClientID(clientID).
+		SetUsername("user").
+		SetPassword("pass").
+		SetAutoReconnect(false))
+	if tok := client.Connect(); !tok.WaitTimeout(5*time.Second) || tok.Error() != nil {
+		t.Fatalf("connect %s: %v", clientID, tok.Error())
+	}
+	t.Cleanup(func() { client.Disconnect(0) })
+	return client
+}
+
+func TestBrokerDeliversToMatchingSubscribers(t *testing.T) {
+	b, err := NewBroker("127.0.0.1:0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	published := make(chan string, 10)
+	b.OnPublish = func(clientID, topic string, payload []byte) { published <- clientID + " " + topic }
+
+	sub := connect(t, b, "sub")
+	got := make(chan string, 10)
+	tok := sub.Subscribe("/sys/+/app/#", 0, func(_ mqtt.Client, m mqtt.Message) {
+		got <- m.Topic() + " " + string(m.Payload())
+	})
+	if !tok.WaitTimeout(5*time.Second) || tok.Error() != nil {
+		t.Fatalf("subscribe: %v", tok.Error())
+	}
+	pub := connect(t, b, "pub")
+	pub.Publish("/sys/pk/other/x", 1, false, "ignored").WaitTimeout(5 * time.Second)
+	pub.Publish("/sys/pk/app/down", 1, false, "hello").WaitTimeout(5 * time.Second)
+	b.Publish("/sys/pk/app/up/events", []byte("from the broker"))
+
+	for _, want := range []string{"/sys/pk/app/down hello", "/sys/pk/app/up/events from the broker"} {
+		select {
+		case m := <-got:
+			if m != want {
+				t.Errorf("received %q, want %q", m, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no delivery of %q", want)
+		}
+	}
+	if first := <-published; first != "pub /sys/pk/other/x" {
+		t.Errorf("OnPublish saw %q first", first)
+	}
+	ids := b.Clients()
+	slices.Sort(ids)
+	if !slices.Equal(ids, []string{"pub", "sub"}) {
+		t.Errorf("clients %v", ids)
+	}
+}
+
+func TestBrokerRefusesAndDropsClients(t *testing.T) {
+	b, err := NewBroker("127.0.0.1:0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	b.Authenticate = func(clientID, username, password string) bool { return password == "pass" }
+
+	bad := mqtt.NewClient(mqtt.NewClientOptions().AddBroker(b.URL()).SetClientID("bad").SetUsername("user").SetPassword("wrong"))
+	if tok := bad.Connect(); !tok.WaitTimeout(5*time.Second) || tok.Error() == nil {
+		t.Fatal("connect with a wrong password succeeded")
+	}
+
+	lost := make(chan error, 1)
+	client := mqtt.NewClient(mqtt.NewClientOptions().
+		AddBroker(b.URL()).
+		SetClientID("good").
+		SetUsername("user").
+		SetPassword("pass").
+		SetAutoReconnect(false).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) { lost <- err }))
+	if tok := client.Connect(); !tok.WaitTimeout(5*time.Second) || tok.Error() != nil {
+		t.Fatalf("connect: %v", tok.Error())
+	}
+	defer client.Disconnect(0)
+	b.DropClients()
+	select {
+	case <-lost:
+	case <-time.After(5 * time.Second):
+		t.Fatal("client not dropped")
+	}
+	// The broker still accepts new connections after dropping its clients.
+	connect(t, b, "again")
+}
+
+func TestTopicMatches(t *testing.T) {
+	for _, c := range []struct {
+		filter, topic string
+		want          bool
+	}{
+		{"a/b", "a/b", true},
+		{"a/b", "a/c", false},
+		{"a/+/c", "a/b/c", true},
+		{"a/+", "a/b/c", false},
+		{"a/#", "a/b/c", true},
+		{"a/#", "a", true},
+		{"#", "a/b", true},
+		{"a/b/c", "a/b", false},
+	} {
+		if got := topicMatches(c.filter, c.topic); got != c.want {
+			t.Errorf("topicMatches(%q, %q) = %v, want %v", c.filter, c.topic, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+// Package fakecloud is a local stand-in for the Mammotion account service and
+// the Aliyun IoT cloud: one HTTP server answering every API the login flow
+// uses, plus an embedded MQTT broker for device→app events. Point auth,
+// aliyuniot and mammotion at it and the full login → connect → command flow
+// runs offline, with each device's commands handed to a Go function.
+package fakecloud
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"mammo/aliyuniot"
+)
+
+// Options configures a Cloud.
+type Options struct {
+	// Username and Password are the only account credentials accepted.
+	Username string
+	Password string
+	// HTTPAddr and BrokerAddr default to 127.0.0.1:0 (a free port).
+	HTTPAddr   string
+	BrokerAddr string
+	// BrokerTLS, when set, makes the broker accept TLS connections only.
+	BrokerTLS *tls.Config
+}
+
+// Cloud is a running stand-in. The zero value is not usable; call Start.
+type Cloud struct {
+	Broker *Broker
+
+	opts Options
+	ln   net.Listener
+	srv  *http.Server
+
+	mu           sync.Mutex
+	productKey   string
+	deviceName   string
+	deviceSecret string
+	tokens       map[string]bool
+	refreshes    int
+	devices      []*device
+}
+
+type device struct {
+	info    aliyuniot.Device
+	handler func([]byte) error
+	invokes int
+}
+
+// Start brings up the HTTP API and the broker.
+func Start(opts Options) (*Cloud, error) {
+	if opts.HTTPAddr == "" {
+		opts.HTTPAddr = "127.0.0.1:0"
+	}
+	if opts.BrokerAddr == "" {
+		opts.BrokerAddr = "127.0.0.1:0"
+	}
+	ln, err := net.Listen("tcp", opts.HTTPAddr)
+	if err != nil {
+		return nil, err
+	}
+	broker, err := NewBroker(opts.BrokerAddr, opts.BrokerTLS)
+	if err != nil {
+		ln.Close()
+		return nil, err
+	}
+	c := &Cloud{
+		Broker:       broker,
+		opts:         opts,
+		ln:           ln,
+		productKey:   "fakeAppProduct",
+		deviceName:   "fake-app-" + randomHex(4),
+		deviceSecret: randomHex(16),
+		tokens:       make(map[string]bool),
+	}
+	broker.Authenticate = c.authenticate
+	c.srv = &http.Server{Handler: c.routes()}
+	go c.srv.Serve(ln)
+	return c, nil
+}
+
+// Host is the host:port serving every HTTP API.
+func (c *Cloud) Host() string {
+	return c.ln.Addr().String()
+}
+
+// URL is the HTTP base URL, for the account endpoints.
+func (c *Cloud) URL() string {
+	return "http://" + c.Host()
+}
+
+// Close shuts down the HTTP server and the broker.
+func (c *Cloud) Close() error {
+	c.srv.Close()
+	return c.Broker.Close()
+}
+
+// AddDevice binds a device to the account. Commands the app sends it through
+// /thing/service/invoke are passed to handler as raw LubaMsg bytes; the
+// returned emit publishes device→app LubaMsg bytes as a
+// device_protobuf_msg_event, the way the mower's reports arrive.
+func (c *Cloud) AddDevice(info aliyuniot.Device, handler func([]byte) error) (emit func([]byte)) {
+	c.mu.Lock()
+	c.devices = append(c.devices, &device{info: info, handler: handler})
+	c.mu.Unlock()
+	return func(data []byte) { c.emit(info, data) }
+}
+
+// Invokes returns how many commands were delivered to the device.
+func (c *Cloud) Invokes(iotID string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if d := c.deviceLocked(iotID); d != nil {
+		return d.invokes
+	}
+	return 0
+}
+
+// Refreshes returns how many checkOrRefreshSession calls succeeded.
+func (c *Cloud) Refreshes() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.refreshes
+}
+
+func (c *Cloud) deviceLocked(iotID string) *device {
+	for _, d := range c.devices {
+		if d.info.IotId == iotID {
+			return d
+		}
+	}
+	return nil
+}
+
+func (c *Cloud) emit(info aliyuniot.Device, data []byte) {
+	c.publishEvent(info, "device_protobuf_msg_event", map[string]any{"content": base64.StdEncoding.EncodeToString(data)})
+}
+
+// Event publishes a thing.events event other than a protobuf message for the
+// device, e.g. identifier device_warning_event with value {"code": 1005}.
+func (c *Cloud) Event(iotID, identifier string, value map[string]any) error {
+	c.mu.Lock()
+	d := c.deviceLocked(iotID)
+	c.mu.Unlock()
+	if d == nil {
+		return fmt.Errorf("fakecloud: no device %q", iotID)
+	}
+	c.publishEvent(d.info, identifier, value)
+	return nil
+}
+
+func (c *Cloud) publishEvent(info aliyuniot.Device, identifier string, value map[string]any) {
+	c.mu.Lock()
+	topic := fmt.Sprintf("/sys/%s/%s/app/down/thing/events", c.productKey, c.deviceName)
+	c.mu.Unlock()
+	now := time.Now().UnixMilli()
+	payload, err := json.Marshal(map[string]interface{}{
+		"method":  "thing.events",
+		"id":      randomHex(8),
+		"version": "1.0",
+		"params": map[string]interface{}{
+			"identifier": identifier,
+			"type":       "info",
+			"iotId":      info.IotId,
+			"productKey": info.ProductKey,
+			"deviceName": info.DeviceName,
+			"time":       now,
+			"gmtCreate":  now,
+			"value":      value,
+		},
+	})
+	if err != nil {
+		log.Printf("fakecloud: encoding event: %v", err)
+		return
+	}
+	c.Broker.Publish(topic, payload)
+}
+
+// authenticate checks an MQTT CONNECT against the credentials issued by
+// aepauth, using the same HMAC-SHA1 signing as the Aliyun broker.
+func (c *Cloud) authenticate(clientID, username, password string) bool {
+	c.mu.Lock()
+	pk, dn, secret := c.productKey, c.deviceName, c.deviceSecret
+	c.mu.Unlock()
+	if username != dn+"&"+pk {
+		return false
+	}
+	id := clientID
+	if i := strings.Index(id, "|"); i >= 0 {
+		id = id[:i]
+	}
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte("clientId" + id + "deviceName" + dn + "productKey" + pk))
+	return hmac.Equal([]byte(password), []byte(fmt.Sprintf("%02x", mac.Sum(nil))))
+}
+
+// SelfSignedTLS returns a server config with a fresh certificate for
+// 127.0.0.1 and localhost, plus the certificate as PEM for clients to trust.
+func SelfSignedTLS() (*tls.Config, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fakecloud"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:              []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return &tls.Config{Certificates: []tls.Certificate{cert}}, certPEM, nil
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return fmt.Sprintf("%x", b)
+}
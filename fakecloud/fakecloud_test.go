@@ -0,0 +1,134 @@
+package fakecloud
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"mammo/aliyuniot"
+)
+
+// post sends an IoT API gateway request and decodes the reply.
+func post(t *testing.T, c *Cloud, path, token string, params map[string]any) map[string]any {
+	t.Helper()
+	body, _ := json.Marshal(map[string]any{
+		"id":      "req-1",
+		"params":  params,
+		"request": map[string]string{"iotToken": token},
+	})
+	resp, err := http.Post(c.URL()+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var out map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	return out
+}
+
+func TestCloudLoginAndInvoke(t *testing.T) {
+	c, err := Start(Options{Username: "me@example.com", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	var got [][]byte
+	c.AddDevice(aliyuniot.Device{IotId: "iot-1", DeviceName: "Luba-SIM"}, func(data []byte) error {
+		got = append(got, data)
+		if len(got) > 1 {
+			return errors.New("busy")
+		}
+		return nil
+	})
+
+	for _, pass := range []string{"wrong", "secret"} {
+		resp, err := http.Post(c.URL()+"/oauth/token?username=me@example.com&password="+pass, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var login struct{ Code int }
+		json.NewDecoder(resp.Body).Decode(&login)
+		resp.Body.Close()
+		if want := map[string]int{"wrong": 1, "secret": 0}[pass]; login.Code != want {
+			t.Errorf("login with %q: code %d, want %d", pass, login.Code, want)
+		}
+	}
+
+	session := post(t, c, "/account/createSessionByAuthCode", "", nil)
+	token, _ := session["data"].(map[string]any)["iotToken"].(string)
+	if token == "" {
+		t.Fatalf("session %v", session)
+	}
+	invoke := func(token, iotID string) float64 {
+		return post(t, c, "/thing/service/invoke", token, map[string]any{
+			"iotId": iotID,
+			"args":  map[string]any{"content": base64.StdEncoding.EncodeToString([]byte{7, 8})},
+		})["code"].(float64)
+	}
+	if code := invoke("stale", "iot-1"); code != 401 {
+		t.Errorf("invoke with an unknown token: code %v", code)
+	}
+	if code := invoke(token, "iot-2"); code != 6205 {
+		t.Errorf("invoke an unknown device: code %v", code)
+	}
+	if code := invoke(token, "iot-1"); code != 200 || len(got) != 1 || !bytes.Equal(got[0], []byte{7, 8}) {
+		t.Errorf("invoke: code %v, device got %v", code, got)
+	}
+	if code := invoke(token, "iot-1"); code != 500 {
+		t.Errorf("invoke the device refuses: code %v", code)
+	}
+	if n := c.Invokes("iot-1"); n != 2 {
+		t.Errorf("%d invokes, want 2", n)
+	}
+
+	refresh := func(refreshToken string) map[string]any {
+		return post(t, c, "/account/checkOrRefreshSession", "", map[string]any{
+			"request": map[string]any{"refreshToken": refreshToken},
+		})
+	}
+	if code := refresh("other")["code"].(float64); code != 2401 {
+		t.Errorf("refresh with a bad token: code %v", code)
+	}
+	fresh, _ := refresh("session-refresh")["data"].(map[string]any)["iotToken"].(string)
+	if fresh == "" || fresh == token || c.Refreshes() != 1 {
+		t.Fatalf("refresh gave token %q after %d refreshes", fresh, c.Refreshes())
+	}
+	// Earlier tokens stay valid after a refresh.
+	devices := post(t, c, "/uc/listBindingByAccount", token, nil)
+	if total := devices["data"].(map[string]any)["total"].(float64); total != 1 {
+		t.Errorf("listed %v devices, want 1", total)
+	}
+	if err := c.Event("iot-2", "device_warning_event", nil); err == nil {
+		t.Error("event for an unknown device succeeded")
+	}
+}
+
+func TestCloudAuthenticatesBrokerClients(t *testing.T) {
+	c, err := Start(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	aep := post(t, c, "/app/aepauth/handle", "", nil)["data"].(map[string]any)
+	pk, dn, secret := aep["productKey"].(string), aep["deviceName"].(string), aep["deviceSecret"].(string)
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte("clientIdclient-1deviceName" + dn + "productKey" + pk))
+	password := hex.EncodeToString(mac.Sum(nil))
+	if !c.authenticate("client-1|securemode=2|", dn+"&"+pk, password) {
+		t.Error("correctly signed client refused")
+	}
+	if c.authenticate("client-2|securemode=2|", dn+"&"+pk, password) {
+		t.Error("password signed for another client id accepted")
+	}
+	if c.authenticate("client-1", "other&"+pk, password) {
+		t.Error("other device name accepted")
+	}
+}
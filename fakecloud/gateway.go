@@ -0,0 +1,237 @@
+package fakecloud
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+)
+
+// apiRequest is the body the IoT API gateway client posts.
+type apiRequest struct {
+	ID      string                 `json:"id"`
+	Params  map[string]interface{} `json:"params"`
+	Request struct {
+		IotToken string `json:"iotToken"`
+	} `json:"request"`
+}
+
+func (c *Cloud) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	// Mammotion account service.
+	mux.HandleFunc("/oauth/token", c.handleLogin)
+	// Aliyun open account and IoT API gateway.
+	mux.HandleFunc("/living/account/region/get", c.handleRegion)
+	mux.HandleFunc("/api/prd/connect.json", c.handleConnect)
+	mux.HandleFunc("/api/prd/loginbyoauth.json", c.handleLoginByOAuth)
+	mux.HandleFunc("/app/aepauth/handle", c.handleAep)
+	mux.HandleFunc("/account/createSessionByAuthCode", c.handleSession)
+	mux.HandleFunc("/account/checkOrRefreshSession", c.handleRefresh)
+	mux.HandleFunc("/uc/listBindingByAccount", c.handleListDevices)
+	mux.HandleFunc("/thing/service/invoke", c.handleInvoke)
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func readAPIRequest(w http.ResponseWriter, r *http.Request) (*apiRequest, bool) {
+	var req apiRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, map[string]interface{}{"code": 400, "message": "bad request: " + err.Error()})
+		return nil, false
+	}
+	return &req, true
+}
+
+func (c *Cloud) handleLogin(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("username") != c.opts.Username || q.Get("password") != c.opts.Password {
+		writeJSON(w, map[string]interface{}{"code": 1, "msg": "account or password error"})
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"code": 0,
+		"msg":  "Request success",
+		"data": map[string]interface{}{
+			"access_token":       "access-" + randomHex(8),
+			"authorization_code": "authcode-" + randomHex(8),
+			"refresh_token":      "refresh-" + randomHex(8),
+			"expires_in":         3600,
+			"userInformation": map[string]interface{}{
+				"areaCode":           "local",
+				"authType":           "0",
+				"domainAbbreviation": "LOCAL",
+				"email":              c.opts.Username,
+				"userAccount":        c.opts.Username,
+				"userId":             "1",
+			},
+		},
+	})
+}
+
+func (c *Cloud) handleRegion(w http.ResponseWriter, r *http.Request) {
+	if _, ok := readAPIRequest(w, r); !ok {
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"code": 200,
+		"data": map[string]string{
+			"apiGatewayEndpoint":   c.Host(),
+			"oaApiGatewayEndpoint": c.Host(),
+			"mqttEndpoint":         c.Broker.URL(),
+			"regionId":             "local",
+		},
+	})
+}
+
+func (c *Cloud) handleConnect(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]interface{}{
+		"data": map[string]interface{}{
+			"vid": "vid-" + randomHex(8),
+			"data": map[string]interface{}{
+				"device": map[string]interface{}{
+					"data": map[string]string{"deviceId": "device-" + randomHex(8)},
+				},
+			},
+		},
+	})
+}
+
+func (c *Cloud) handleLoginByOAuth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]interface{}{
+		"api": "loginbyoauth",
+		"data": map[string]interface{}{
+			"code": 1,
+			"data": map[string]interface{}{
+				"loginSuccessResult": map[string]interface{}{
+					"sid":          "sid-" + randomHex(8),
+					"token":        "token-" + randomHex(8),
+					"refreshToken": "refresh-" + randomHex(8),
+					"sidExpireIn":  7200,
+				},
+			},
+		},
+	})
+}
+
+func (c *Cloud) handleAep(w http.ResponseWriter, r *http.Request) {
+	if _, ok := readAPIRequest(w, r); !ok {
+		return
+	}
+	c.mu.Lock()
+	data := map[string]string{
+		"productKey":   c.productKey,
+		"deviceName":   c.deviceName,
+		"deviceSecret": c.deviceSecret,
+	}
+	c.mu.Unlock()
+	writeJSON(w, map[string]interface{}{"code": 200, "data": data})
+}
+
+// issueSessionLocked hands out a fresh iotToken. Earlier tokens stay valid, as
+// they do on the real gateway until they expire.
+func (c *Cloud) issueSessionLocked() map[string]interface{} {
+	token := "iot-" + randomHex(8)
+	c.tokens[token] = true
+	return map[string]interface{}{
+		"identityId":         "identity-1",
+		"refreshToken":       "session-refresh",
+		"refreshTokenExpire": 720000,
+		"iotToken":           token,
+		"iotTokenExpire":     72000,
+	}
+}
+
+func (c *Cloud) handleSession(w http.ResponseWriter, r *http.Request) {
+	if _, ok := readAPIRequest(w, r); !ok {
+		return
+	}
+	c.mu.Lock()
+	data := c.issueSessionLocked()
+	c.mu.Unlock()
+	writeJSON(w, map[string]interface{}{"code": 200, "data": data})
+}
+
+func (c *Cloud) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	req, ok := readAPIRequest(w, r)
+	if !ok {
+		return
+	}
+	inner, _ := req.Params["request"].(map[string]interface{})
+	if inner["refreshToken"] != "session-refresh" {
+		writeJSON(w, map[string]interface{}{"code": 2401, "msg": "refreshToken invalid"})
+		return
+	}
+	c.mu.Lock()
+	c.refreshes++
+	data := c.issueSessionLocked()
+	c.mu.Unlock()
+	writeJSON(w, map[string]interface{}{"code": 200, "data": data})
+}
+
+func (c *Cloud) handleListDevices(w http.ResponseWriter, r *http.Request) {
+	req, ok := readAPIRequest(w, r)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.tokens[req.Request.IotToken] {
+		writeJSON(w, map[string]interface{}{"code": 401, "message": "iotToken invalid"})
+		return
+	}
+	var list []interface{}
+	for _, d := range c.devices {
+		list = append(list, d.info)
+	}
+	writeJSON(w, map[string]interface{}{
+		"code": 200,
+		"id":   req.ID,
+		"data": map[string]interface{}{
+			"data":     list,
+			"pageNo":   1,
+			"pageSize": 100,
+			"total":    len(list),
+		},
+	})
+}
+
+func (c *Cloud) handleInvoke(w http.ResponseWriter, r *http.Request) {
+	req, ok := readAPIRequest(w, r)
+	if !ok {
+		return
+	}
+	iotID, _ := req.Params["iotId"].(string)
+	args, _ := req.Params["args"].(map[string]interface{})
+	content, _ := args["content"].(string)
+	data, err := base64.StdEncoding.DecodeString(content)
+	if err != nil {
+		writeJSON(w, map[string]interface{}{"code": 400, "message": "content is not base64"})
+		return
+	}
+
+	c.mu.Lock()
+	valid := c.tokens[req.Request.IotToken]
+	d := c.deviceLocked(iotID)
+	if valid && d != nil {
+		d.invokes++
+	}
+	c.mu.Unlock()
+	switch {
+	case !valid:
+		writeJSON(w, map[string]interface{}{"code": 401, "message": "iotToken invalid"})
+		return
+	case d == nil:
+		writeJSON(w, map[string]interface{}{"code": 6205, "message": "device not found"})
+		return
+	}
+	if d.handler != nil {
+		if err := d.handler(data); err != nil {
+			writeJSON(w, map[string]interface{}{"code": 500, "message": err.Error()})
+			return
+		}
+	}
+	writeJSON(w, map[string]interface{}{"code": 200, "id": req.ID, "data": map[string]string{"messageId": req.ID}})
+}
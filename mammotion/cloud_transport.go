@@ -0,0 +1,87 @@
+package mammotion
+
+import (
+	"sync"
+
+	aliyuniot "mammo/aliyuniot"
+)
+
+// CloudTransport carries LubaMsg bytes over the Aliyun IoT cloud: commands go
+// out through the gateway's SendCloudCommand and device events arrive on the
+// MQTT thing/events topic, unwrapped by the MammotionBaseCloudDevice.
+type CloudTransport struct {
+	gateway *aliyuniot.CloudIOTGateway
+	cloud   *MammotionCloud
+	iotID   string
+	subs    subscriberSet
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewCloudTransport wraps a connected cloud device. Every protobuf event the
+// device receives is passed to subscribers.
+func NewCloudTransport(gateway *aliyuniot.CloudIOTGateway, cloud *MammotionCloud, device *MammotionBaseCloudDevice) *CloudTransport {
+	t := &CloudTransport{
+		gateway: gateway,
+		cloud:   cloud,
+		iotID:   device.device.iotDevice.IotId,
+	}
+	device.rawDataEvent.AddSubscriber(func(v interface{}) {
+		data, ok := v.([]byte)
+		if !ok || t.State() == TransportClosed {
+			return
+		}
+		t.subs.deliver(data)
+	})
+	return t
+}
+
+func (t *CloudTransport) Send(data []byte) error {
+	if t.State() == TransportClosed {
+		return ErrTransportClosed
+	}
+	_, err := t.gateway.SendCloudCommand(t.iotID, data)
+	t.cloud.recordCommand(err)
+	return err
+}
+
+func (t *CloudTransport) Subscribe(fn func([]byte)) func() {
+	return t.subs.add(fn)
+}
+
+// Refresh renews the cloud session token. Long-running senders (continuous
+// driving) call it before commands so the session can't lapse mid-drive.
+func (t *CloudTransport) Refresh() error {
+	return t.gateway.CheckOrRefreshSession()
+}
+
+// Stats returns the MQTT and command counters of the underlying cloud.
+func (t *CloudTransport) Stats() CloudStats {
+	return t.cloud.Stats()
+}
+
+func (t *CloudTransport) Close() error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	t.mu.Unlock()
+	t.cloud.Disconnect()
+	return nil
+}
+
+func (t *CloudTransport) State() TransportState {
+	t.mu.Lock()
+	closed := t.closed
+	t.mu.Unlock()
+	switch {
+	case closed:
+		return TransportClosed
+	case t.cloud.IsConnected():
+		return TransportConnected
+	}
+	return TransportDisconnected
+}
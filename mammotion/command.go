@@ -317,6 +317,34 @@ func GetSVGData(hash int64) ([]byte, error) {
 	return proto.Marshal(lubaMsg)
 }
 
+// GetAreaNameList requests the table of user-given zone names (the names set
+// in the phone app). The device answers with toapp_all_hash_name.
+func GetAreaNameList(deviceID string) ([]byte, error) {
+	mctlNav := &pb.MctlNav{
+		SubNavMsg: &pb.MctlNav_ToappAllHashName{
+			ToappAllHashName: &pb.AppGetAllAreaHashName{
+				DeviceId: deviceID,
+			},
+		},
+	}
+
+	lubaMsg := &pb.LubaMsg{
+		Msgtype:    pb.MsgCmdType_MSG_CMD_TYPE_NAV,
+		Sender:     pb.MsgDevice_DEV_MOBILEAPP,
+		Rcver:      pb.MsgDevice_DEV_MAINCTL,
+		Msgattr:    pb.MsgAttr_MSG_ATTR_REQ,
+		Seqs:       1,
+		Version:    1,
+		Subtype:    1,
+		Timestamp:  uint64(time.Now().UnixMilli()),
+		LubaSubMsg: &pb.LubaMsg_Nav{
+			Nav: mctlNav,
+		},
+	}
+
+	return proto.Marshal(lubaMsg)
+}
+
 // GetCommDataWithParams requests map data with custom parameters
 func GetCommDataWithParams(action int32, dataType int32, hash int64, subCmd int32) ([]byte, error) {
 	navGetCommData := &pb.NavGetCommData{
@@ -7,6 +7,7 @@ import (
 	"log"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	aliyuniot "mammo/aliyuniot"
@@ -27,6 +28,41 @@ type MammotionCloud struct {
 	onConnectedEvent    DataEvent
 	operationLock       sync.Mutex
 	mqttClient          *MammotionMQTT
+
+	connects, disconnects   atomic.Uint64
+	messages, messageErrors atomic.Uint64
+	commands, commandErrors atomic.Uint64
+}
+
+// CloudStats counts MQTT connection events, received messages and cloud
+// command results since the MammotionCloud was created.
+type CloudStats struct {
+	Connects      uint64
+	Disconnects   uint64
+	Messages      uint64 // MQTT messages received
+	MessageErrors uint64 // received messages that failed to parse
+	Commands      uint64 // commands sent through the IoT gateway
+	CommandErrors uint64 // commands the gateway failed or rejected
+}
+
+// Stats returns a snapshot of the counters.
+func (mc *MammotionCloud) Stats() CloudStats {
+	return CloudStats{
+		Connects:      mc.connects.Load(),
+		Disconnects:   mc.disconnects.Load(),
+		Messages:      mc.messages.Load(),
+		MessageErrors: mc.messageErrors.Load(),
+		Commands:      mc.commands.Load(),
+		CommandErrors: mc.commandErrors.Load(),
+	}
+}
+
+// recordCommand counts one command sent through the gateway.
+func (mc *MammotionCloud) recordCommand(err error) {
+	mc.commands.Add(1)
+	if err != nil {
+		mc.commandErrors.Add(1)
+	}
 }
 
 type Command struct {
@@ -76,14 +112,17 @@ func (mc *MammotionCloud) ConnectAsync() {
 }
 
 func (mc *MammotionCloud) SendCommand(iotID string, command []byte) {
-	mc.mqttClient.GetCloudClient().SendCloudCommand(iotID, command)
+	_, err := mc.mqttClient.GetCloudClient().SendCloudCommand(iotID, command)
+	mc.recordCommand(err)
 }
 
 func (mc *MammotionCloud) onConnected() {
+	mc.connects.Add(1)
 	mc.onConnectedEvent.Trigger(nil)
 }
 
 func (mc *MammotionCloud) onDisconnected() {
+	mc.disconnects.Add(1)
 	mc.onDisconnectedEvent.Trigger(nil)
 }
 
@@ -101,7 +140,8 @@ func (mc *MammotionCloud) executeCommandLocked(cmd Command) {
 	defer mc.operationLock.Unlock()
 
 	log.Printf("Sending command: %s", cmd.key)
-	mc.mqttClient.GetCloudClient().SendCloudCommand(cmd.iotID, cmd.command)
+	_, err := mc.mqttClient.GetCloudClient().SendCloudCommand(cmd.iotID, cmd.command)
+	mc.recordCommand(err)
 
 	future := NewMammotionFuture(cmd.iotID)
 	mc.waitingQueue.PushBack(future)
@@ -117,6 +157,7 @@ func (mc *MammotionCloud) executeCommandLocked(cmd Command) {
 }
 
 func (mc *MammotionCloud) onMQTTMessage(topic string, payload []byte, iotID string) {
+	mc.messages.Add(1)
 	if mc.waitingQueue.Len() > 0 {
 		fut := mc.DequeueByIotID(iotID)
 		if fut != nil {
@@ -127,6 +168,7 @@ func (mc *MammotionCloud) onMQTTMessage(topic string, payload []byte, iotID stri
 	var payloadMap map[string]interface{}
 	if err := json.Unmarshal(payload, &payloadMap); err != nil {
 		log.Printf("Error unmarshalling payload: %v", err)
+		mc.messageErrors.Add(1)
 		return
 	}
 
@@ -137,6 +179,7 @@ func (mc *MammotionCloud) onMQTTMessage(topic string, payload []byte, iotID stri
 			mc.mqttMessageEvent.Trigger(eventMsg)
 		} else {
 			log.Printf("Error parsing ThingEventMessage: %v", err)
+			mc.messageErrors.Add(1)
 		}
 	}
 
@@ -191,6 +234,7 @@ func (mc *MammotionCloud) parseMQTTResponse(topic string, payload map[string]int
 							decodedData, err := base64.StdEncoding.DecodeString(content)
 							if err != nil {
 								log.Printf("Error decoding base64 protobuf: %v", err)
+								mc.messageErrors.Add(1)
 								return
 							}
 
@@ -199,6 +243,7 @@ func (mc *MammotionCloud) parseMQTTResponse(topic string, payload map[string]int
 							err = proto.Unmarshal(decodedData, &lubaMsg)
 							if err != nil {
 								log.Printf("Error unmarshalling protobuf: %v", err)
+								mc.messageErrors.Add(1)
 								return
 							}
 
@@ -23,6 +23,7 @@ type MammotionBaseCloudDevice struct {
 	commands            *MammotionCommand
 	currentID           string
 	operationLock       sync.Mutex
+	rawDataEvent        DataEvent
 }
 
 func NewMammotionBaseCloudDevice(mqtt *MammotionCloud, device *MowingDevice, stateManager *StateManager) *MammotionBaseCloudDevice {
@@ -32,6 +33,7 @@ func NewMammotionBaseCloudDevice(mqtt *MammotionCloud, device *MowingDevice, sta
 		stateManager:   stateManager,
 		commandFutures: make(map[string]chan []byte),
 		commands:       NewMammotionCommand(device.iotDevice.DeviceName),
+		rawDataEvent:   NewDataEvent(),
 	}
 
 	device.mqttMessageEvent.AddSubscriber(mbcd.parseMessageForDevice)
@@ -146,6 +148,16 @@ func (mbcd *MammotionBaseCloudDevice) parseMessageForDevice(event interface{}) {
 		deviceName = params.DeviceName
 		productKey = params.ProductKey
 		valueContent = params.Value.Content
+	case mqtt.DeviceWarningEventParams:
+		if params.IotId == mbcd.device.iotDevice.IotId && mbcd.stateManager.OnDeviceEvent != nil {
+			mbcd.stateManager.OnDeviceEvent(&DeviceEvent{Identifier: params.Identifier, Code: params.Value.Code})
+		}
+		return
+	case mqtt.DeviceNotificationEventParams:
+		if params.IotId == mbcd.device.iotDevice.IotId && mbcd.stateManager.OnDeviceEvent != nil {
+			mbcd.stateManager.OnDeviceEvent(&DeviceEvent{Identifier: params.Identifier, Data: params.Value.Data})
+		}
+		return
 	default:
 		// try to get general params
 		if generalParams, ok := thingEventMessage.Params.(mqtt.GeneralParams); ok {
@@ -174,16 +186,40 @@ func (mbcd *MammotionBaseCloudDevice) parseMessageForDevice(event interface{}) {
 	}
 	mbcd.updateRawData(binaryData)
 
-	// Parse the protobuf message
+	if mbcd.commands.GetDeviceProductKey() == "" && mbcd.commands.GetDeviceName() == deviceName {
+		mbcd.commands.SetDeviceProductKey(productKey)
+	}
+	if mbcd.mqtt.waitingQueue.Len() > 0 {
+		fut := mbcd.mqtt.DequeueByIotID(mbcd.device.iotDevice.IotId)
+		if fut != nil {
+			fut.Resolve(binaryData)
+		}
+	}
+
+	// Still call the placeholder Notification for compatibility
+	newMsg := LubaMsg{}
+	newMsg.Parse(binaryData)
+	mbcd.stateManager.Notification(&newMsg)
+}
+
+// HandleLubaMsg decodes one device→app LubaMsg and fires the matching
+// callbacks. It is the whole inbound protocol path below the MQTT envelope, so
+// anything that produces raw LubaMsg bytes (the cloud, a simulator, a
+// recording) can drive the state manager.
+func (sm *StateManager) HandleLubaMsg(data []byte) error {
 	var lubaMsg pb.LubaMsg
-	err = proto.Unmarshal(binaryData, &lubaMsg)
-	if err != nil {
-		log.Printf("Error parsing protobuf message: %v", err)
-		return
+	if err := proto.Unmarshal(data, &lubaMsg); err != nil {
+		return err
 	}
 
 	// Extract battery data and position from system messages
 	if sys := lubaMsg.GetSys(); sys != nil {
+		if errCode := sys.GetToappErrCode(); errCode != nil {
+			log.Printf("DEBUG: ErrCode %d", errCode.GetErrorCode())
+			if sm.OnErrorCode != nil {
+				sm.OnErrorCode(errCode.GetErrorCode())
+			}
+		}
 		if reportData := sys.GetToappReportData(); reportData != nil {
 			// Extract battery level + full dev status (for diagnostics)
 			if devStatus := reportData.GetDev(); devStatus != nil {
@@ -193,17 +229,50 @@ func (mbcd *MammotionBaseCloudDevice) parseMessageForDevice(event interface{}) {
 					devStatus.GetSensorStatus(), devStatus.GetLastStatus(), devStatus.GetVslamStatus())
 				if lock := devStatus.GetLockState(); lock != nil {
 					log.Printf("DEBUG: LockState %+v", lock)
+					if sm.OnLockState != nil {
+						sm.OnLockState(lock.GetLockState())
+					}
 				}
-				mbcd.stateManager.UpdateBatteryFromProtobuf(batteryLevel)
-				if mbcd.stateManager.OnDeviceStatus != nil {
-					mbcd.stateManager.OnDeviceStatus(devStatus.GetSysStatus(), devStatus.GetChargeState())
+				sm.UpdateBatteryFromProtobuf(batteryLevel)
+				if sm.OnDeviceStatus != nil {
+					sm.OnDeviceStatus(devStatus.GetSysStatus(), devStatus.GetChargeState())
 				}
 			}
 			if workState := reportData.GetWork(); workState != nil {
 				log.Printf("DEBUG: WorkState %+v", workState)
+				if sm.OnWorkReport != nil {
+					sm.OnWorkReport(extractWork(workState))
+				}
 			}
 			if rtk := reportData.GetRtk(); rtk != nil {
 				log.Printf("DEBUG: RTK %+v", rtk)
+				if sm.OnRtkReport != nil {
+					sm.OnRtkReport(&RtkData{
+						Status:      rtk.GetStatus(),
+						PosLevel:    rtk.GetPosLevel(),
+						GpsStars:    rtk.GetGpsStars(),
+						L2Stars:     rtk.GetL2Stars(),
+						CoViewStars: rtk.GetCoViewStars(),
+						Age:         rtk.GetAge(),
+						LatStd:      rtk.GetLatStd(),
+						LonStd:      rtk.GetLonStd(),
+					})
+				}
+			}
+			if maintain := reportData.GetMaintain(); maintain != nil && sm.OnMaintainReport != nil {
+				sm.OnMaintainReport(&MaintainData{
+					Mileage:   maintain.GetMileage(),
+					WorkTime:  maintain.GetWorkTime(),
+					BatCycles: maintain.GetBatCycles(),
+				})
+			}
+			if conn := reportData.GetConnect(); conn != nil && sm.OnConnectReport != nil {
+				sm.OnConnectReport(&ConnectData{
+					ConnectType: conn.GetConnectType(),
+					BleRssi:     conn.GetBleRssi(),
+					WifiRssi:    conn.GetWifiRssi(),
+					MnetRssi:    conn.GetMnetRssi(),
+				})
 			}
 
 			// Extract position data
@@ -217,8 +286,8 @@ func (mbcd *MammotionBaseCloudDevice) parseMessageForDevice(event interface{}) {
 
 				log.Printf("DEBUG: Position update - X=%.0f Y=%.0f Angle=%d PosType=%d", x, y, angle, posType)
 
-				if mbcd.stateManager.OnPositionUpdate != nil {
-					mbcd.stateManager.OnPositionUpdate(x, y, angle, posType)
+				if sm.OnPositionUpdate != nil {
+					sm.OnPositionUpdate(x, y, angle, posType)
 				}
 			}
 		}
@@ -232,8 +301,8 @@ func (mbcd *MammotionBaseCloudDevice) parseMessageForDevice(event interface{}) {
 				Hashes: hashListAck.GetDataCouple(),
 			}
 
-			if mbcd.stateManager.OnHashListReceived != nil {
-				mbcd.stateManager.OnHashListReceived(hashListData)
+			if sm.OnHashListReceived != nil {
+				sm.OnHashListReceived(hashListData)
 			}
 		}
 
@@ -250,15 +319,31 @@ func (mbcd *MammotionBaseCloudDevice) parseMessageForDevice(event interface{}) {
 				AreaLabel:    commonDataAck.GetAreaLabel().GetLabel(),
 			}
 
-			if mbcd.stateManager.OnMapDataReceived != nil {
-				mbcd.stateManager.OnMapDataReceived(mapData)
+			if sm.OnMapDataReceived != nil {
+				sm.OnMapDataReceived(mapData)
 			}
 		}
 
 		// Extract charge pile (dock) position
 		if chgPile := nav.GetToappChgpileto(); chgPile != nil {
-			if mbcd.stateManager.OnChargePilePosition != nil {
-				mbcd.stateManager.OnChargePilePosition(chgPile.GetToward(), chgPile.GetX(), chgPile.GetY())
+			if sm.OnChargePilePosition != nil {
+				sm.OnChargePilePosition(chgPile.GetToward(), chgPile.GetX(), chgPile.GetY())
+			}
+		}
+
+		// Extract the interrupted-job breakpoint
+		if bp := nav.GetToappBp(); bp != nil {
+			log.Printf("DEBUG: BreakPoint x=%.2f y=%.2f toward=%d flag=%d action=%d zone=%d",
+				bp.GetX(), bp.GetY(), bp.GetToward(), bp.GetFlag(), bp.GetAction(), bp.GetZoneHash())
+			if sm.OnBreakPointReceived != nil {
+				sm.OnBreakPointReceived(&BreakPointData{
+					X:        float64(bp.GetX()),
+					Y:        float64(bp.GetY()),
+					Toward:   bp.GetToward(),
+					Flag:     bp.GetFlag(),
+					Action:   bp.GetAction(),
+					ZoneHash: bp.GetZoneHash(),
+				})
 			}
 		}
 
@@ -267,8 +352,8 @@ func (mbcd *MammotionBaseCloudDevice) parseMessageForDevice(event interface{}) {
 			log.Printf("DEBUG: ZigZag frame job=%d zone=%d/%d frame=%d/%d points=%d",
 				zz.GetJobId(), zz.GetCurrentZone(), zz.GetTotalZoneNum(),
 				zz.GetCurrentFrame(), zz.GetTotalFrame(), len(zz.GetDataCouple()))
-			if mbcd.stateManager.OnZigZagReceived != nil {
-				mbcd.stateManager.OnZigZagReceived(&ZigZagData{
+			if sm.OnZigZagReceived != nil {
+				sm.OnZigZagReceived(&ZigZagData{
 					JobId:        zz.GetJobId(),
 					CurrentZone:  zz.GetCurrentZone(),
 					TotalZoneNum: zz.GetTotalZoneNum(),
@@ -280,22 +365,76 @@ func (mbcd *MammotionBaseCloudDevice) parseMessageForDevice(event interface{}) {
 				})
 			}
 		}
-	}
 
-	if mbcd.commands.GetDeviceProductKey() == "" && mbcd.commands.GetDeviceName() == deviceName {
-		mbcd.commands.SetDeviceProductKey(productKey)
-	}
-	if mbcd.mqtt.waitingQueue.Len() > 0 {
-		fut := mbcd.mqtt.DequeueByIotID(mbcd.device.iotDevice.IotId)
-		if fut != nil {
-			fut.Resolve(binaryData)
+		// Extract user-given zone names
+		if names := nav.GetToappAllHashName(); names != nil {
+			log.Printf("DEBUG: AreaHashName table device=%s entries=%d", names.GetDeviceId(), len(names.GetHashnames()))
+			if sm.OnAreaNamesReceived != nil {
+				data := &AreaNameData{DeviceID: names.GetDeviceId(), Names: make(map[int64]string)}
+				for _, hn := range names.GetHashnames() {
+					data.Names[hn.GetHash()] = hn.GetName()
+				}
+				sm.OnAreaNamesReceived(data)
+			}
+		}
+
+		// Extract SVG pattern overlay frames
+		if svgAck := nav.GetToappSvgMsg(); svgAck != nil {
+			svg := svgAck.GetSvgMessage()
+			log.Printf("DEBUG: SVG frame hash=%d frame=%d/%d result=%d file=%q bytes=%d",
+				svgAck.GetDataHash(), svgAck.GetCurrentFrame(), svgAck.GetTotalFrame(),
+				svgAck.GetResult(), svg.GetSvgFileName(), len(svg.GetSvgFileData()))
+			if sm.OnSvgReceived != nil {
+				sm.OnSvgReceived(&SvgData{
+					Hash:          svgAck.GetDataHash(),
+					TotalFrame:    svgAck.GetTotalFrame(),
+					CurrentFrame:  svgAck.GetCurrentFrame(),
+					Type:          svgAck.GetType(),
+					Result:        svgAck.GetResult(),
+					XMove:         svg.GetXMove(),
+					YMove:         svg.GetYMove(),
+					Scale:         svg.GetScale(),
+					Rotate:        svg.GetRotate(),
+					BaseWidthM:    svg.GetBaseWidthM(),
+					BaseHeightM:   svg.GetBaseHeightM(),
+					BaseWidthPix:  svg.GetBaseWidthPix(),
+					BaseHeightPix: svg.GetBaseHeightPix(),
+					Hidden:        svg.GetHideSvg(),
+					FileName:      svg.GetSvgFileName(),
+					FileData:      svg.GetSvgFileData(),
+				})
+			}
+		}
+
+		// Extract the local costmap (what the mower considers blocked)
+		if cm := nav.GetToappCostmap(); cm != nil {
+			log.Printf("DEBUG: Costmap %dx%d res=%.3f centre=(%.2f,%.2f) yaw=%.3f",
+				cm.GetWidth(), cm.GetHeight(), cm.GetRes(), cm.GetCenterX(), cm.GetCenterY(), cm.GetYaw())
+			if sm.OnCostmapReceived != nil {
+				sm.OnCostmapReceived(&CostmapData{
+					Width:   cm.GetWidth(),
+					Height:  cm.GetHeight(),
+					CenterX: cm.GetCenterX(),
+					CenterY: cm.GetCenterY(),
+					Yaw:     cm.GetYaw(),
+					Res:     cm.GetRes(),
+					Cells:   cm.GetCostmap(),
+				})
+			}
 		}
 	}
 
-	// Still call the placeholder Notification for compatibility
-	newMsg := LubaMsg{}
-	newMsg.Parse(binaryData)
-	mbcd.stateManager.Notification(&newMsg)
+	// Extract perception (detected obstacle) frames
+	if pept := lubaMsg.GetPept(); pept != nil {
+		if vis := pept.GetPerceptionObstaclesVisualization(); vis != nil {
+			log.Printf("DEBUG: Perception heartbeat=%d obstacles=%d scale=%.3f",
+				vis.GetIsHeartBeat(), len(vis.GetObstacles()), vis.GetScale())
+			if sm.OnPerceptionReceived != nil {
+				sm.OnPerceptionReceived(extractPerception(vis))
+			}
+		}
+	}
+	return nil
 }
 
 func (mbcd *MammotionBaseCloudDevice) parseMessagePropertiesForDevice(event interface{}) {
@@ -310,8 +449,10 @@ func (mbcd *MammotionBaseCloudDevice) parseMessagePropertiesForDevice(event inte
 	mbcd.stateManager.Properties(thingPropertiesMessage)
 }
 
+// updateRawData publishes a decoded device→app LubaMsg to rawDataEvent
+// subscribers (the CloudTransport).
 func (mbcd *MammotionBaseCloudDevice) updateRawData(data []byte) {
-	// Implement this method
+	mbcd.rawDataEvent.Trigger(data)
 }
 
 // extractDataCouple flattens CommDataCouple pairs preserving float precision.
@@ -324,3 +465,53 @@ func extractDataCouple(dataCouples []*pb.CommDataCouple) []float32 {
 	}
 	return result
 }
+
+// extractPerception converts perception obstacle outlines to metres. Points
+// are integers scaled by the frame's scale factor (points / scale = metres);
+// a zero scale is treated as already in metres.
+func extractPerception(vis *pb.PerceptionObstaclesVisualizationT) *PerceptionData {
+	scale := float32(vis.GetScale())
+	if scale == 0 {
+		scale = 1
+	}
+	data := &PerceptionData{
+		HeartBeat: vis.GetIsHeartBeat() != 0,
+		Timestamp: vis.GetTimestamp(),
+	}
+	for _, ob := range vis.GetObstacles() {
+		xs, ys := ob.GetPointsX(), ob.GetPointsY()
+		n := len(xs)
+		if len(ys) < n {
+			n = len(ys)
+		}
+		pts := make([]float32, 0, n*2)
+		for i := 0; i < n; i++ {
+			pts = append(pts, float32(xs[i])/scale, float32(ys[i])/scale)
+		}
+		data.Obstacles = append(data.Obstacles, PerceptionObstacle{Label: ob.GetLabel(), Points: pts})
+	}
+	return data
+}
+
+// extractWork converts an rpt_work report. The breakpoint position uses the
+// same 0.1mm units as RealPos; a report with neither bp_info nor bp_hash set
+// has no interrupted job.
+func extractWork(w *pb.RptWork) *WorkData {
+	data := &WorkData{
+		Plan:        w.GetPlan(),
+		PathHash:    w.GetPathHash(),
+		Progress:    w.GetProgress(),
+		Area:        w.GetArea(),
+		KnifeHeight: w.GetKnifeHeight(),
+	}
+	if w.GetBpInfo() != 0 || w.GetBpHash() != 0 {
+		data.BreakPoint = &BreakPointData{
+			X:        float64(w.GetBpPosX()) / 10000.0,
+			Y:        float64(w.GetBpPosY()) / 10000.0,
+			ZoneHash: uint64(w.GetBpHash()),
+			Info:     w.GetBpInfo(),
+			FromWork: true,
+		}
+	}
+	return data
+}
@@ -18,6 +18,16 @@ import (
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 )
 
+// BrokerURL, when set, replaces the regional Aliyun broker address, e.g.
+// tcp://127.0.0.1:1883 for a local broker. tls:// and ssl:// URLs use the
+// TLS config from NewTLSConfig.
+var BrokerURL string
+
+// RootCAFile, when set, is a PEM bundle of the only roots trusted for the
+// broker certificate, and the certificate is then verified. Without it the
+// system pool plus ./x509/*.pem is used and verification is skipped.
+var RootCAFile string
+
 type MammotionMQTT struct {
 	RegionID       string
 	ProductKey     string
@@ -68,6 +78,9 @@ func NewMammotionMQTT(regionID, productKey, deviceName, deviceSecret, iotToken s
     opts := mqtt.NewClientOptions()
     // Now use the actual region since we have the correct ProductKey
     brokerURL := fmt.Sprintf("tls://%s.iot-as-mqtt.%s.aliyuncs.com:8883", productKey, regionID)
+    if BrokerURL != "" {
+        brokerURL = BrokerURL
+    }
     // Connection details (logging disabled for cleaner output)
     opts.AddBroker(brokerURL)
     opts.SetClientID(auth.mqttClientId)
@@ -291,6 +304,17 @@ func calculate_sign(clientId, productKey, deviceName, deviceSecret string) AuthI
 }
 
 func NewTLSConfig() *tls.Config {
+    if RootCAFile != "" {
+        certpool := x509.NewCertPool()
+        pemCerts, err := ioutil.ReadFile(RootCAFile)
+        if err != nil {
+            log.Printf("Warning: failed to read root CA file: %v", err)
+        } else if ok := certpool.AppendCertsFromPEM(pemCerts); !ok {
+            log.Printf("Warning: no certificates parsed from %s", RootCAFile)
+        }
+        return &tls.Config{RootCAs: certpool, ClientAuth: tls.NoClientCert}
+    }
+
     // Use system certificate pool for better compatibility
     certpool, err := x509.SystemCertPool()
     if err != nil {
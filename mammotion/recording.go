@@ -0,0 +1,272 @@
+package mammotion
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// A session recording (.mrec) is every LubaMsg that crossed a Transport, in
+// order, with its direction and time:
+//
+//	header: "MREC" | version (1 byte) | start, unix ms (int64 BE) |
+//	        uvarint length | device name
+//	record: direction (1 byte) | uvarint µs since start |
+//	        uvarint length | LubaMsg bytes
+//
+// Records are written as they happen, so a recording survives a crash up to
+// the last complete record.
+const (
+	recordingMagic   = "MREC"
+	recordingVersion = 1
+
+	recordInbound  = 0 // device→app
+	recordOutbound = 1 // app→device
+
+	// maxRecordedChunk bounds a device name or message read back from a
+	// file; LubaMsg frames are a few KiB, so anything larger is corruption.
+	maxRecordedChunk = 4 << 20
+)
+
+// RecordedMsg is one message from a recording.
+type RecordedMsg struct {
+	At       time.Duration // since the recording started
+	Outbound bool          // app→device; false for device→app
+	Data     []byte
+}
+
+// Recording is a decoded session recording.
+type Recording struct {
+	Start    time.Time
+	Device   string
+	Messages []RecordedMsg
+}
+
+// SessionRecorder writes a session recording.
+type SessionRecorder struct {
+	mu    sync.Mutex
+	w     io.Writer
+	start time.Time
+	err   error
+}
+
+// NewSessionRecorder writes the recording header to w. Close closes w if it
+// is an io.Closer.
+func NewSessionRecorder(w io.Writer, device string, start time.Time) (*SessionRecorder, error) {
+	hdr := append([]byte(recordingMagic), recordingVersion)
+	hdr = binary.BigEndian.AppendUint64(hdr, uint64(start.UnixMilli()))
+	hdr = binary.AppendUvarint(hdr, uint64(len(device)))
+	hdr = append(hdr, device...)
+	if _, err := w.Write(hdr); err != nil {
+		return nil, err
+	}
+	return &SessionRecorder{w: w, start: start}, nil
+}
+
+// CreateSessionRecording creates (truncating) a recording file at path.
+func CreateSessionRecording(path, device string) (*SessionRecorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewSessionRecorder(f, device, time.Now())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Record appends one message stamped with the current time. After a write
+// error every later call returns that error.
+func (r *SessionRecorder) Record(outbound bool, data []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	dir := byte(recordInbound)
+	if outbound {
+		dir = recordOutbound
+	}
+	rec := []byte{dir}
+	rec = binary.AppendUvarint(rec, uint64(time.Since(r.start).Microseconds()))
+	rec = binary.AppendUvarint(rec, uint64(len(data)))
+	rec = append(rec, data...)
+	_, r.err = r.w.Write(rec)
+	return r.err
+}
+
+func (r *SessionRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c, ok := r.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// ReadRecording decodes a recording. A truncated final record (the process
+// died mid-write) is dropped rather than treated as an error.
+func ReadRecording(r io.Reader) (*Recording, error) {
+	br := bufio.NewReader(r)
+	hdr := make([]byte, len(recordingMagic)+1+8)
+	if _, err := io.ReadFull(br, hdr); err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	if string(hdr[:4]) != recordingMagic {
+		return nil, errors.New("not a session recording")
+	}
+	if hdr[4] != recordingVersion {
+		return nil, fmt.Errorf("unsupported recording version %d", hdr[4])
+	}
+	rec := &Recording{Start: time.UnixMilli(int64(binary.BigEndian.Uint64(hdr[5:])))}
+	name, err := readChunk(br)
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	rec.Device = string(name)
+
+	for {
+		dir, err := br.ReadByte()
+		if err == io.EOF {
+			return rec, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if dir != recordInbound && dir != recordOutbound {
+			return nil, fmt.Errorf("record %d: bad direction %d", len(rec.Messages), dir)
+		}
+		us, err := binary.ReadUvarint(br)
+		if err != nil {
+			return rec, nil
+		}
+		data, err := readChunk(br)
+		if errors.Is(err, errChunkTooLong) {
+			return nil, fmt.Errorf("record %d: %w", len(rec.Messages), err)
+		}
+		if err != nil {
+			return rec, nil
+		}
+		rec.Messages = append(rec.Messages, RecordedMsg{
+			At:       time.Duration(us) * time.Microsecond,
+			Outbound: dir == recordOutbound,
+			Data:     data,
+		})
+	}
+}
+
+// LoadRecording reads a recording file.
+func LoadRecording(path string) (*Recording, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadRecording(f)
+}
+
+var errChunkTooLong = errors.New("length exceeds the recording limit")
+
+func readChunk(br *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxRecordedChunk {
+		return nil, fmt.Errorf("%w: %d bytes", errChunkTooLong, n)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(br, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Duration is the time of the last message.
+func (rec *Recording) Duration() time.Duration {
+	if len(rec.Messages) == 0 {
+		return 0
+	}
+	return rec.Messages[len(rec.Messages)-1].At
+}
+
+// Replay passes each message to fn, keeping the recorded spacing divided by
+// speed (speed <= 0 replays as fast as possible). It returns early, false,
+// when stop is closed.
+func (rec *Recording) Replay(speed float64, stop <-chan struct{}, fn func(RecordedMsg)) bool {
+	begin := time.Now()
+	for _, m := range rec.Messages {
+		if speed > 0 {
+			due := begin.Add(time.Duration(float64(m.At) / speed))
+			if wait := time.Until(due); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-stop:
+					return false
+				}
+			}
+		}
+		select {
+		case <-stop:
+			return false
+		default:
+		}
+		fn(m)
+	}
+	return true
+}
+
+// RecordingTransport wraps a Transport and records every message crossing it.
+type RecordingTransport struct {
+	Transport
+	rec         *SessionRecorder
+	unsubscribe func()
+}
+
+// NewRecordingTransport starts recording t's traffic to rec. Closing the
+// returned transport closes t and rec.
+func NewRecordingTransport(t Transport, rec *SessionRecorder) *RecordingTransport {
+	rt := &RecordingTransport{Transport: t, rec: rec}
+	rt.unsubscribe = t.Subscribe(func(data []byte) {
+		rec.Record(false, data)
+	})
+	return rt
+}
+
+func (rt *RecordingTransport) Send(data []byte) error {
+	rt.rec.Record(true, data)
+	return rt.Transport.Send(data)
+}
+
+// Refresh passes through to the wrapped transport's session refresh, if any.
+func (rt *RecordingTransport) Refresh() error {
+	if r, ok := rt.Transport.(interface{ Refresh() error }); ok {
+		return r.Refresh()
+	}
+	return nil
+}
+
+// Stats passes through to the wrapped transport's cloud counters; they are
+// all zero if it has none.
+func (rt *RecordingTransport) Stats() CloudStats {
+	if st, ok := rt.Transport.(interface{ Stats() CloudStats }); ok {
+		return st.Stats()
+	}
+	return CloudStats{}
+}
+
+func (rt *RecordingTransport) Close() error {
+	rt.unsubscribe()
+	err := rt.Transport.Close()
+	if cerr := rt.rec.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,133 @@
+package mammotion
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecordingTransportRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s.mrec")
+	rec, err := CreateSessionRecording(path, "Luba-SIM")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loop *LoopbackTransport
+	loop = NewLoopbackTransport(func(data []byte) error {
+		loop.Deliver(append([]byte{0xee}, data...)) // answer each command
+		return nil
+	})
+	rt := NewRecordingTransport(loop, rec)
+	var got [][]byte
+	rt.Subscribe(func(data []byte) { got = append(got, data) })
+	rt.Send([]byte{1, 2})
+	rt.Send([]byte{3})
+	if err := rt.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || loop.State() != TransportClosed {
+		t.Fatalf("subscriber got %v, loop %v", got, loop.State())
+	}
+
+	r, err := LoadRecording(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Device != "Luba-SIM" || time.Since(r.Start) > time.Minute || len(r.Messages) != 4 {
+		t.Fatalf("recording %q started %v with %d messages", r.Device, r.Start, len(r.Messages))
+	}
+	want := []struct {
+		outbound bool
+		data     []byte
+	}{{true, []byte{1, 2}}, {false, []byte{0xee, 1, 2}}, {true, []byte{3}}, {false, []byte{0xee, 3}}}
+	for i, w := range want {
+		m := r.Messages[i]
+		if m.Outbound != w.outbound || !bytes.Equal(m.Data, w.data) {
+			t.Errorf("message %d = %+v, want outbound %v %v", i, m, w.outbound, w.data)
+		}
+		if i > 0 && m.At < r.Messages[i-1].At {
+			t.Errorf("message %d at %v is before the one before it", i, m.At)
+		}
+	}
+	if r.Duration() != r.Messages[3].At {
+		t.Errorf("duration %v, want the last message's time", r.Duration())
+	}
+}
+
+func TestReadRecordingDropsTruncatedRecord(t *testing.T) {
+	var buf bytes.Buffer
+	rec, err := NewSessionRecorder(&buf, "d", time.UnixMilli(1700000000000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.Record(true, []byte("whole"))
+	rec.Record(false, []byte("cut short"))
+	data := buf.Bytes()[:buf.Len()-3]
+
+	r, err := ReadRecording(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Start.Equal(time.UnixMilli(1700000000000)) || len(r.Messages) != 1 || string(r.Messages[0].Data) != "whole" {
+		t.Errorf("recording %+v", r)
+	}
+}
+
+func TestReadRecordingRejectsOtherFiles(t *testing.T) {
+	var buf bytes.Buffer
+	NewSessionRecorder(&buf, "d", time.Now())
+	future := append([]byte(nil), buf.Bytes()...)
+	future[4] = recordingVersion + 1
+	badDir := append(append([]byte(nil), buf.Bytes()...), 7, 0, 0)
+	// Corrupt lengths must fail rather than allocate what they ask for.
+	hugeName := binary.AppendUvarint(append([]byte(nil), buf.Bytes()[:13]...), 1<<40)
+	hugeRecord := binary.AppendUvarint(append(append([]byte(nil), buf.Bytes()...), recordInbound, 0), 1<<40)
+
+	for name, data := range map[string][]byte{
+		"not a recording": []byte("PK\x03\x04 a zip file"),
+		"newer version":   future,
+		"short header":    buf.Bytes()[:6],
+		"bad direction":   badDir,
+		"huge name":       hugeName,
+		"huge record":     hugeRecord,
+	} {
+		if _, err := ReadRecording(bytes.NewReader(data)); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+	if _, err := LoadRecording(filepath.Join(t.TempDir(), "missing.mrec")); !os.IsNotExist(err) {
+		t.Errorf("missing file: %v", err)
+	}
+}
+
+func TestReplaySpacingAndStop(t *testing.T) {
+	r := &Recording{Messages: []RecordedMsg{
+		{At: 0, Data: []byte{1}},
+		{At: 40 * time.Millisecond, Data: []byte{2}},
+		{At: time.Hour, Data: []byte{3}},
+	}}
+
+	var n int
+	if !r.Replay(0, nil, func(RecordedMsg) { n++ }) || n != 3 {
+		t.Errorf("replay as fast as possible: %d messages", n)
+	}
+
+	stop := make(chan struct{})
+	var times []time.Duration
+	begin := time.Now()
+	done := make(chan bool)
+	go func() {
+		done <- r.Replay(1, stop, func(RecordedMsg) { times = append(times, time.Since(begin)) })
+	}()
+	time.Sleep(100 * time.Millisecond)
+	close(stop)
+	if <-done {
+		t.Error("replay reported finishing after stop")
+	}
+	if len(times) != 2 || times[1] < 40*time.Millisecond {
+		t.Errorf("replayed at %v, want two messages with the second 40ms in", times)
+	}
+}
@@ -35,6 +35,125 @@ type ZigZagData struct {
 	SubCmd       int32
 }
 
+// AreaNameData is the device's table of user-given zone names (set in the
+// phone app), keyed by area hash.
+type AreaNameData struct {
+	DeviceID string
+	Names    map[int64]string
+}
+
+// SvgData is one frame of an SVG pattern overlay (type 13 map element). The
+// file data is split across frames; placement fields are repeated per frame.
+type SvgData struct {
+	Hash          uint64
+	TotalFrame    int32
+	CurrentFrame  int32
+	Type          int32
+	Result        int32
+	XMove         float64 // placement offset in map metres
+	YMove         float64
+	Scale         float64
+	Rotate        float64 // degrees
+	BaseWidthM    float64
+	BaseHeightM   float64
+	BaseWidthPix  int32
+	BaseHeightPix int32
+	Hidden        bool
+	FileName      string
+	FileData      string
+}
+
+// CostmapData is the mower's local obstacle cost grid (toapp_costmap): Width x
+// Height cells of Res metres, centred at CenterX, CenterY in the map frame
+// and rotated by Yaw radians. Cells are row-major, 0 = free.
+type CostmapData struct {
+	Width   int32
+	Height  int32
+	CenterX float32
+	CenterY float32
+	Yaw     float32
+	Res     float32
+	Cells   []int32
+}
+
+// PerceptionObstacle is one obstacle outline detected by the mower's sensors.
+type PerceptionObstacle struct {
+	Label  int32
+	Points []float32 // X,Y pairs in meters (map frame)
+}
+
+// PerceptionData is one perception_obstacles_visualization frame. A heartbeat
+// frame carries no obstacles and only signals that perception is alive.
+type PerceptionData struct {
+	HeartBeat bool
+	Timestamp float64
+	Obstacles []PerceptionObstacle
+}
+
+// BreakPointData is where an interrupted job will resume. It arrives either as
+// a toapp_bp push (metres, with heading and resume action) or inside the
+// rpt_work report (position in 0.1mm units, converted to metres here).
+type BreakPointData struct {
+	X        float64 // meters, map frame
+	Y        float64
+	Toward   int32 // heading; only set by toapp_bp
+	Flag     int32
+	Action   int32
+	ZoneHash uint64
+	Info     int32 // rpt_work bp_info
+	FromWork bool  // true when decoded from rpt_work rather than toapp_bp
+}
+
+// WorkData is the job summary from rpt_work. BreakPoint is nil when no job
+// is interrupted.
+type WorkData struct {
+	Plan        int32
+	PathHash    int64
+	Progress    int32
+	Area        int32
+	KnifeHeight int32 // cutting height, mm
+	BreakPoint  *BreakPointData
+}
+
+// RtkData is the rpt_rtk part of a device report: fix status and satellite
+// counts from the mower's RTK receiver.
+type RtkData struct {
+	Status      int32
+	PosLevel    int32
+	GpsStars    int32
+	L2Stars     int32
+	CoViewStars int32 // satellites seen by both the mower and the base
+	Age         int32 // age of the RTK corrections, as reported
+	LatStd      int32 // position standard deviations, as reported
+	LonStd      int32
+}
+
+// MaintainData is the rpt_maintain part of a device report: lifetime totals
+// in the device's own units.
+type MaintainData struct {
+	Mileage   int64
+	WorkTime  int32
+	BatCycles int32
+}
+
+// ConnectData is the rpt_connect_status part of a device report: which link
+// the mower is using and its signal strengths (dBm).
+type ConnectData struct {
+	ConnectType int32
+	BleRssi     int32
+	WifiRssi    int32
+	MnetRssi    int32
+}
+
+// DeviceEvent is a cloud-side device event other than a protobuf message: a
+// device_warning_event carries Code, a device_notification_event or
+// device_warning_code_event carries Data (the raw value string).
+type DeviceEvent struct {
+	Identifier string
+	Code       int
+	Data       string
+}
+
 type StateManager struct {
 	Device                 *MowingDevice
 	LastUpdatedAt          time.Time
@@ -49,6 +168,18 @@ type StateManager struct {
 	OnChargePilePosition   func(toward int32, x, y float32) // Dock position callback
 	OnDeviceStatus         func(sysStatus, chargeState int32) // System/charge status callback
 	OnZigZagReceived       func(*ZigZagData) // Planned coverage-path frame callback
+	OnAreaNamesReceived    func(*AreaNameData) // Zone name table callback
+	OnSvgReceived          func(*SvgData) // SVG overlay frame callback
+	OnCostmapReceived      func(*CostmapData) // Local costmap callback
+	OnPerceptionReceived   func(*PerceptionData) // Detected obstacles callback
+	OnWorkReport           func(*WorkData) // Job progress / breakpoint report callback
+	OnBreakPointReceived   func(*BreakPointData) // Interrupted-job breakpoint callback
+	OnErrorCode            func(code int32) // Device error code callback
+	OnRtkReport            func(*RtkData) // RTK receiver status callback
+	OnMaintainReport       func(*MaintainData) // Lifetime totals callback
+	OnConnectReport        func(*ConnectData) // Link / signal strength callback
+	OnLockState            func(state uint32) // Lift / lock sensor callback
+	OnDeviceEvent          func(*DeviceEvent) // Warning / notification event callback
 	mu                     sync.Mutex
 }
 
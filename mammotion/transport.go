@@ -0,0 +1,165 @@
+package mammotion
+
+import (
+	"errors"
+	"log"
+	"sync"
+)
+
+// TransportState is the connection state of a Transport.
+type TransportState int
+
+const (
+	TransportDisconnected TransportState = iota
+	TransportConnecting
+	TransportConnected
+	TransportClosed
+)
+
+func (s TransportState) String() string {
+	switch s {
+	case TransportDisconnected:
+		return "disconnected"
+	case TransportConnecting:
+		return "connecting"
+	case TransportConnected:
+		return "connected"
+	case TransportClosed:
+		return "closed"
+	}
+	return "unknown"
+}
+
+// ErrTransportClosed is returned by Send after Close.
+var ErrTransportClosed = errors.New("transport closed")
+
+// Transport carries raw LubaMsg bytes between the app and one mower. Send
+// delivers an app→device command; every device→app message is passed to the
+// functions registered with Subscribe. Implementations must allow Send to be
+// called from inside a subscriber.
+type Transport interface {
+	Send(data []byte) error
+	Subscribe(fn func(data []byte)) (unsubscribe func())
+	Close() error
+	State() TransportState
+}
+
+// subscriberSet is the fan-out shared by Transport implementations.
+type subscriberSet struct {
+	mu   sync.Mutex
+	next int
+	fns  map[int]func([]byte)
+}
+
+func (s *subscriberSet) add(fn func([]byte)) func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fns == nil {
+		s.fns = make(map[int]func([]byte))
+	}
+	id := s.next
+	s.next++
+	s.fns[id] = fn
+	return func() {
+		s.mu.Lock()
+		delete(s.fns, id)
+		s.mu.Unlock()
+	}
+}
+
+// deliver calls every subscriber without holding the lock, so a subscriber
+// may subscribe, unsubscribe or send.
+func (s *subscriberSet) deliver(data []byte) {
+	s.mu.Lock()
+	fns := make([]func([]byte), 0, len(s.fns))
+	// deliver in subscription order
+	for i := 0; i < s.next; i++ {
+		if fn, ok := s.fns[i]; ok {
+			fns = append(fns, fn)
+		}
+	}
+	s.mu.Unlock()
+	for _, fn := range fns {
+		fn(data)
+	}
+}
+
+// LoopbackTransport is an in-process Transport. Sent commands go to a handler
+// (a simulator, a recording being replayed, or nothing) and Deliver injects
+// device→app messages as if they had arrived from the mower.
+type LoopbackTransport struct {
+	mu      sync.Mutex
+	handler func([]byte) error
+	sent    [][]byte
+	closed  bool
+	subs    subscriberSet
+}
+
+// NewLoopbackTransport returns a connected loopback. handler may be nil, in
+// which case sent commands are only recorded.
+func NewLoopbackTransport(handler func([]byte) error) *LoopbackTransport {
+	return &LoopbackTransport{handler: handler}
+}
+
+func (l *LoopbackTransport) Send(data []byte) error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return ErrTransportClosed
+	}
+	l.sent = append(l.sent, append([]byte(nil), data...))
+	handler := l.handler
+	l.mu.Unlock()
+	if handler != nil {
+		return handler(data)
+	}
+	return nil
+}
+
+func (l *LoopbackTransport) Subscribe(fn func([]byte)) func() {
+	return l.subs.add(fn)
+}
+
+// Deliver hands a device→app message to every subscriber. It is a no-op once
+// the transport is closed.
+func (l *LoopbackTransport) Deliver(data []byte) {
+	l.mu.Lock()
+	closed := l.closed
+	l.mu.Unlock()
+	if !closed {
+		l.subs.deliver(data)
+	}
+}
+
+// Sent returns a copy of every command sent so far, in order.
+func (l *LoopbackTransport) Sent() [][]byte {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([][]byte(nil), l.sent...)
+}
+
+func (l *LoopbackTransport) Close() error {
+	l.mu.Lock()
+	l.closed = true
+	l.mu.Unlock()
+	return nil
+}
+
+func (l *LoopbackTransport) State() TransportState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return TransportClosed
+	}
+	return TransportConnected
+}
+
+// Attach feeds every message arriving on t through HandleLubaMsg. The
+// returned function detaches.
+func (sm *StateManager) Attach(t Transport) func() {
+	return t.Subscribe(func(data []byte) {
+		if err := sm.HandleLubaMsg(data); err != nil {
+			log.Printf("Error parsing protobuf message: %v", err)
+		}
+	})
+}
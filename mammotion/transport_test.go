@@ -0,0 +1,77 @@
+package mammotion
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLoopbackTransport(t *testing.T) {
+	var handled int
+	loop := NewLoopbackTransport(func([]byte) error { handled++; return nil })
+	var got [][]byte
+	unsubscribe := loop.Subscribe(func(data []byte) { got = append(got, data) })
+
+	if err := loop.Send([]byte{1}); err != nil || handled != 1 || len(loop.Sent()) != 1 {
+		t.Fatalf("send: err %v, handled %d, sent %d", err, handled, len(loop.Sent()))
+	}
+	loop.Deliver([]byte{2})
+	unsubscribe()
+	loop.Deliver([]byte{3})
+	if len(got) != 1 || got[0][0] != 2 {
+		t.Errorf("subscriber got %v, want only the message before unsubscribing", got)
+	}
+
+	loop.Close()
+	if loop.State() != TransportClosed {
+		t.Errorf("state after close = %v", loop.State())
+	}
+	if err := loop.Send([]byte{4}); err != ErrTransportClosed {
+		t.Errorf("send after close = %v, want ErrTransportClosed", err)
+	}
+}
+
+func TestSubscribersInOrderAndReentrant(t *testing.T) {
+	loop := NewLoopbackTransport(nil)
+	var order []string
+	var unsubscribeSecond func()
+	loop.Subscribe(func(data []byte) {
+		order = append(order, "first")
+		// A subscriber may send, subscribe and unsubscribe while a message
+		// is being delivered.
+		if err := loop.Send(data); err != nil {
+			t.Errorf("send from a subscriber: %v", err)
+		}
+		unsubscribeSecond()
+		loop.Subscribe(func([]byte) { order = append(order, "late") })
+	})
+	unsubscribeSecond = loop.Subscribe(func([]byte) { order = append(order, "second") })
+	loop.Subscribe(func([]byte) { order = append(order, "third") })
+
+	loop.Deliver([]byte{1})
+	if got := len(loop.Sent()); got != 1 {
+		t.Errorf("%d sent from the subscriber, want 1", got)
+	}
+	// The delivery in progress still reaches those subscribed when it began.
+	if want := []string{"first", "second", "third"}; !slices.Equal(order, want) {
+		t.Errorf("first delivery order %v, want %v", order, want)
+	}
+	order = nil
+	loop.Deliver([]byte{2})
+	if want := []string{"first", "third", "late"}; !slices.Equal(order, want) {
+		t.Errorf("second delivery order %v, want %v", order, want)
+	}
+}
+
+func TestTransportStateString(t *testing.T) {
+	for state, want := range map[TransportState]string{
+		TransportDisconnected: "disconnected",
+		TransportConnecting:   "connecting",
+		TransportConnected:    "connected",
+		TransportClosed:       "closed",
+		TransportState(9):     "unknown",
+	} {
+		if got := state.String(); got != want {
+			t.Errorf("%d.String() = %q, want %q", int(state), got, want)
+		}
+	}
+}